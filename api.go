@@ -8,12 +8,15 @@ import (
 	"net/http"
 	"net/http/pprof" // register handlers
 	"regexp"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/zephyrtronium/robot/brain"
 )
 
 // Userspace metrics.
@@ -42,9 +45,137 @@ var (
 		Name:      "forgot",
 		Help:      "Number of individual messages deleted. Does not include messages deleted by user or time.",
 	})
+	expiredCount = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "robot",
+		Subsystem: "brain",
+		Name:      "expired",
+		Help:      "Number of messages deleted for exceeding their channel's retention period.",
+	})
+	speakLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "robot",
+		Subsystem: "brain",
+		Name:      "speak_seconds",
+		Help:      "Time spent generating messages.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"tag"})
+	rateLimitedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "robot",
+		Subsystem: "channel",
+		Name:      "rate_limited",
+		Help:      "Number of actions rejected by rate limits, by the limit which rejected them.",
+	}, []string{"channel", "budget"})
+	chatVelocity = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "robot",
+		Subsystem: "channel",
+		Name:      "messages_per_minute",
+		Help:      "Moving average of the rate of chat messages.",
+	}, []string{"channel"})
+	responseProbability = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "robot",
+		Subsystem: "channel",
+		Name:      "response_probability",
+		Help:      "Current probability of a random response to each message.",
+	}, []string{"channel"})
+)
+
+// brainCollector collects statistics about a brain's knowledge.
+// Statistics can be expensive to compute, so they are cached per tag and
+// recomputed at most once per refresh interval.
+type brainCollector struct {
+	br brain.Statter
+	// tags returns the tags to scan. It is called at the start of each scan
+	// so that reloads which add or retag channels take effect.
+	tags    func() []string
+	refresh time.Duration
+
+	// stats is the result of the most recent scan.
+	stats atomic.Pointer[brainStats]
+	// scanning is set while a scan is in progress.
+	scanning atomic.Bool
+}
+
+// brainStats is the result of a scan of a brain's statistics.
+type brainStats struct {
+	tags map[string]*brain.Stats
+	at   time.Time
+}
+
+var (
+	brainMessagesDesc = prometheus.NewDesc("robot_brain_messages", "Number of messages the brain knows.", []string{"tag", "state"}, nil)
+	brainTuplesDesc   = prometheus.NewDesc("robot_brain_tuples", "Number of tuples the brain knows.", []string{"tag"}, nil)
+	brainPrefixesDesc = prometheus.NewDesc("robot_brain_prefixes", "Number of distinct prefixes the brain knows.", []string{"tag"}, nil)
+	brainStartsDesc   = prometheus.NewDesc("robot_brain_starts", "Number of tuples which start a message.", []string{"tag"}, nil)
+	brainOldestDesc   = prometheus.NewDesc("robot_brain_oldest_seconds", "Unix time of the oldest message the brain knows.", []string{"tag"}, nil)
+	brainNewestDesc   = prometheus.NewDesc("robot_brain_newest_seconds", "Unix time of the newest message the brain knows.", []string{"tag"}, nil)
 )
 
-func api(ctx context.Context, listen string, mux *http.ServeMux) error {
+func newBrainCollector(br brain.Statter, tags func() []string) *brainCollector {
+	return &brainCollector{
+		br:      br,
+		tags:    tags,
+		refresh: 5 * time.Minute,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *brainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- brainMessagesDesc
+	ch <- brainTuplesDesc
+	ch <- brainPrefixesDesc
+	ch <- brainStartsDesc
+	ch <- brainOldestDesc
+	ch <- brainNewestDesc
+}
+
+// Collect implements prometheus.Collector.
+// Scans can take a long time, so at most one runs at once. Collections which
+// happen during a scan report the previous results.
+func (c *brainCollector) Collect(ch chan<- prometheus.Metric) {
+	cur := c.stats.Load()
+	if (cur == nil || time.Since(cur.at) >= c.refresh) && c.scanning.CompareAndSwap(false, true) {
+		cur = c.scan(cur)
+		c.scanning.Store(false)
+	}
+	if cur == nil {
+		return
+	}
+	for tag, s := range cur.tags {
+		ch <- prometheus.MustNewConstMetric(brainMessagesDesc, prometheus.GaugeValue, float64(s.Messages), tag, "live")
+		ch <- prometheus.MustNewConstMetric(brainMessagesDesc, prometheus.GaugeValue, float64(s.Deleted), tag, "deleted")
+		ch <- prometheus.MustNewConstMetric(brainTuplesDesc, prometheus.GaugeValue, float64(s.Tuples), tag)
+		ch <- prometheus.MustNewConstMetric(brainPrefixesDesc, prometheus.GaugeValue, float64(s.Prefixes), tag)
+		ch <- prometheus.MustNewConstMetric(brainStartsDesc, prometheus.GaugeValue, float64(s.Starts), tag)
+		if !s.Oldest.IsZero() {
+			ch <- prometheus.MustNewConstMetric(brainOldestDesc, prometheus.GaugeValue, float64(s.Oldest.Unix()), tag)
+			ch <- prometheus.MustNewConstMetric(brainNewestDesc, prometheus.GaugeValue, float64(s.Newest.Unix()), tag)
+		}
+	}
+}
+
+// scan computes statistics for all tags and stores them as the current
+// results. Tags which fail keep their results from prev, if any.
+func (c *brainCollector) scan(prev *brainStats) *brainStats {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tags := c.tags()
+	r := &brainStats{tags: make(map[string]*brain.Stats, len(tags))}
+	for _, tag := range tags {
+		s, err := c.br.Stats(ctx, tag)
+		if err != nil {
+			slog.ErrorContext(ctx, "couldn't get brain stats", slog.String("tag", tag), slog.Any("err", err))
+			if prev != nil && prev.tags[tag] != nil {
+				r.tags[tag] = prev.tags[tag]
+			}
+			continue
+		}
+		r.tags[tag] = s
+	}
+	r.at = time.Now()
+	c.stats.Store(r)
+	return r
+}
+
+func api(ctx context.Context, listen string, mux *http.ServeMux, extra ...prometheus.Collector) error {
 	reg := prometheus.NewRegistry()
 	reg.MustRegister(collectors.NewGoCollector(
 		collectors.WithGoCollectorMemStatsMetricsDisabled(),
@@ -58,6 +189,14 @@ func api(ctx context.Context, listen string, mux *http.ServeMux) error {
 	reg.MustRegister(tmiCommandsCount)
 	reg.MustRegister(learnedCount)
 	reg.MustRegister(forgortCount)
+	reg.MustRegister(expiredCount)
+	reg.MustRegister(speakLatency)
+	reg.MustRegister(rateLimitedCount)
+	reg.MustRegister(chatVelocity)
+	reg.MustRegister(responseProbability)
+	for _, c := range extra {
+		reg.MustRegister(c)
+	}
 	opts := promhttp.HandlerOpts{
 		EnableOpenMetrics: true,
 	}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+// slowStatter is a brain.Statter whose first scan blocks until released.
+type slowStatter struct {
+	calls   atomic.Int64
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStatter) Stats(ctx context.Context, tag string) (*brain.Stats, error) {
+	if s.calls.Add(1) == 1 {
+		close(s.entered)
+		<-s.release
+	}
+	return &brain.Stats{Messages: 1, Tuples: 2, Oldest: time.Unix(1, 0), Newest: time.Unix(2, 0)}, nil
+}
+
+func collect(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
+
+func TestBrainCollector(t *testing.T) {
+	st := &slowStatter{entered: make(chan struct{}), release: make(chan struct{})}
+	tags := []string{"kessoku"}
+	c := newBrainCollector(st, func() []string { return tags })
+	first := make(chan int, 1)
+	go func() { first <- collect(c) }()
+	<-st.entered
+	// A collection during the scan must not wait for it.
+	done := make(chan int, 1)
+	go func() { done <- collect(c) }()
+	select {
+	case n := <-done:
+		if n != 0 {
+			t.Errorf("wrong number of metrics before first scan: want 0, got %d", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("collection blocked on scan")
+	}
+	close(st.release)
+	// Two message states, tuples, prefixes, starts, oldest, and newest.
+	if n := <-first; n != 7 {
+		t.Errorf("wrong number of metrics from scan: want 7, got %d", n)
+	}
+	if n := collect(c); n != 7 {
+		t.Errorf("wrong number of metrics after scan: want 7, got %d", n)
+	}
+	if n := st.calls.Load(); n != 1 {
+		t.Errorf("wrong number of scans: want 1, got %d", n)
+	}
+	// Tags added later, e.g. by reloading, are scanned next time.
+	tags = append(tags, "bocchi")
+	c.refresh = 0
+	if n := collect(c); n != 14 {
+		t.Errorf("wrong number of metrics after adding a tag: want 14, got %d", n)
+	}
+}
@@ -224,6 +224,68 @@ func BenchSpeak(ctx context.Context, b *testing.B, new func(ctx context.Context,
 	}
 }
 
+// BenchSize reports the storage a brain uses per learned message as the
+// bytes/msg metric. size returns the total storage currently used by the
+// learner in bytes.
+func BenchSize(ctx context.Context, b *testing.B, new func(ctx context.Context, b *testing.B) brain.Learner, size func(brain.Learner) int64, cleanup func(brain.Learner)) {
+	b.Run("similar", func(b *testing.B) {
+		l := new(ctx, b)
+		if cleanup != nil {
+			b.Cleanup(func() { cleanup(l) })
+		}
+		toks := []string{
+			hex.EncodeToString(randbytes(make([]byte, 4))),
+			hex.EncodeToString(randbytes(make([]byte, 4))),
+			hex.EncodeToString(randbytes(make([]byte, 4))),
+			hex.EncodeToString(randbytes(make([]byte, 4))),
+			hex.EncodeToString(randbytes(make([]byte, 4))),
+			hex.EncodeToString(randbytes(make([]byte, 4))),
+			hex.EncodeToString(randbytes(make([]byte, 4))),
+			hex.EncodeToString(randbytes(make([]byte, 4))),
+		}
+		base := size(l)
+		for t := range int64(b.N) {
+			toks[len(toks)-1] = strconv.FormatInt(t, 10)
+			id := randid()
+			u := userhash.Hash(randbytes(make([]byte, len(userhash.Hash{}))))
+			err := brain.Learn(ctx, l, "bocchi", id, u, time.Unix(t, 0), toks)
+			if err != nil {
+				b.Errorf("error while learning: %v", err)
+			}
+		}
+		b.ReportMetric(float64(size(l)-base)/float64(b.N), "bytes/msg")
+	})
+	b.Run("chat", func(b *testing.B) {
+		// Chat-like messages: varying lengths with words drawn from a Zipf
+		// distribution, so that common words and phrases repeat a lot.
+		l := new(ctx, b)
+		if cleanup != nil {
+			b.Cleanup(func() { cleanup(l) })
+		}
+		vocab := make([]string, 5000)
+		for i := range vocab {
+			vocab[i] = hex.EncodeToString(randbytes(make([]byte, 4)))[:2+i%7]
+		}
+		r := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+		z := rand.NewZipf(r, 1.1, 1, uint64(len(vocab)-1))
+		toks := make([]string, 0, 24)
+		base := size(l)
+		for t := range int64(b.N) {
+			toks = toks[:0]
+			for range 4 + r.IntN(20) {
+				toks = append(toks, vocab[z.Uint64()])
+			}
+			id := randid()
+			u := userhash.Hash(randbytes(make([]byte, len(userhash.Hash{}))))
+			err := brain.Learn(ctx, l, "bocchi", id, u, time.Unix(t, 0), toks)
+			if err != nil {
+				b.Errorf("error while learning: %v", err)
+			}
+		}
+		b.ReportMetric(float64(size(l)-base)/float64(b.N), "bytes/msg")
+	})
+}
+
 // randbytes fills a slice of at least length 4 with random data.
 func randbytes(b []byte) []byte {
 	binary.NativeEndian.PutUint32(b, rand.Uint32())
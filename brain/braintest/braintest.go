@@ -2,13 +2,18 @@
 package braintest
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/zephyrtronium/robot/brain"
 	"github.com/zephyrtronium/robot/userhash"
 )
@@ -20,7 +25,17 @@ func Test(ctx context.Context, t *testing.T, new func(context.Context) brain.Bra
 	t.Run("speak", testSpeak(ctx, new(ctx)))
 	t.Run("forgetMessage", testForgetMessage(ctx, new(ctx)))
 	t.Run("forgetDuring", testForgetDuring(ctx, new(ctx)))
+	t.Run("forgetUser", testForgetUser(ctx, new(ctx)))
 	t.Run("combinatoric", testCombinatoric(ctx, new(ctx)))
+	t.Run("expire", testExpire(ctx, new(ctx)))
+	t.Run("export", testExport(ctx, new(ctx), new(ctx)))
+	t.Run("stats", testStats(ctx, new(ctx)))
+	t.Run("tags", testTags(ctx, new(ctx)))
+	t.Run("stream", testStream(ctx, new(ctx)))
+	t.Run("seed", testSeed(ctx, new(ctx)))
+	t.Run("concurrent", testConcurrent(ctx, new(ctx)))
+	t.Run("equivalence", testEquivalence(ctx, new))
+	t.Run("ownership", testOwnership(ctx, new))
 }
 
 func these(s ...string) func() []string {
@@ -276,7 +291,516 @@ func testForgetDuring(ctx context.Context, br brain.Brain) func(t *testing.T) {
 	}
 }
 
-// TODO(zeph): testForgetUser
+// testForgetUser tests that a brain can forget all messages from a user
+// across tags.
+func testForgetUser(ctx context.Context, br brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		learn(ctx, t, br)
+		if err := br.ForgetUser(ctx, &userhash.Hash{2}); err != nil {
+			t.Errorf("failed to forget: %v", err)
+		}
+		got := speak(ctx, t, br, "kessoku", "", 2048)
+		want := map[string]struct{}{
+			"3#member nijika":   {},
+			"3 4#member nijika": {},
+			"3 4#member kita":   {},
+			"4#member kita":     {},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong messages after forgetting (+got/-want):\n%s", diff)
+		}
+		got = speak(ctx, t, br, "sickhack", "", 2048)
+		want = map[string]struct{}{
+			"7#member nijika":   {},
+			"7 8#member nijika": {},
+			"7 8#member kita":   {},
+			"8#member kita":     {},
+			"9#manager seika":   {},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong messages in other tag after forgetting (+got/-want):\n%s", diff)
+		}
+	}
+}
+
+// testSeed tests that a brain speaks deterministically given a seed.
+func testSeed(ctx context.Context, br brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		learn(ctx, t, br)
+		got := make(map[string]struct{})
+		for seed := range uint64(64) {
+			a, atr, err := brain.Speak(brain.WithSeed(ctx, seed), br, "kessoku", "")
+			if err != nil {
+				t.Errorf("couldn't speak with seed %d: %v", seed, err)
+			}
+			b, btr, err := brain.Speak(brain.WithSeed(ctx, seed), br, "kessoku", "")
+			if err != nil {
+				t.Errorf("couldn't speak again with seed %d: %v", seed, err)
+			}
+			if a != b || !slices.Equal(atr, btr) {
+				t.Errorf("seed %d gave different messages: %q %q then %q %q", seed, a, atr, b, btr)
+			}
+			got[a] = struct{}{}
+		}
+		if len(got) < 2 {
+			t.Errorf("all seeds gave the same message: %q", got)
+		}
+		// Long messages which share terms in the middle make generation
+		// shorten its context and choose among many branches of knowledge.
+		for i := range 8 {
+			toks := []string{fmt.Sprintf("x%d ", i), "bocchi ", "ryou ", "nijika ", "kita ", fmt.Sprintf("y%d ", i)}
+			err := brain.Learn(ctx, br, "seed", fmt.Sprintf("seed%d", i), userhash.Hash{}, time.Unix(int64(i), 0), toks)
+			if err != nil {
+				t.Fatalf("couldn't learn: %v", err)
+			}
+		}
+		for seed := range uint64(64) {
+			a, atr, err := brain.Speak(brain.WithSeed(ctx, seed), br, "seed", "")
+			if err != nil {
+				t.Errorf("couldn't speak with seed %d: %v", seed, err)
+			}
+			b, btr, err := brain.Speak(brain.WithSeed(ctx, seed), br, "seed", "")
+			if err != nil {
+				t.Errorf("couldn't speak again with seed %d: %v", seed, err)
+			}
+			if a != b || !slices.Equal(atr, btr) {
+				t.Errorf("seed %d gave different long messages: %q %q then %q %q", seed, a, atr, b, btr)
+			}
+		}
+	}
+}
+
+// testConcurrent tests that a brain can learn, speak, and forget from many
+// goroutines at once, and that forgotten messages stay forgotten.
+func testConcurrent(ctx context.Context, br brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		const workers, msgs = 8, 24
+		var (
+			wg     sync.WaitGroup
+			mu     sync.Mutex
+			forgot = make(map[string]bool)
+		)
+		for g := range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				u := userhash.Hash{0xc0, byte(g)}
+				for i := range msgs {
+					id := fmt.Sprintf("%d.%d", g, i)
+					toks := []string{fmt.Sprintf("w%d ", g), fmt.Sprintf("m%d ", i)}
+					if err := brain.Learn(ctx, br, "concurrent", id, u, time.Unix(int64(i), 0), toks); err != nil {
+						t.Errorf("couldn't learn %s: %v", id, err)
+					}
+					if i%3 == 2 {
+						// Forget the previous message while others learn.
+						id := fmt.Sprintf("%d.%d", g, i-1)
+						mu.Lock()
+						forgot[id] = true
+						mu.Unlock()
+						if err := br.ForgetMessage(ctx, "concurrent", id); err != nil {
+							t.Errorf("couldn't forget %s: %v", id, err)
+						}
+					}
+					if _, _, err := brain.Speak(ctx, br, "concurrent", ""); err != nil {
+						t.Errorf("couldn't speak: %v", err)
+					}
+				}
+				if g != 0 {
+					return
+				}
+				// The first worker forgets itself entirely while the others
+				// are still going.
+				mu.Lock()
+				for i := range msgs {
+					forgot[fmt.Sprintf("%d.%d", g, i)] = true
+				}
+				mu.Unlock()
+				if err := br.ForgetUser(ctx, &u); err != nil {
+					t.Errorf("couldn't forget user: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+		for range 512 {
+			s, trace, err := brain.Speak(ctx, br, "concurrent", "")
+			if err != nil {
+				t.Errorf("couldn't speak: %v", err)
+			}
+			if s == "" {
+				t.Errorf("spoke nothing")
+			}
+			for _, id := range trace {
+				if forgot[id] {
+					t.Errorf("spoke forgotten message %s in %q", id, s)
+				}
+			}
+		}
+	}
+}
+
+// testExpire tests that a brain which is a [brain.Expirer] can expire messages.
+func testExpire(ctx context.Context, br brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		ex, ok := br.(brain.Expirer)
+		if !ok {
+			t.Skip("brain is not an Expirer")
+		}
+		learn(ctx, t, br)
+		n, err := ex.Expire(ctx, "kessoku", time.Unix(2, 0))
+		if err != nil {
+			t.Errorf("failed to expire: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("wrong number of expired messages: want 2, got %d", n)
+		}
+		got := speak(ctx, t, br, "kessoku", "", 2048)
+		want := map[string]struct{}{
+			"3#member nijika":   {},
+			"3 4#member nijika": {},
+			"3 4#member kita":   {},
+			"4#member kita":     {},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong messages after expiring (+got/-want):\n%s", diff)
+		}
+		got = speak(ctx, t, br, "sickhack", "", 2048)
+		want = map[string]struct{}{
+			"5#member bocchi":   {},
+			"5 6#member bocchi": {},
+			"5 7#member bocchi": {},
+			"5 8#member bocchi": {},
+			"5 6#member ryou":   {},
+			"6#member ryou":     {},
+			"6 7#member ryou":   {},
+			"6 8#member ryou":   {},
+			"5 7#member nijika": {},
+			"6 7#member nijika": {},
+			"7#member nijika":   {},
+			"7 8#member nijika": {},
+			"5 8#member kita":   {},
+			"6 8#member kita":   {},
+			"7 8#member kita":   {},
+			"8#member kita":     {},
+			"9#manager seika":   {},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong spoken messages for sickhack (+got/-want):\n%s", diff)
+		}
+	}
+}
+
+// recorder is a [brain.Learner] which records the messages it learns.
+type recorder struct {
+	msgs []brain.Message
+}
+
+func (r *recorder) Learn(ctx context.Context, tag, id string, user userhash.Hash, t time.Time, tuples []brain.Tuple) error {
+	m := brain.Message{Tag: tag, ID: id, User: user, Time: t}
+	for _, tt := range tuples {
+		m.Tuples = append(m.Tuples, brain.Tuple{Prefix: slices.Clone(tt.Prefix), Suffix: tt.Suffix})
+	}
+	r.msgs = append(r.msgs, m)
+	return nil
+}
+
+func (r *recorder) ForgetMessage(ctx context.Context, tag, id string) error { return nil }
+
+func (r *recorder) ForgetDuring(ctx context.Context, tag string, since, before time.Time) error {
+	return nil
+}
+
+func (r *recorder) ForgetUser(ctx context.Context, user *userhash.Hash) error { return nil }
+
+// exported collects the messages a brain exports from a tag.
+func exported(ctx context.Context, t *testing.T, ex brain.Exporter, tag string) []brain.Message {
+	t.Helper()
+	var r []brain.Message
+	for m, err := range ex.Messages(ctx, tag, false) {
+		if err != nil {
+			t.Fatalf("couldn't get messages: %v", err)
+		}
+		r = append(r, *m)
+	}
+	return r
+}
+
+// testExport tests that a brain which is a [brain.Exporter] can list the
+// messages it has learned, and that they can be imported into another brain.
+func testExport(ctx context.Context, br, dst brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		ex, ok := br.(brain.Exporter)
+		if !ok {
+			t.Skip("brain is not an Exporter")
+		}
+		learn(ctx, t, br)
+		if err := br.ForgetMessage(ctx, "kessoku", messages[0].ID); err != nil {
+			t.Errorf("failed to forget first message: %v", err)
+		}
+		var want recorder
+		for _, m := range messages[1:4] {
+			if err := brain.Learn(ctx, &want, m.Tag, m.ID, m.User, m.Time, m.Tokens()); err != nil {
+				t.Fatalf("couldn't record message %v: %v", m.ID, err)
+			}
+		}
+		got := exported(ctx, t, ex, "kessoku")
+		if diff := cmp.Diff(want.msgs, got); diff != "" {
+			t.Errorf("wrong exported messages (+got/-want):\n%s", diff)
+		}
+		var b bytes.Buffer
+		n, err := brain.Export(ctx, &b, ex, []string{"kessoku", "sickhack"}, false)
+		if err != nil {
+			t.Errorf("couldn't export: %v", err)
+		}
+		if n != 8 {
+			t.Errorf("wrong number of exported messages: want 8, got %d", n)
+		}
+		n, err = brain.Import(ctx, dst, &b)
+		if err != nil {
+			t.Errorf("couldn't import: %v", err)
+		}
+		if n != 8 {
+			t.Errorf("wrong number of imported messages: want 8, got %d", n)
+		}
+		spoke := speak(ctx, t, dst, "sickhack", "manager", 32)
+		if diff := cmp.Diff(map[string]struct{}{"9#manager seika": {}}, spoke); diff != "" {
+			t.Errorf("wrong prompted messages after import (+got/-want):\n%s", diff)
+		}
+		if imp, ok := dst.(brain.Exporter); ok {
+			for _, tag := range []string{"kessoku", "sickhack"} {
+				want := exported(ctx, t, ex, tag)
+				got := exported(ctx, t, imp, tag)
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Errorf("wrong messages in %s after import (+got/-want):\n%s", tag, diff)
+				}
+			}
+		}
+	}
+}
+
+// testStats tests that a brain which is a [brain.Statter] can summarize its
+// knowledge.
+func testStats(ctx context.Context, br brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		st, ok := br.(brain.Statter)
+		if !ok {
+			t.Skip("brain is not a Statter")
+		}
+		got, err := st.Stats(ctx, "kessoku")
+		if err != nil {
+			t.Errorf("couldn't get stats for empty brain: %v", err)
+		}
+		if diff := cmp.Diff(&brain.Stats{}, got); diff != "" {
+			t.Errorf("wrong stats for empty brain (+got/-want):\n%s", diff)
+		}
+		learn(ctx, t, br)
+		if err := br.ForgetMessage(ctx, "kessoku", messages[0].ID); err != nil {
+			t.Errorf("failed to forget first message: %v", err)
+		}
+		got, err = st.Stats(ctx, "kessoku")
+		if err != nil {
+			t.Errorf("couldn't get stats: %v", err)
+		}
+		want := &brain.Stats{
+			Messages: 3,
+			Deleted:  1,
+			Tuples:   9,
+			// "ryou member", "nijika member", "kita member", "member", and
+			// the start of the message.
+			Prefixes: 5,
+			Starts:   3,
+			Oldest:   time.Unix(1, 0),
+			Newest:   time.Unix(3, 0),
+		}
+		if got != nil && got.Deleted == 0 {
+			// Brains may drop forgotten messages entirely instead of
+			// recording them as deleted.
+			want.Deleted = 0
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong stats for kessoku (+got/-want):\n%s", diff)
+		}
+		got, err = st.Stats(ctx, "sickhack")
+		if err != nil {
+			t.Errorf("couldn't get stats: %v", err)
+		}
+		want = &brain.Stats{
+			Messages: 5,
+			Tuples:   15,
+			Prefixes: 8,
+			Starts:   5,
+			Oldest:   time.Unix(0, 0),
+			Newest:   time.Unix(43, 0),
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong stats for sickhack (+got/-want):\n%s", diff)
+		}
+	}
+}
+
+// testTags tests that a brain which is a [brain.Tagger] can list, copy,
+// rename, and drop tags.
+func testTags(ctx context.Context, br brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		tg, ok := br.(brain.Tagger)
+		if !ok {
+			t.Skip("brain is not a Tagger")
+		}
+		tags := func(want ...string) {
+			t.Helper()
+			got, err := tg.Tags(ctx)
+			if err != nil {
+				t.Errorf("couldn't list tags: %v", err)
+			}
+			if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("wrong tags (+got/-want):\n%s", diff)
+			}
+		}
+		tags()
+		learn(ctx, t, br)
+		tags("kessoku", "sickhack")
+		if err := br.ForgetMessage(ctx, "kessoku", messages[0].ID); err != nil {
+			t.Errorf("failed to forget first message: %v", err)
+		}
+		if err := tg.CopyTag(ctx, "kessoku", "kessoku"); err == nil {
+			t.Errorf("copying a tag to itself should fail")
+		}
+		if err := tg.CopyTag(ctx, "kessoku", "bocchi"); err != nil {
+			t.Errorf("couldn't copy tag: %v", err)
+		}
+		tags("bocchi", "kessoku", "sickhack")
+		// Copying again must not duplicate anything.
+		if err := tg.CopyTag(ctx, "kessoku", "bocchi"); err != nil {
+			t.Errorf("couldn't copy tag again: %v", err)
+		}
+		want := map[string]struct{}{
+			"2#member ryou":     {},
+			"2 3#member ryou":   {},
+			"2 4#member ryou":   {},
+			"2 3#member nijika": {},
+			"3#member nijika":   {},
+			"3 4#member nijika": {},
+			"2 4#member kita":   {},
+			"3 4#member kita":   {},
+			"4#member kita":     {},
+		}
+		got := speak(ctx, t, br, "bocchi", "", 2048)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong messages in copied tag (+got/-want):\n%s", diff)
+		}
+		// The copy must be independent of the original.
+		if err := br.ForgetMessage(ctx, "bocchi", messages[1].ID); err != nil {
+			t.Errorf("failed to forget message in copy: %v", err)
+		}
+		got = speak(ctx, t, br, "kessoku", "", 2048)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong messages in original tag after forgetting in copy (+got/-want):\n%s", diff)
+		}
+		if err := tg.RenameTag(ctx, "sickhack", "seika"); err != nil {
+			t.Errorf("couldn't rename tag: %v", err)
+		}
+		tags("bocchi", "kessoku", "seika")
+		got = speak(ctx, t, br, "sickhack", "", 32)
+		if diff := cmp.Diff(map[string]struct{}{"#": {}}, got); diff != "" {
+			t.Errorf("wrong messages in renamed tag (+got/-want):\n%s", diff)
+		}
+		got = speak(ctx, t, br, "seika", "manager", 32)
+		if diff := cmp.Diff(map[string]struct{}{"9#manager seika": {}}, got); diff != "" {
+			t.Errorf("wrong messages in new name (+got/-want):\n%s", diff)
+		}
+		if err := tg.DropTag(ctx, "kessoku"); err != nil {
+			t.Errorf("couldn't drop tag: %v", err)
+		}
+		tags("bocchi", "seika")
+		got = speak(ctx, t, br, "kessoku", "", 32)
+		if diff := cmp.Diff(map[string]struct{}{"#": {}}, got); diff != "" {
+			t.Errorf("wrong messages in dropped tag (+got/-want):\n%s", diff)
+		}
+		got = speak(ctx, t, br, "bocchi", "", 2048)
+		want = map[string]struct{}{
+			"3#member nijika":   {},
+			"3 4#member nijika": {},
+			"3 4#member kita":   {},
+			"4#member kita":     {},
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong messages in copy after dropping original (+got/-want):\n%s", diff)
+		}
+		// Copied and renamed messages must still be forgettable.
+		if err := br.ForgetDuring(ctx, "bocchi", time.Unix(3, 0), time.Unix(3, 0)); err != nil {
+			t.Errorf("failed to forget during in copy: %v", err)
+		}
+		got = speak(ctx, t, br, "bocchi", "", 2048)
+		want = map[string]struct{}{"3#member nijika": {}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong messages in copy after forgetting during (+got/-want):\n%s", diff)
+		}
+		if err := br.ForgetUser(ctx, &userhash.Hash{4}); err != nil {
+			t.Errorf("failed to forget user in renamed tag: %v", err)
+		}
+		got = speak(ctx, t, br, "seika", "manager", 32)
+		if diff := cmp.Diff(map[string]struct{}{"#": {}}, got); diff != "" {
+			t.Errorf("wrong messages in renamed tag after forgetting user (+got/-want):\n%s", diff)
+		}
+		if err := br.ForgetUser(ctx, &userhash.Hash{3}); err != nil {
+			t.Errorf("failed to forget user in copy: %v", err)
+		}
+		got = speak(ctx, t, br, "bocchi", "", 32)
+		if diff := cmp.Diff(map[string]struct{}{"#": {}}, got); diff != "" {
+			t.Errorf("wrong messages in copy after forgetting user (+got/-want):\n%s", diff)
+		}
+	}
+}
+
+// testStream tests that a brain which is a [brain.Streamer] yields terms one
+// at a time and stops when asked.
+func testStream(ctx context.Context, br brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		st, ok := br.(brain.Streamer)
+		if !ok {
+			t.Skip("brain is not a Streamer")
+		}
+		learn(ctx, t, br)
+		var got []brain.Term
+		for term, err := range st.Stream(ctx, "sickhack", []string{"manager "}) {
+			if err != nil {
+				t.Errorf("couldn't stream: %v", err)
+			}
+			got = append(got, term)
+		}
+		want := []brain.Term{{Text: "seika ", ID: "9", Context: 1}}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong streamed terms (+got/-want):\n%s", diff)
+		}
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		for term, err := range st.Stream(canceled, "kessoku", nil) {
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("wrong result streaming with canceled context: %+v, %v", term, err)
+			}
+		}
+		for range 32 {
+			got = got[:0]
+			for term, err := range st.Stream(ctx, "kessoku", nil) {
+				if err != nil {
+					t.Errorf("couldn't stream: %v", err)
+				}
+				got = append(got, term)
+				break
+			}
+			if len(got) == 0 {
+				t.Fatal("streamed nothing")
+			}
+			want := []brain.Term{{Text: "member ", ID: got[0].ID}}
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("wrong first streamed term (+got/-want):\n%s", diff)
+			}
+		}
+		// Stopping early must leave the brain usable.
+		if err := brain.Learn(ctx, br, "kessoku", "5", userhash.Hash{5}, time.Unix(5, 0), []string{"seika "}); err != nil {
+			t.Errorf("couldn't learn after stopping a stream: %v", err)
+		}
+	}
+}
 
 // testCombinatoric tests that chains can generate even with substantial
 // overlap in learned material.
@@ -306,12 +830,13 @@ func testCombinatoric(ctx context.Context, br brain.Brain) func(t *testing.T) {
 				}
 			}
 		}
-		allocs := testing.AllocsPerRun(10, func() {
+		// We used to report allocations here, but AllocsPerRun can't be used
+		// in parallel tests, and brain tests generally want to be parallel.
+		for range 10 {
 			_, _, err := brain.Speak(ctx, br, "bocchi", "")
 			if err != nil {
 				t.Errorf("couldn't speak: %v", err)
 			}
-		})
-		t.Logf("speaking cost %v allocs per run", allocs)
+		}
 	}
 }
@@ -2,7 +2,6 @@ package braintest_test
 
 import (
 	"context"
-	"math/rand/v2"
 	"slices"
 	"strings"
 	"sync"
@@ -118,29 +117,20 @@ func (m *membrain) ForgetUser(ctx context.Context, user *userhash.Hash) error {
 func (m *membrain) Speak(ctx context.Context, tag string, prompt []string, w *brain.Builder) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	var s string
-	if len(prompt) == 0 {
-		u := m.tups[tag][""]
-		if len(u) == 0 {
-			return nil
-		}
-		t := u[rand.IntN(len(u))]
-		w.Append(t[0], []byte(t[1]))
-		s = brain.ReduceEntropy(t[1])
-	} else {
-		s = brain.ReduceEntropy(prompt[len(prompt)-1])
-	}
+	// Search by the entire context. The real brains can drop context, but the
+	// tests are written so that doing so makes no difference.
+	p := slices.Clone(prompt)
 	for range 256 {
-		u := m.tups[tag][s]
+		u := m.tups[tag][strings.Join(p, "\xff")]
 		if len(u) == 0 {
 			break
 		}
-		t := u[rand.IntN(len(u))]
+		t := u[brain.Rand(ctx).IntN(len(u))]
 		if t[1] == "" {
 			break
 		}
-		w.Append(t[0], []byte(t[1]))
-		s = brain.ReduceEntropy(t[1])
+		w.Append(t[0], []byte(t[1]), len(p))
+		p = slices.Insert(p, 0, brain.ReduceEntropy(t[1]))
 	}
 	return nil
 }
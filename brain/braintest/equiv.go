@@ -0,0 +1,360 @@
+package braintest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+// refMessage is a message known to the reference model.
+type refMessage struct {
+	tag  string
+	id   string
+	user userhash.Hash
+	time int64 // seconds
+	toks []string
+	// tuples are the tuples the message owns, as [brain.Learn] creates them.
+	tuples []brain.Tuple
+}
+
+// refModel is a reference model of what a brain should be able to say.
+//
+// Tree corpora for the model are random trees of terms, where each message is
+// a path from the root and every node has a distinct term. Each term therefore
+// identifies all of the context before it, so brains which match different
+// amounts of context must still agree on everything they can say.
+//
+// Other corpora repeat terms freely across and within messages. Brains may
+// then say different things, but every term they say must come from a tuple
+// owned by a message which hasn't been forgotten and whose prefix matches as
+// much context as the brain claims.
+type refModel struct {
+	msgs []refMessage
+}
+
+// refTuples creates the tuples for a message's terms.
+func refTuples(toks []string) []brain.Tuple {
+	pres := make([]string, len(toks))
+	for i, w := range toks {
+		pres[len(toks)-1-i] = brain.ReduceEntropy(w)
+	}
+	r := make([]brain.Tuple, 0, len(toks)+1)
+	for i, w := range toks {
+		r = append(r, brain.Tuple{Prefix: pres[len(toks)-i:], Suffix: w})
+	}
+	return append(r, brain.Tuple{Prefix: pres, Suffix: ""})
+}
+
+// iterations scales the work of randomized tests for -short.
+func iterations(long, short int) int {
+	if testing.Short() {
+		return short
+	}
+	return long
+}
+
+var refTags = []string{"kessoku", "sickhack"}
+
+// randomCorpus creates a random reference model of trees of distinct terms.
+func randomCorpus(rng *rand.Rand) *refModel {
+	var m refModel
+	k := 0
+	for _, tag := range refTags {
+		children := make(map[string][]string)
+		for range 6 {
+			var toks []string
+			for range 1 + rng.IntN(4) {
+				p := strings.Join(toks, "")
+				c := children[p]
+				if len(c) == 0 || rng.IntN(2) == 0 {
+					c = append(c, fmt.Sprintf("%s%d ", tag[:1], k))
+					children[p] = c
+					k++
+				}
+				toks = append(toks, c[rng.IntN(len(c))])
+			}
+			m.msgs = append(m.msgs, refMessage{
+				tag:    tag,
+				id:     fmt.Sprintf("%s-%d", tag, len(m.msgs)),
+				user:   userhash.Hash{byte(1 + rng.IntN(3))},
+				time:   int64(rng.IntN(8)),
+				toks:   toks,
+				tuples: refTuples(toks),
+			})
+		}
+	}
+	return &m
+}
+
+// refTerms is the vocabulary of repetitive corpora.
+var refTerms = []string{"bocchi ", "ryou ", "nijika ", "kita ", "Bocchi "}
+
+// repeatCorpus creates a random reference model whose messages share terms.
+func repeatCorpus(rng *rand.Rand) *refModel {
+	var m refModel
+	for _, tag := range refTags {
+		for range 8 {
+			toks := make([]string, 1+rng.IntN(5))
+			for i := range toks {
+				toks[i] = refTerms[rng.IntN(len(refTerms))]
+			}
+			m.msgs = append(m.msgs, refMessage{
+				tag:    tag,
+				id:     fmt.Sprintf("%s-%d", tag, len(m.msgs)),
+				user:   userhash.Hash{byte(1 + rng.IntN(3))},
+				time:   int64(rng.IntN(8)),
+				toks:   toks,
+				tuples: refTuples(toks),
+			})
+		}
+	}
+	return &m
+}
+
+// forget applies a random forget operation to both the model and a brain.
+func (m *refModel) forget(ctx context.Context, t *testing.T, rng *rand.Rand, br brain.Brain) {
+	t.Helper()
+	switch rng.IntN(3) {
+	case 0:
+		if len(m.msgs) == 0 {
+			return
+		}
+		msg := m.msgs[rng.IntN(len(m.msgs))]
+		t.Logf("forget message %s in %s", msg.id, msg.tag)
+		if err := br.ForgetMessage(ctx, msg.tag, msg.id); err != nil {
+			t.Errorf("couldn't forget message %s: %v", msg.id, err)
+		}
+		m.msgs = slices.DeleteFunc(m.msgs, func(r refMessage) bool { return r.id == msg.id })
+	case 1:
+		tag := refTags[rng.IntN(len(refTags))]
+		s := int64(rng.IntN(8))
+		t.Logf("forget during %d to %d in %s", s, s+1, tag)
+		// Keep clear of the boundaries so that inclusivity doesn't matter.
+		since := time.Unix(s, 0).Add(-time.Second / 2)
+		before := time.Unix(s+1, 0).Add(time.Second / 2)
+		if err := br.ForgetDuring(ctx, tag, since, before); err != nil {
+			t.Errorf("couldn't forget during %v to %v: %v", since, before, err)
+		}
+		m.msgs = slices.DeleteFunc(m.msgs, func(r refMessage) bool { return r.tag == tag && r.time >= s && r.time <= s+1 })
+	case 2:
+		u := userhash.Hash{byte(1 + rng.IntN(3))}
+		t.Logf("forget user %x", u[0])
+		if err := br.ForgetUser(ctx, &u); err != nil {
+			t.Errorf("couldn't forget user: %v", err)
+		}
+		m.msgs = slices.DeleteFunc(m.msgs, func(r refMessage) bool { return r.user == u })
+	}
+}
+
+// texts returns the set of messages the model can say in a tag.
+func (m *refModel) texts(tag string) map[string]struct{} {
+	r := make(map[string]struct{})
+	for _, msg := range m.msgs {
+		if msg.tag == tag {
+			r[strings.TrimSpace(strings.Join(msg.toks, ""))] = struct{}{}
+		}
+	}
+	if len(r) == 0 {
+		r[""] = struct{}{}
+	}
+	return r
+}
+
+// valid reports whether the model could produce a message with a given trace.
+// Each term of the message must come from a message which starts with every
+// term up to and including it, and every message in the trace must be used.
+func (m *refModel) valid(tag, text string, trace []string) bool {
+	if text == "" {
+		return len(trace) == 0
+	}
+	toks := strings.SplitAfter(text+" ", " ")
+	toks = toks[:len(toks)-1]
+	// For each message in the trace, find how many terms of the text it can
+	// have contributed.
+	depth := make([]int, len(trace))
+	for i, id := range trace {
+		k := slices.IndexFunc(m.msgs, func(r refMessage) bool { return r.tag == tag && r.id == id })
+		if k < 0 {
+			return false
+		}
+		u := m.msgs[k].toks
+		for depth[i] < len(u) && depth[i] < len(toks) && u[depth[i]] == toks[depth[i]] {
+			depth[i]++
+		}
+	}
+	// Try every assignment of trace messages to terms. Messages are short, so
+	// there aren't many.
+	pick := make([]int, len(toks))
+	for {
+		ok := true
+		used := make([]bool, len(trace))
+		for k, i := range pick {
+			if depth[i] <= k {
+				ok = false
+				break
+			}
+			used[i] = true
+		}
+		if ok && !slices.Contains(used, false) {
+			return true
+		}
+		// Advance to the next assignment.
+		k := 0
+		for k < len(pick) {
+			pick[k]++
+			if pick[k] < len(trace) {
+				break
+			}
+			pick[k] = 0
+			k++
+		}
+		if k == len(pick) {
+			return false
+		}
+	}
+}
+
+// owned reports whether a message's spans could have come from the tuples of
+// the messages in the model. Each term must be the suffix of a tuple owned by
+// the message its span names, and the tuple's prefix must match the number of
+// preceding terms the span claims. The first term must start a message.
+func (m *refModel) owned(tag, text string, spans []brain.Span) bool {
+	prev := make([]string, 0, len(spans))
+	for k, sp := range spans {
+		if sp.Context > k {
+			return false
+		}
+		i := slices.IndexFunc(m.msgs, func(r refMessage) bool { return r.tag == tag && r.id == sp.ID })
+		if i < 0 {
+			return false
+		}
+		term := strings.TrimSpace(text[sp.Start:sp.End])
+		ok := slices.ContainsFunc(m.msgs[i].tuples, func(tt brain.Tuple) bool {
+			if strings.TrimSpace(tt.Suffix) != term || len(tt.Prefix) < sp.Context {
+				return false
+			}
+			if k == 0 && len(tt.Prefix) != 0 {
+				return false
+			}
+			for j := range sp.Context {
+				if strings.TrimSpace(tt.Prefix[j]) != prev[len(prev)-1-j] {
+					return false
+				}
+			}
+			return true
+		})
+		if !ok {
+			return false
+		}
+		prev = append(prev, brain.ReduceEntropy(term))
+	}
+	return true
+}
+
+// checkOwned checks that everything a brain says comes from the model.
+func (m *refModel) checkOwned(ctx context.Context, t *testing.T, br brain.Speaker) {
+	t.Helper()
+	accept := func(string) bool { return true }
+	for _, tag := range refTags {
+		empty := !slices.ContainsFunc(m.msgs, func(r refMessage) bool { return r.tag == tag })
+		for range iterations(512, 128) {
+			s, _, spans, err := brain.SpeakCheck(ctx, br, tag, "", accept)
+			if err != nil {
+				t.Errorf("couldn't speak: %v", err)
+			}
+			if empty && s != "" {
+				t.Errorf("spoke %q from forgotten messages in %s", s, tag)
+			}
+			if !m.owned(tag, s, spans) {
+				t.Errorf("unowned spans %+v for %q in %s", spans, s, tag)
+			}
+		}
+	}
+}
+
+// check compares what a brain says against the model.
+func (m *refModel) check(ctx context.Context, t *testing.T, br brain.Speaker) {
+	t.Helper()
+	for _, tag := range refTags {
+		got := make(map[string]struct{})
+		for range iterations(1024, 512) {
+			s, trace, err := brain.Speak(ctx, br, tag, "")
+			if err != nil {
+				t.Errorf("couldn't speak: %v", err)
+			}
+			got[s] = struct{}{}
+			if !m.valid(tag, s, trace) {
+				t.Errorf("impossible trace %q for %q in %s", trace, s, tag)
+			}
+		}
+		if diff := cmp.Diff(m.texts(tag), got); diff != "" {
+			t.Errorf("wrong messages in %s (+got/-want):\n%s", tag, diff)
+		}
+	}
+}
+
+// testEquivalence tests that brains say exactly what a reference model says
+// after learning random corpora and forgetting randomly from them.
+func testEquivalence(ctx context.Context, new func(context.Context) brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		seed := rand.Uint64()
+		t.Logf("seed: %#x", seed)
+		rng := rand.New(rand.NewPCG(seed, seed))
+		for range iterations(8, 2) {
+			br := new(ctx)
+			m := randomCorpus(rng)
+			for _, msg := range m.msgs {
+				err := brain.Learn(ctx, br, msg.tag, msg.id, msg.user, time.Unix(msg.time, 0), slices.Clone(msg.toks))
+				if err != nil {
+					t.Fatalf("couldn't learn %s: %v", msg.id, err)
+				}
+			}
+			m.check(ctx, t, br)
+			for range 3 {
+				m.forget(ctx, t, rng, br)
+				m.check(ctx, t, br)
+			}
+			if t.Failed() {
+				return
+			}
+		}
+	}
+}
+
+// testOwnership tests that everything brains say after learning random
+// corpora with repeated terms, and after forgetting randomly from them, comes
+// from tuples of messages which remain.
+func testOwnership(ctx context.Context, new func(context.Context) brain.Brain) func(t *testing.T) {
+	return func(t *testing.T) {
+		seed := rand.Uint64()
+		t.Logf("seed: %#x", seed)
+		rng := rand.New(rand.NewPCG(seed, seed))
+		for range iterations(8, 2) {
+			br := new(ctx)
+			m := repeatCorpus(rng)
+			for _, msg := range m.msgs {
+				err := brain.Learn(ctx, br, msg.tag, msg.id, msg.user, time.Unix(msg.time, 0), slices.Clone(msg.toks))
+				if err != nil {
+					t.Fatalf("couldn't learn %s: %v", msg.id, err)
+				}
+			}
+			m.checkOwned(ctx, t, br)
+			for range 3 {
+				m.forget(ctx, t, rng, br)
+				m.checkOwned(ctx, t, br)
+			}
+			if t.Failed() {
+				return
+			}
+		}
+	}
+}
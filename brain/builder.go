@@ -4,13 +4,28 @@ import "slices"
 
 // Builder builds a spoken message along with its message trace.
 type Builder struct {
-	w  []byte
-	id []string
+	w     []byte
+	id    []string
+	spans []Span
+}
+
+// Span records the provenance of one term of a built message.
+type Span struct {
+	// ID is the ID of the message from which the term was learned.
+	ID string
+	// Start and End are the byte offsets of the term in the built message.
+	Start, End int
+	// Context is the number of terms of the message before the term which
+	// matched when it was chosen.
+	Context int
 }
 
 // Append adds a term to the builder.
-func (b *Builder) Append(id string, term []byte) {
+// context is the number of preceding terms which matched to choose it.
+func (b *Builder) Append(id string, term []byte, context int) {
+	start := len(b.w)
 	b.w = append(b.w, term...)
+	b.spans = append(b.spans, Span{ID: id, Start: start, End: len(b.w), Context: context})
 	k, ok := slices.BinarySearch(b.id, id)
 	if !ok {
 		b.id = slices.Insert(b.id, k, id)
@@ -42,9 +57,17 @@ func (b *Builder) Trace() []string {
 	return b.id
 }
 
+// Spans returns a direct reference to the spans of the terms appended to the
+// builder, in the order they were appended.
+func (b *Builder) Spans() []Span {
+	return b.spans
+}
+
 // Reset restores the builder to an empty state.
 func (b *Builder) Reset() {
 	b.w = b.w[:0]
 	clear(b.id) // allow held strings to release
 	b.id = b.id[:0]
+	clear(b.spans)
+	b.spans = b.spans[:0]
 }
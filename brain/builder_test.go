@@ -15,6 +15,7 @@ func TestBuilder(t *testing.T) {
 		terms [][2]string
 		want  string
 		trace []string
+		spans []brain.Span
 	}{
 		{
 			name:  "empty",
@@ -31,6 +32,9 @@ func TestBuilder(t *testing.T) {
 			trace: []string{
 				"bocchi",
 			},
+			spans: []brain.Span{
+				{ID: "bocchi", Start: 0, End: 3, Context: 0},
+			},
 		},
 		{
 			name: "multi",
@@ -43,6 +47,10 @@ func TestBuilder(t *testing.T) {
 				"bocchi",
 				"nijika",
 			},
+			spans: []brain.Span{
+				{ID: "bocchi", Start: 0, End: 3, Context: 0},
+				{ID: "nijika", Start: 3, End: 7, Context: 1},
+			},
 		},
 		{
 			name: "order",
@@ -55,6 +63,10 @@ func TestBuilder(t *testing.T) {
 				"bocchi",
 				"nijika",
 			},
+			spans: []brain.Span{
+				{ID: "nijika", Start: 0, End: 3, Context: 0},
+				{ID: "bocchi", Start: 3, End: 7, Context: 1},
+			},
 		},
 		{
 			name: "dedup",
@@ -66,13 +78,17 @@ func TestBuilder(t *testing.T) {
 			trace: []string{
 				"bocchi",
 			},
+			spans: []brain.Span{
+				{ID: "bocchi", Start: 0, End: 3, Context: 0},
+				{ID: "bocchi", Start: 3, End: 7, Context: 1},
+			},
 		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
 			var b brain.Builder
-			for _, t := range c.terms {
-				b.Append(t[0], []byte(t[1]))
+			for i, t := range c.terms {
+				b.Append(t[0], []byte(t[1]), i)
 			}
 			got := b.String()
 			trace := b.Trace()
@@ -82,6 +98,9 @@ func TestBuilder(t *testing.T) {
 			if !slices.Equal(trace, c.trace) {
 				t.Errorf("wrong trace: want %q, got %q", c.trace, trace)
 			}
+			if !slices.Equal(b.Spans(), c.spans) {
+				t.Errorf("wrong spans: want %+v, got %+v", c.spans, b.Spans())
+			}
 			b.Reset()
 			got = b.String()
 			trace = b.Trace()
@@ -91,6 +110,9 @@ func TestBuilder(t *testing.T) {
 			if len(trace) != 0 {
 				t.Errorf("trace %q not empty after reset", trace)
 			}
+			if len(b.Spans()) != 0 {
+				t.Errorf("spans %+v not empty after reset", b.Spans())
+			}
 		})
 	}
 }
@@ -108,9 +130,9 @@ func BenchmarkBuilder(b *testing.B) {
 	for range b.N {
 		m.Reset()
 		u := rand.Uint64()
-		m.Append(ids[byte(u>>0)], words[byte(u>>8)])
-		m.Append(ids[byte(u>>16)], words[byte(u>>24)])
-		m.Append(ids[byte(u>>32)], words[byte(u>>40)])
-		m.Append(ids[byte(u>>48)], words[byte(u>>56)])
+		m.Append(ids[byte(u>>0)], words[byte(u>>8)], 0)
+		m.Append(ids[byte(u>>16)], words[byte(u>>24)], 0)
+		m.Append(ids[byte(u>>32)], words[byte(u>>40)], 0)
+		m.Append(ids[byte(u>>48)], words[byte(u>>56)], 0)
 	}
 }
@@ -0,0 +1,184 @@
+package brain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+// Message is a single message learned by a brain, along with the tuples
+// learned from it.
+type Message struct {
+	// Tag is the tag in which the message was learned.
+	Tag string
+	// ID is the message ID.
+	ID string
+	// User is the sender's userhash.
+	User userhash.Hash
+	// Time is the time at which the message was learned.
+	// It may be the zero time for messages imported from other sources.
+	Time time.Time
+	// Tuples are the tuples learned from the message, in the order they were
+	// passed to [Learner.Learn].
+	Tuples []Tuple
+	// Deleted is the reason the message was deleted, or the empty string if
+	// it has not been.
+	Deleted string
+}
+
+// Exporter is a [Learner] which can list the messages it has learned.
+type Exporter interface {
+	// Messages iterates over the messages learned in tag.
+	// Deleted messages are included only if deleted is true. They have no
+	// tuples if the exporter no longer has them.
+	// Iteration stops after the first error.
+	Messages(ctx context.Context, tag string, deleted bool) iter.Seq2[*Message, error]
+}
+
+// ExportVersion is the version of the export format written by [Export].
+const ExportVersion = 1
+
+// exportHeader is the first record in an export.
+type exportHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+// exportFormat is the value of the format field of the export header.
+const exportFormat = "robot-brain"
+
+// exportMessage is the record for a single message in an export.
+type exportMessage struct {
+	Tag     string        `json:"tag"`
+	ID      string        `json:"id"`
+	Time    time.Time     `json:"time"`
+	User    userhash.Hash `json:"user"`
+	Tuples  []exportTuple `json:"tuples"`
+	Deleted string        `json:"deleted,omitempty"`
+}
+
+// exportTuple is the record for a single tuple in an export.
+type exportTuple struct {
+	Prefix []string `json:"prefix"`
+	Suffix string   `json:"suffix"`
+}
+
+/*
+Export writes the messages learned in each of tags to w.
+
+The export format is JSON Lines. The first line is a header:
+
+	{"format":"robot-brain","version":1}
+
+Each following line is a single message:
+
+	{"tag":"bocchi","id":"1","time":"2024-01-01T00:00:00Z","user":"<base64>","tuples":[...]}
+
+time is in RFC 3339 format, and user is the base64 encoding of the userhash.
+tuples is the list of tuples learned from the message, in order, each an object
+with a prefix field holding the entropy-reduced prefix terms in reverse order
+and a suffix field holding the full-entropy suffix.
+Deleted messages additionally have a deleted field giving the reason. Their
+tuples may be empty if the exporter no longer has them.
+
+The result is the number of messages written.
+*/
+func Export(ctx context.Context, w io.Writer, e Exporter, tags []string, deleted bool) (int64, error) {
+	enc := jsontext.NewEncoder(w)
+	if err := json.MarshalEncode(enc, exportHeader{Format: exportFormat, Version: ExportVersion}); err != nil {
+		return 0, fmt.Errorf("couldn't write export header: %w", err)
+	}
+	var n int64
+	var r exportMessage
+	for _, tag := range tags {
+		for m, err := range e.Messages(ctx, tag, deleted) {
+			if err != nil {
+				return n, fmt.Errorf("couldn't get messages in %s: %w", tag, err)
+			}
+			r = exportMessage{
+				Tag:     m.Tag,
+				ID:      m.ID,
+				Time:    m.Time,
+				User:    m.User,
+				Tuples:  r.Tuples[:0],
+				Deleted: m.Deleted,
+			}
+			for _, t := range m.Tuples {
+				r.Tuples = append(r.Tuples, exportTuple{Prefix: t.Prefix, Suffix: t.Suffix})
+			}
+			if err := json.MarshalEncode(enc, &r); err != nil {
+				return n, fmt.Errorf("couldn't write message %s in %s: %w", m.ID, tag, err)
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Import learns messages from an export written by [Export].
+// Deleted messages in the export are learned and then forgotten, or only
+// forgotten if the export has no tuples for them.
+// The result is the number of messages learned.
+func Import(ctx context.Context, l Learner, r io.Reader) (int64, error) {
+	dec := jsontext.NewDecoder(r)
+	var h exportHeader
+	if err := json.UnmarshalDecode(dec, &h); err != nil {
+		return 0, fmt.Errorf("couldn't read export header: %w", err)
+	}
+	if h.Format != exportFormat {
+		return 0, fmt.Errorf("not a brain export: format is %q", h.Format)
+	}
+	if h.Version < 1 || h.Version > ExportVersion {
+		return 0, fmt.Errorf("unsupported export version %d", h.Version)
+	}
+	var n int64
+	var tuples []Tuple
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		var m exportMessage
+		err := json.UnmarshalDecode(dec, &m)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("couldn't read message %d: %w", n+1, err)
+		}
+		if len(m.Tuples) == 0 && m.Deleted != "" {
+			if err := l.ForgetMessage(ctx, m.Tag, m.ID); err != nil {
+				return n, fmt.Errorf("couldn't forget deleted message %s in %s: %w", m.ID, m.Tag, err)
+			}
+			n++
+			continue
+		}
+		if len(m.Tuples) == 0 {
+			return n, fmt.Errorf("message %s in %s has no tuples", m.ID, m.Tag)
+		}
+		tuples = tuples[:0]
+		for _, t := range m.Tuples {
+			// The start of the message has a nil prefix.
+			if len(t.Prefix) == 0 {
+				t.Prefix = nil
+			}
+			tuples = append(tuples, Tuple{Prefix: t.Prefix, Suffix: t.Suffix})
+		}
+		if err := l.Learn(ctx, m.Tag, m.ID, m.User, m.Time, tuples); err != nil {
+			return n, fmt.Errorf("couldn't learn message %s in %s: %w", m.ID, m.Tag, err)
+		}
+		if m.Deleted != "" {
+			if err := l.ForgetMessage(ctx, m.Tag, m.ID); err != nil {
+				return n, fmt.Errorf("couldn't forget deleted message %s in %s: %w", m.ID, m.Tag, err)
+			}
+		}
+		n++
+	}
+}
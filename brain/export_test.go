@@ -0,0 +1,174 @@
+package brain_test
+
+import (
+	"bytes"
+	"context"
+	"iter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+type testExporter []brain.Message
+
+func (e testExporter) Messages(ctx context.Context, tag string, deleted bool) iter.Seq2[*brain.Message, error] {
+	return func(yield func(*brain.Message, error) bool) {
+		for i := range e {
+			m := &e[i]
+			if m.Tag != tag || m.Deleted != "" && !deleted {
+				continue
+			}
+			if !yield(m, nil) {
+				return
+			}
+		}
+	}
+}
+
+type importLearner struct {
+	testLearner
+	msgs   []brain.Message
+	forgot []string
+}
+
+func (l *importLearner) Learn(ctx context.Context, tag, id string, user userhash.Hash, tm time.Time, tuples []brain.Tuple) error {
+	l.msgs = append(l.msgs, brain.Message{Tag: tag, ID: id, User: user, Time: tm, Tuples: append([]brain.Tuple(nil), tuples...)})
+	return nil
+}
+
+func (l *importLearner) ForgetMessage(ctx context.Context, tag, id string) error {
+	l.forgot = append(l.forgot, tag+"/"+id)
+	return nil
+}
+
+func TestExportImport(t *testing.T) {
+	msgs := testExporter{
+		{
+			Tag:    "kessoku",
+			ID:     "1",
+			User:   userhash.Hash{1},
+			Time:   time.Unix(1, 0).UTC(),
+			Tuples: []brain.Tuple{{Prefix: []string{"bocchi "}, Suffix: ""}, {Prefix: nil, Suffix: "Bocchi "}},
+		},
+		{
+			Tag:     "kessoku",
+			ID:      "2",
+			User:    userhash.Hash{2},
+			Time:    time.Unix(2, 0).UTC(),
+			Tuples:  []brain.Tuple{{Prefix: []string{"ryo "}, Suffix: ""}, {Prefix: nil, Suffix: "ryo "}},
+			Deleted: "CLEARMSG",
+		},
+		{
+			Tag:     "kessoku",
+			ID:      "4",
+			Time:    time.Unix(4, 0).UTC(),
+			Deleted: "CLEARMSG",
+		},
+		{
+			Tag:    "sickhack",
+			ID:     "3",
+			User:   userhash.Hash{3},
+			Time:   time.Unix(3, 0).UTC(),
+			Tuples: []brain.Tuple{{Prefix: []string{"kikuri "}, Suffix: ""}, {Prefix: nil, Suffix: "kikuri "}},
+		},
+	}
+	cases := []struct {
+		name    string
+		tags    []string
+		deleted bool
+		want    []brain.Message
+		forgot  []string
+	}{
+		{
+			name: "one",
+			tags: []string{"kessoku"},
+			want: msgs[:1],
+		},
+		{
+			name: "all",
+			tags: []string{"kessoku", "sickhack"},
+			want: []brain.Message{msgs[0], msgs[3]},
+		},
+		{
+			name:    "deleted",
+			tags:    []string{"kessoku"},
+			deleted: true,
+			want:    msgs[:3],
+			forgot:  []string{"kessoku/2", "kessoku/4"},
+		},
+		{
+			name: "none",
+			tags: []string{"anime"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			var b bytes.Buffer
+			n, err := brain.Export(ctx, &b, msgs, c.tags, c.deleted)
+			if err != nil {
+				t.Errorf("couldn't export: %v", err)
+			}
+			if n != int64(len(c.want)) {
+				t.Errorf("wrong number of exported messages: want %d, got %d", len(c.want), n)
+			}
+			if got := strings.Count(b.String(), "\n"); got != len(c.want)+1 {
+				t.Errorf("wrong number of lines: want %d, got %d", len(c.want)+1, got)
+			}
+			var l importLearner
+			n, err = brain.Import(ctx, &l, &b)
+			if err != nil {
+				t.Errorf("couldn't import: %v", err)
+			}
+			if n != int64(len(c.want)) {
+				t.Errorf("wrong number of imported messages: want %d, got %d", len(c.want), n)
+			}
+			// Deletion is recorded by forgetting rather than in learned messages.
+			// Deleted messages without tuples are only forgotten.
+			var want []brain.Message
+			for _, m := range c.want {
+				if len(m.Tuples) == 0 {
+					continue
+				}
+				m.Deleted = ""
+				want = append(want, m)
+			}
+			if diff := cmp.Diff(want, l.msgs); diff != "" {
+				t.Errorf("wrong imported messages (+got/-want):\n%s", diff)
+			}
+			if diff := cmp.Diff(c.forgot, l.forgot); diff != "" {
+				t.Errorf("wrong forgotten messages (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImportBad(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ``},
+		{"format", `{"format":"anime","version":1}`},
+		{"version", `{"format":"robot-brain","version":2}`},
+		{"no-tuples", `{"format":"robot-brain","version":1}` + "\n" + `{"tag":"kessoku","id":"1","time":"2024-01-01T00:00:00Z","user":"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA==","tuples":[]}`},
+		{"garbage", `{"format":"robot-brain","version":1}` + "\nbocchi"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var l importLearner
+			_, err := brain.Import(context.Background(), &l, strings.NewReader(c.in))
+			if err == nil {
+				t.Errorf("import succeeded")
+			}
+			if len(l.msgs) != 0 {
+				t.Errorf("learned messages from bad import: %v", l.msgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package httpbrain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+// Brain is a brain which uses a brain server.
+type Brain struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+var (
+	_ brain.Brain    = (*Brain)(nil)
+	_ brain.Streamer = (*Brain)(nil)
+	_ brain.Expirer  = (*Brain)(nil)
+	_ brain.Statter  = (*Brain)(nil)
+	_ brain.Exporter = (*Brain)(nil)
+	_ brain.Tagger   = (*Brain)(nil)
+)
+
+// RequestTimeout is the time allowed for requests other than streams whose
+// contexts have no deadlines.
+const RequestTimeout = 30 * time.Second
+
+// New creates a brain using the server at base with the given access token.
+// If client is nil, [http.DefaultClient] is used. The client should not have
+// a Timeout, since that would cut off long streams; requests have deadlines
+// from their contexts instead.
+func New(base, token string, client *http.Client) *Brain {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Brain{base: base, token: token, http: client}
+}
+
+// do sends a request to an endpoint and returns the response if it succeeds.
+// The caller must close the response body.
+func (br *Brain) do(ctx context.Context, ep string, req any) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode request: %w", err)
+	}
+	u, err := url.JoinPath(br.base, ep)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't make URL for %s: %w", ep, err)
+	}
+	r, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't make request: %w", err)
+	}
+	r.Header.Set("Authorization", "Bearer "+br.token)
+	r.Header.Set("Content-Type", "application/json")
+	res, err := br.http.Do(r)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't POST %s: %w", ep, err)
+	}
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return res, nil
+	default:
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 2<<10))
+		res.Body.Close()
+		return nil, fmt.Errorf("brain server request to %s failed: %s (%s)", ep, bytes.TrimSpace(b), res.Status)
+	}
+}
+
+// post sends a request to an endpoint and decodes the response into resp.
+// If resp is nil, the response body is ignored.
+// If ctx has no deadline, the request is limited to [RequestTimeout].
+func post[Resp any](ctx context.Context, br *Brain, ep string, req any, resp *Resp) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, RequestTimeout)
+		defer cancel()
+	}
+	res, err := br.do(ctx, ep, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(res.Body, 2<<20))
+	if err != nil {
+		return fmt.Errorf("couldn't read response: %w", err)
+	}
+	if resp == nil {
+		return nil
+	}
+	if err := json.Unmarshal(b, resp); err != nil {
+		return fmt.Errorf("couldn't decode response: %w", err)
+	}
+	return nil
+}
+
+// Learn records a set of tuples.
+func (br *Brain) Learn(ctx context.Context, tag, id string, user userhash.Hash, t time.Time, tuples []brain.Tuple) error {
+	req := learnRequest{
+		Tag:    tag,
+		ID:     id,
+		User:   user,
+		Time:   t,
+		Tuples: make([]tuple, len(tuples)),
+	}
+	for i, t := range tuples {
+		req.Tuples[i] = tuple{Prefix: t.Prefix, Suffix: t.Suffix}
+	}
+	return post[struct{}](ctx, br, "learn", &req, nil)
+}
+
+// Speak generates a full message and appends it to w.
+// The prompt is in reverse order and has entropy reduction applied.
+func (br *Brain) Speak(ctx context.Context, tag string, prompt []string, w *brain.Builder) error {
+	req := speakRequest{Tag: tag, Prompt: prompt, Seed: brain.Rand(ctx).Uint64()}
+	var resp speakResponse
+	if err := post(ctx, br, "speak", &req, &resp); err != nil {
+		return err
+	}
+	for _, sp := range resp.Spans {
+		if sp.Start < 0 || sp.Start > sp.End || sp.End > len(resp.Text) {
+			return fmt.Errorf("brain server returned invalid span %d:%d for %d bytes", sp.Start, sp.End, len(resp.Text))
+		}
+		w.Append(sp.ID, []byte(resp.Text[sp.Start:sp.End]), sp.Context)
+	}
+	return nil
+}
+
+// Stream generates a message, yielding each term as it is chosen.
+// The prompt is in reverse order and has entropy reduction applied.
+func (br *Brain) Stream(ctx context.Context, tag string, prompt []string) iter.Seq2[brain.Term, error] {
+	return func(yield func(brain.Term, error) bool) {
+		// Stopping early needs to stop the server, too.
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		req := speakRequest{Tag: tag, Prompt: prompt, Seed: brain.Rand(ctx).Uint64()}
+		res, err := br.do(ctx, "stream", &req)
+		if err != nil {
+			yield(brain.Term{}, err)
+			return
+		}
+		defer res.Body.Close()
+		dec := jsontext.NewDecoder(res.Body)
+		for {
+			var l streamTerm
+			err := json.UnmarshalDecode(dec, &l)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					// Report the deadline rather than whatever it did to the body.
+					err = ctx.Err()
+				}
+				yield(brain.Term{}, fmt.Errorf("couldn't decode streamed term: %w", err))
+				return
+			}
+			if l.Error != "" {
+				yield(brain.Term{}, fmt.Errorf("brain server stream failed: %s", l.Error))
+				return
+			}
+			if !yield(brain.Term{Text: l.Text, ID: l.ID, Context: l.Context}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ForgetMessage forgets everything learned from a single given message.
+func (br *Brain) ForgetMessage(ctx context.Context, tag, id string) error {
+	req := forgetMessageRequest{Tag: tag, ID: id}
+	return post[struct{}](ctx, br, "forget/message", &req, nil)
+}
+
+// ForgetDuring forgets all messages learned in the given time span.
+func (br *Brain) ForgetDuring(ctx context.Context, tag string, since, before time.Time) error {
+	req := forgetDuringRequest{Tag: tag, Since: since, Before: before}
+	return post[struct{}](ctx, br, "forget/during", &req, nil)
+}
+
+// ForgetUser forgets all messages associated with a userhash.
+func (br *Brain) ForgetUser(ctx context.Context, user *userhash.Hash) error {
+	req := forgetUserRequest{User: *user}
+	return post[struct{}](ctx, br, "forget/user", &req, nil)
+}
+
+// Expire deletes all messages in tag learned before the given time.
+func (br *Brain) Expire(ctx context.Context, tag string, before time.Time) (int64, error) {
+	req := expireRequest{Tag: tag, Before: before}
+	var resp expireResponse
+	if err := post(ctx, br, "expire", &req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+// Stats computes statistics for a tag.
+func (br *Brain) Stats(ctx context.Context, tag string) (*brain.Stats, error) {
+	req := statsRequest{Tag: tag}
+	var resp statsResponse
+	if err := post(ctx, br, "stats", &req, &resp); err != nil {
+		return nil, err
+	}
+	r := brain.Stats{
+		Messages: resp.Messages,
+		Deleted:  resp.Deleted,
+		Tuples:   resp.Tuples,
+		Prefixes: resp.Prefixes,
+		Starts:   resp.Starts,
+		Oldest:   resp.Oldest,
+		Newest:   resp.Newest,
+	}
+	return &r, nil
+}
+
+// Messages iterates over the messages learned in tag.
+func (br *Brain) Messages(ctx context.Context, tag string, deleted bool) iter.Seq2[*brain.Message, error] {
+	return func(yield func(*brain.Message, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		req := messagesRequest{Tag: tag, Deleted: deleted}
+		res, err := br.do(ctx, "messages", &req)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer res.Body.Close()
+		dec := jsontext.NewDecoder(res.Body)
+		for {
+			var l messageLine
+			err := json.UnmarshalDecode(dec, &l)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					err = ctx.Err()
+				}
+				yield(nil, fmt.Errorf("couldn't decode message: %w", err))
+				return
+			}
+			if l.Error != "" {
+				yield(nil, fmt.Errorf("brain server messages failed: %s", l.Error))
+				return
+			}
+			m := brain.Message{
+				Tag:     l.Tag,
+				ID:      l.ID,
+				User:    l.User,
+				Time:    l.Time,
+				Tuples:  make([]brain.Tuple, len(l.Tuples)),
+				Deleted: l.Deleted,
+			}
+			for i, t := range l.Tuples {
+				m.Tuples[i] = brain.Tuple{Prefix: t.Prefix, Suffix: t.Suffix}
+				if len(t.Prefix) == 0 {
+					m.Tuples[i].Prefix = nil
+				}
+			}
+			if !yield(&m, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Tags lists the tags the server knows which the token can access.
+func (br *Brain) Tags(ctx context.Context) ([]string, error) {
+	var resp tagsResponse
+	if err := post(ctx, br, "tags", &struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tags, nil
+}
+
+// CopyTag copies everything the brain knows in one tag into another.
+func (br *Brain) CopyTag(ctx context.Context, from, to string) error {
+	req := copyTagRequest{From: from, To: to}
+	return post[struct{}](ctx, br, "tags/copy", &req, nil)
+}
+
+// RenameTag moves everything the brain knows in one tag into another.
+func (br *Brain) RenameTag(ctx context.Context, from, to string) error {
+	req := copyTagRequest{From: from, To: to}
+	return post[struct{}](ctx, br, "tags/rename", &req, nil)
+}
+
+// DropTag permanently deletes everything the brain knows in a tag.
+func (br *Brain) DropTag(ctx context.Context, tag string) error {
+	req := dropTagRequest{Tag: tag}
+	return post[struct{}](ctx, br, "tags/drop", &req, nil)
+}
@@ -0,0 +1,152 @@
+// Package httpbrain serves a brain over HTTP and provides a brain which uses
+// such a server.
+//
+// All requests are POSTs of a JSON object to an endpoint named for the brain
+// method, with the token in a bearer Authorization header:
+//
+//	POST /learn          {"tag", "id", "user", "time", "tuples": [{"prefix", "suffix"}]}
+//	POST /speak          {"tag", "prompt", "seed"} → {"text", "trace", "spans": [{"id", "start", "end", "context"}]}
+//	POST /stream         {"tag", "prompt", "seed"} → {"id", "text", "context"} or {"error"} per line
+//	POST /forget/message {"tag", "id"}
+//	POST /forget/during  {"tag", "since", "before"}
+//	POST /forget/user    {"user"}
+//	POST /expire         {"tag", "before"} → {"count"}
+//	POST /stats          {"tag"} → {"messages", "deleted", "tuples", "prefixes", "starts", "oldest", "newest"}
+//	POST /messages       {"tag", "deleted"} → {"tag", "id", "user", "time", "tuples", "deleted"} or {"error"} per line
+//	POST /tags           {} → {"tags"}
+//	POST /tags/copy      {"from", "to"}
+//	POST /tags/rename    {"from", "to"}
+//	POST /tags/drop      {"tag"}
+//
+// Endpoints for optional brain interfaces respond with 501 Not Implemented if
+// the served brain doesn't implement them.
+//
+// The seed in speak and stream requests is drawn from the client's random
+// source and seeds the server's choices, so [brain.WithSeed] on the client
+// reproduces messages as it does for any other brain.
+//
+// Each token grants access to a set of tags. Forgetting a user applies to all
+// tags, so any valid token may do it; it only ever removes knowledge, and
+// privacy requests need to be honored everywhere. Listing tags gives only the
+// tags the token can access, and copying or renaming needs access to both tags.
+package httpbrain
+
+import (
+	"time"
+
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+// AllTags is a tag which grants a token access to every tag.
+const AllTags = "*"
+
+type learnRequest struct {
+	Tag    string        `json:"tag"`
+	ID     string        `json:"id"`
+	User   userhash.Hash `json:"user"`
+	Time   time.Time     `json:"time"`
+	Tuples []tuple       `json:"tuples"`
+}
+
+type tuple struct {
+	Prefix []string `json:"prefix"`
+	Suffix string   `json:"suffix"`
+}
+
+type speakRequest struct {
+	Tag    string   `json:"tag"`
+	Prompt []string `json:"prompt"`
+	// Seed seeds the server's random choices so that the client's source
+	// determines the message. Zero means the server chooses.
+	Seed uint64 `json:"seed,omitzero"`
+}
+
+type speakResponse struct {
+	Text  string   `json:"text"`
+	Trace []string `json:"trace"`
+	Spans []span   `json:"spans"`
+}
+
+type span struct {
+	ID      string `json:"id"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Context int    `json:"context"`
+}
+
+// streamTerm is a single line of a streamed generation.
+// Exactly one of Error or the other fields is set.
+type streamTerm struct {
+	ID      string `json:"id,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Context int    `json:"context,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type forgetMessageRequest struct {
+	Tag string `json:"tag"`
+	ID  string `json:"id"`
+}
+
+type forgetDuringRequest struct {
+	Tag    string    `json:"tag"`
+	Since  time.Time `json:"since"`
+	Before time.Time `json:"before"`
+}
+
+type forgetUserRequest struct {
+	User userhash.Hash `json:"user"`
+}
+
+type expireRequest struct {
+	Tag    string    `json:"tag"`
+	Before time.Time `json:"before"`
+}
+
+type expireResponse struct {
+	Count int64 `json:"count"`
+}
+
+type statsRequest struct {
+	Tag string `json:"tag"`
+}
+
+type statsResponse struct {
+	Messages int64     `json:"messages"`
+	Deleted  int64     `json:"deleted"`
+	Tuples   int64     `json:"tuples"`
+	Prefixes int64     `json:"prefixes"`
+	Starts   int64     `json:"starts"`
+	Oldest   time.Time `json:"oldest"`
+	Newest   time.Time `json:"newest"`
+}
+
+type messagesRequest struct {
+	Tag     string `json:"tag"`
+	Deleted bool   `json:"deleted"`
+}
+
+// messageLine is a single line of a message listing.
+// Exactly one of Error or the other fields is set.
+type messageLine struct {
+	Tag     string        `json:"tag,omitempty"`
+	ID      string        `json:"id,omitempty"`
+	User    userhash.Hash `json:"user,omitzero"`
+	Time    time.Time     `json:"time,omitzero"`
+	Tuples  []tuple       `json:"tuples,omitempty"`
+	Deleted string        `json:"deleted,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+type tagsResponse struct {
+	Tags []string `json:"tags"`
+}
+
+type copyTagRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type dropTagRequest struct {
+	Tag string `json:"tag"`
+}
@@ -0,0 +1,125 @@
+package httpbrain_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/brain/braintest"
+	"github.com/zephyrtronium/robot/brain/httpbrain"
+	"github.com/zephyrtronium/robot/brain/membrain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+func TestIntegrated(t *testing.T) {
+	braintest.Test(context.Background(), t, func(ctx context.Context) brain.Brain {
+		s := httpbrain.NewServer(membrain.New())
+		s.Grant("test", "bocchi", []string{httpbrain.AllTags})
+		srv := httptest.NewServer(s)
+		t.Cleanup(srv.Close)
+		return httpbrain.New(srv.URL, "bocchi", srv.Client())
+	})
+}
+
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+	s := httpbrain.NewServer(membrain.New())
+	s.Grant("kessoku", "bocchi", []string{"kessoku"})
+	s.Grant("sickhack", "hiroi", []string{"sickhack", "other"})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	bocchi := httpbrain.New(srv.URL, "bocchi", srv.Client())
+	hiroi := httpbrain.New(srv.URL, "hiroi", srv.Client())
+	kita := httpbrain.New(srv.URL, "kita", srv.Client())
+	toks := []string{"member ", "bocchi "}
+	if err := brain.Learn(ctx, bocchi, "kessoku", "1", userhash.Hash{1}, time.Unix(0, 0), toks); err != nil {
+		t.Errorf("couldn't learn with allowed token: %v", err)
+	}
+	if err := brain.Learn(ctx, bocchi, "sickhack", "2", userhash.Hash{1}, time.Unix(0, 0), toks); err == nil {
+		t.Errorf("learned with token for a different tag")
+	}
+	if err := brain.Learn(ctx, kita, "kessoku", "3", userhash.Hash{1}, time.Unix(0, 0), toks); err == nil {
+		t.Errorf("learned with invalid token")
+	}
+	if s, _, err := brain.Speak(ctx, bocchi, "kessoku", ""); s != "member bocchi" || err != nil {
+		t.Errorf("wrong speech with allowed token: %q, %v", s, err)
+	}
+	var b brain.Builder
+	if err := bocchi.Speak(ctx, "kessoku", nil, &b); err != nil {
+		t.Errorf("couldn't speak into builder: %v", err)
+	}
+	spans := []brain.Span{
+		{ID: "1", Start: 0, End: 7, Context: 0},
+		{ID: "1", Start: 7, End: 14, Context: 1},
+	}
+	if diff := cmp.Diff(spans, b.Spans()); diff != "" {
+		t.Errorf("wrong spans from server (+got/-want):\n%s", diff)
+	}
+	if _, _, err := brain.Speak(ctx, hiroi, "kessoku", ""); err == nil {
+		t.Errorf("spoke with token for a different tag")
+	}
+	if err := hiroi.ForgetMessage(ctx, "kessoku", "1"); err == nil {
+		t.Errorf("forgot with token for a different tag")
+	}
+	// Any valid token can forget a user.
+	if err := hiroi.ForgetUser(ctx, &userhash.Hash{1}); err != nil {
+		t.Errorf("couldn't forget user: %v", err)
+	}
+	if err := kita.ForgetUser(ctx, &userhash.Hash{1}); err == nil {
+		t.Errorf("forgot user with invalid token")
+	}
+	if s, _, err := brain.Speak(ctx, bocchi, "kessoku", ""); s != "" || err != nil {
+		t.Errorf("wrong speech after forgetting user: %q, %v", s, err)
+	}
+	if err := brain.Learn(ctx, hiroi, "sickhack", "4", userhash.Hash{2}, time.Unix(0, 0), toks); err != nil {
+		t.Errorf("couldn't learn with allowed token: %v", err)
+	}
+	tags, err := hiroi.Tags(ctx)
+	if err != nil {
+		t.Errorf("couldn't list tags: %v", err)
+	}
+	if diff := cmp.Diff([]string{"sickhack"}, tags); diff != "" {
+		t.Errorf("wrong tags for token (+got/-want):\n%s", diff)
+	}
+	if err := hiroi.CopyTag(ctx, "sickhack", "kessoku"); err == nil {
+		t.Errorf("copied into tag the token can't access")
+	}
+	if err := hiroi.CopyTag(ctx, "sickhack", "other"); err != nil {
+		t.Errorf("couldn't copy between allowed tags: %v", err)
+	}
+	if _, err := bocchi.Stats(ctx, "sickhack"); err == nil {
+		t.Errorf("got stats with token for a different tag")
+	}
+	for _, err := range bocchi.Messages(ctx, "sickhack", false) {
+		if err == nil {
+			t.Errorf("listed messages with token for a different tag")
+		}
+	}
+}
+
+// learnOnly is a brain which implements no optional interfaces.
+type learnOnly struct {
+	brain.Brain
+}
+
+func TestUnimplemented(t *testing.T) {
+	ctx := context.Background()
+	s := httpbrain.NewServer(learnOnly{membrain.New()})
+	s.Grant("test", "bocchi", []string{httpbrain.AllTags})
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	br := httpbrain.New(srv.URL, "bocchi", srv.Client())
+	if _, err := br.Expire(ctx, "kessoku", time.Unix(0, 0)); err == nil {
+		t.Errorf("expired with a brain that can't")
+	}
+	if _, err := br.Stats(ctx, "kessoku"); err == nil {
+		t.Errorf("got stats from a brain that can't")
+	}
+	if _, err := br.Tags(ctx); err == nil {
+		t.Errorf("listed tags from a brain that can't")
+	}
+}
@@ -0,0 +1,391 @@
+package httpbrain
+
+import (
+	"context"
+	"crypto/subtle"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/go-json-experiment/json"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+// Server serves a brain over HTTP.
+type Server struct {
+	br     brain.Brain
+	tokens []token
+	mux    http.ServeMux
+}
+
+// token is an access token and the tags it can access.
+type token struct {
+	secret []byte
+	name   string
+	tags   []string
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// NewServer creates a server for a brain.
+// Tokens must be granted with [Server.Grant] before any request can succeed.
+func NewServer(br brain.Brain) *Server {
+	s := &Server{br: br}
+	s.mux.HandleFunc("POST /learn", s.learn)
+	s.mux.HandleFunc("POST /speak", s.speak)
+	s.mux.HandleFunc("POST /stream", s.stream)
+	s.mux.HandleFunc("POST /forget/message", s.forgetMessage)
+	s.mux.HandleFunc("POST /forget/during", s.forgetDuring)
+	s.mux.HandleFunc("POST /forget/user", s.forgetUser)
+	s.mux.HandleFunc("POST /expire", s.expire)
+	s.mux.HandleFunc("POST /stats", s.stats)
+	s.mux.HandleFunc("POST /messages", s.messages)
+	s.mux.HandleFunc("POST /tags", s.tags)
+	s.mux.HandleFunc("POST /tags/copy", s.copyTag)
+	s.mux.HandleFunc("POST /tags/rename", s.renameTag)
+	s.mux.HandleFunc("POST /tags/drop", s.dropTag)
+	return s
+}
+
+// Grant allows a token to access the given tags. If tags contains [AllTags],
+// the token can access every tag. The name identifies the token in logs.
+// Grant must not be called concurrently with requests.
+func (s *Server) Grant(name, secret string, tags []string) {
+	s.tokens = append(s.tokens, token{secret: []byte(secret), name: name, tags: slices.Clone(tags)})
+}
+
+// ServeHTTP implements [http.Handler].
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// auth finds the token for a request.
+// If the request has no valid token, auth writes an error and returns nil.
+func (s *Server) auth(w http.ResponseWriter, r *http.Request) *token {
+	h, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return nil
+	}
+	b := []byte(h)
+	// Check every token so that timing doesn't reveal which one matched.
+	var found *token
+	for i := range s.tokens {
+		if subtle.ConstantTimeCompare(s.tokens[i].secret, b) == 1 {
+			found = &s.tokens[i]
+		}
+	}
+	if found == nil {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}
+	return found
+}
+
+// allowed reports whether a token can access a tag.
+func (t *token) allowed(tag string) bool {
+	return slices.Contains(t.tags, tag) || slices.Contains(t.tags, AllTags)
+}
+
+// decode reads a JSON request body into v after authenticating the request
+// and checking that its token can access the tag returned by tag.
+// If anything fails, decode writes an error and returns nil.
+func decode[T any](s *Server, w http.ResponseWriter, r *http.Request, tag func(*T) (string, bool)) (*T, *token) {
+	tok := s.auth(w, r)
+	if tok == nil {
+		return nil, nil
+	}
+	b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "couldn't read request: "+err.Error(), http.StatusBadRequest)
+		return nil, nil
+	}
+	v := new(T)
+	if err := json.Unmarshal(b, v); err != nil {
+		http.Error(w, "couldn't decode request: "+err.Error(), http.StatusBadRequest)
+		return nil, nil
+	}
+	if t, ok := tag(v); ok && !tok.check(w, r, t) {
+		return nil, nil
+	}
+	return v, tok
+}
+
+// check reports whether a token can access a tag.
+// If it can't, check writes an error.
+func (t *token) check(w http.ResponseWriter, r *http.Request, tag string) bool {
+	if t.allowed(tag) {
+		return true
+	}
+	slog.WarnContext(r.Context(), "token denied", slog.String("token", t.name), slog.String("tag", tag))
+	http.Error(w, "token can't access tag", http.StatusForbidden)
+	return false
+}
+
+// unimplemented reports that the brain lacks an optional interface.
+func unimplemented(w http.ResponseWriter, what string) {
+	http.Error(w, "brain can't "+what, http.StatusNotImplemented)
+}
+
+// fail reports a brain error.
+func fail(ctx context.Context, w http.ResponseWriter, op string, tok *token, err error) {
+	slog.ErrorContext(ctx, "brain server "+op+" failed", slog.String("token", tok.name), slog.Any("err", err))
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func (s *Server) learn(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *learnRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	tuples := make([]brain.Tuple, len(req.Tuples))
+	for i, t := range req.Tuples {
+		tuples[i] = brain.Tuple{Prefix: t.Prefix, Suffix: t.Suffix}
+		if len(t.Prefix) == 0 {
+			tuples[i].Prefix = nil
+		}
+	}
+	if err := s.br.Learn(r.Context(), req.Tag, req.ID, req.User, req.Time, tuples); err != nil {
+		fail(r.Context(), w, "learn", tok, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) speak(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *speakRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	var b brain.Builder
+	if err := s.br.Speak(seeded(r.Context(), req.Seed), req.Tag, req.Prompt, &b); err != nil {
+		fail(r.Context(), w, "speak", tok, err)
+		return
+	}
+	resp := speakResponse{Text: b.String(), Trace: b.Trace(), Spans: make([]span, len(b.Spans()))}
+	for i, sp := range b.Spans() {
+		resp.Spans[i] = span{ID: sp.ID, Start: sp.Start, End: sp.End, Context: sp.Context}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.MarshalWrite(w, &resp)
+}
+
+// seeded applies a client's seed to a request context.
+func seeded(ctx context.Context, seed uint64) context.Context {
+	if seed == 0 {
+		return ctx
+	}
+	return brain.WithSeed(ctx, seed)
+}
+
+func (s *Server) stream(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *speakRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	st, ok := s.br.(brain.Streamer)
+	if !ok {
+		unimplemented(w, "stream")
+		return
+	}
+	w.Header().Set("Content-Type", "application/jsonl")
+	rc := http.NewResponseController(w)
+	for t, err := range st.Stream(seeded(r.Context(), req.Seed), req.Tag, req.Prompt) {
+		l := streamTerm{ID: t.ID, Text: t.Text, Context: t.Context}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "brain server stream failed", slog.String("token", tok.name), slog.Any("err", err))
+			l = streamTerm{Error: err.Error()}
+		}
+		if err := json.MarshalWrite(w, &l); err != nil {
+			// The client is probably gone.
+			return
+		}
+		w.Write([]byte{'\n'})
+		rc.Flush()
+	}
+}
+
+func (s *Server) forgetMessage(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *forgetMessageRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	if err := s.br.ForgetMessage(r.Context(), req.Tag, req.ID); err != nil {
+		fail(r.Context(), w, "forget message", tok, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) forgetDuring(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *forgetDuringRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	if err := s.br.ForgetDuring(r.Context(), req.Tag, req.Since, req.Before); err != nil {
+		fail(r.Context(), w, "forget during", tok, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) forgetUser(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *forgetUserRequest) (string, bool) { return "", false })
+	if req == nil {
+		return
+	}
+	if err := s.br.ForgetUser(r.Context(), &req.User); err != nil {
+		fail(r.Context(), w, "forget user", tok, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) expire(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *expireRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	ex, ok := s.br.(brain.Expirer)
+	if !ok {
+		unimplemented(w, "expire")
+		return
+	}
+	n, err := ex.Expire(r.Context(), req.Tag, req.Before)
+	if err != nil {
+		fail(r.Context(), w, "expire", tok, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.MarshalWrite(w, &expireResponse{Count: n})
+}
+
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *statsRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	st, ok := s.br.(brain.Statter)
+	if !ok {
+		unimplemented(w, "compute stats")
+		return
+	}
+	v, err := st.Stats(r.Context(), req.Tag)
+	if err != nil {
+		fail(r.Context(), w, "stats", tok, err)
+		return
+	}
+	resp := statsResponse{
+		Messages: v.Messages,
+		Deleted:  v.Deleted,
+		Tuples:   v.Tuples,
+		Prefixes: v.Prefixes,
+		Starts:   v.Starts,
+		Oldest:   v.Oldest,
+		Newest:   v.Newest,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.MarshalWrite(w, &resp)
+}
+
+func (s *Server) messages(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *messagesRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	ex, ok := s.br.(brain.Exporter)
+	if !ok {
+		unimplemented(w, "export")
+		return
+	}
+	w.Header().Set("Content-Type", "application/jsonl")
+	var l messageLine
+	for m, err := range ex.Messages(r.Context(), req.Tag, req.Deleted) {
+		if err != nil {
+			slog.ErrorContext(r.Context(), "brain server messages failed", slog.String("token", tok.name), slog.Any("err", err))
+			l = messageLine{Error: err.Error()}
+		} else {
+			l = messageLine{
+				Tag:     m.Tag,
+				ID:      m.ID,
+				User:    m.User,
+				Time:    m.Time,
+				Tuples:  l.Tuples[:0],
+				Deleted: m.Deleted,
+			}
+			for _, t := range m.Tuples {
+				l.Tuples = append(l.Tuples, tuple{Prefix: t.Prefix, Suffix: t.Suffix})
+			}
+		}
+		if err := json.MarshalWrite(w, &l); err != nil {
+			// The client is probably gone.
+			return
+		}
+		w.Write([]byte{'\n'})
+	}
+}
+
+func (s *Server) tags(w http.ResponseWriter, r *http.Request) {
+	_, tok := decode(s, w, r, func(v *struct{}) (string, bool) { return "", false })
+	if tok == nil {
+		return
+	}
+	tg, ok := s.br.(brain.Tagger)
+	if !ok {
+		unimplemented(w, "list tags")
+		return
+	}
+	all, err := tg.Tags(r.Context())
+	if err != nil {
+		fail(r.Context(), w, "tags", tok, err)
+		return
+	}
+	resp := tagsResponse{Tags: slices.DeleteFunc(all, func(t string) bool { return !tok.allowed(t) })}
+	w.Header().Set("Content-Type", "application/json")
+	json.MarshalWrite(w, &resp)
+}
+
+func (s *Server) copyTag(w http.ResponseWriter, r *http.Request) {
+	s.moveTag(w, r, "copy tag", brain.Tagger.CopyTag)
+}
+
+func (s *Server) renameTag(w http.ResponseWriter, r *http.Request) {
+	s.moveTag(w, r, "rename tag", brain.Tagger.RenameTag)
+}
+
+// moveTag serves copying or renaming a tag.
+func (s *Server) moveTag(w http.ResponseWriter, r *http.Request, op string, f func(brain.Tagger, context.Context, string, string) error) {
+	req, tok := decode(s, w, r, func(v *copyTagRequest) (string, bool) { return v.From, true })
+	if req == nil || !tok.check(w, r, req.To) {
+		return
+	}
+	tg, ok := s.br.(brain.Tagger)
+	if !ok {
+		unimplemented(w, op)
+		return
+	}
+	if err := f(tg, r.Context(), req.From, req.To); err != nil {
+		fail(r.Context(), w, op, tok, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) dropTag(w http.ResponseWriter, r *http.Request) {
+	req, tok := decode(s, w, r, func(v *dropTagRequest) (string, bool) { return v.Tag, true })
+	if req == nil {
+		return
+	}
+	tg, ok := s.br.(brain.Tagger)
+	if !ok {
+		unimplemented(w, "drop tag")
+		return
+	}
+	if err := tg.DropTag(r.Context(), req.Tag); err != nil {
+		fail(r.Context(), w, "drop tag", tok, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
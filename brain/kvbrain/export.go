@@ -0,0 +1,160 @@
+package kvbrain
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+var _ brain.Exporter = (*Brain)(nil)
+
+// ErrUnrecorded is the error yielded when exporting a tag which has knowledge
+// learned before message records were introduced.
+var ErrUnrecorded = errors.New("tag has tuples without message records")
+
+// Messages iterates over the messages learned in tag in order of time.
+// Only messages with records can be exported. If the tag has any tuples
+// learned before records were introduced, the only thing yielded is an error
+// wrapping [ErrUnrecorded], so that an export never silently loses knowledge.
+// Forgetting a message removes its tuples, so deleted messages are never
+// included regardless of deleted.
+func (br *Brain) Messages(ctx context.Context, tag string, deleted bool) iter.Seq2[*brain.Message, error] {
+	return func(yield func(*brain.Message, error) bool) {
+		prefix := recordPrefix(hashTag(nil, tag))
+		var keys [][]byte
+		err := br.knowledge.View(func(txn *badger.Txn) error {
+			n, err := unrecorded(ctx, txn, hashTag(nil, tag))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("%w: %d tuples in %s can't be exported", ErrUnrecorded, n, tag)
+			}
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = prefix
+			it := txn.NewIterator(opts)
+			defer it.Close()
+		records:
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				item := it.Item()
+				k := item.Key()[len(prefix):]
+				if len(k) < 8 {
+					return fmt.Errorf("short message record key %q", item.Key())
+				}
+				m := brain.Message{
+					Tag:  tag,
+					ID:   string(k[8:]),
+					Time: time.Unix(0, int64(binary.BigEndian.Uint64(k)^(1<<63))),
+				}
+				err := item.Value(func(val []byte) error {
+					var err error
+					keys, err = recordKeys(keys[:0], val)
+					if err != nil {
+						return err
+					}
+					copy(m.User[:], val)
+					return nil
+				})
+				if err != nil {
+					return fmt.Errorf("couldn't read message record %q: %w", item.Key(), err)
+				}
+				m.Tuples = make([]brain.Tuple, 0, len(keys))
+				for _, key := range keys {
+					v, err := txn.Get(key)
+					if errors.Is(err, badger.ErrKeyNotFound) {
+						// Forgotten.
+						continue records
+					}
+					if err != nil {
+						return fmt.Errorf("couldn't get knowledge for message %s: %w", m.ID, err)
+					}
+					suf, err := v.ValueCopy(nil)
+					if err != nil {
+						return fmt.Errorf("couldn't read knowledge for message %s: %w", m.ID, err)
+					}
+					p, err := keyPrefix(key)
+					if err != nil {
+						return fmt.Errorf("couldn't read knowledge key for message %s: %w", m.ID, err)
+					}
+					m.Tuples = append(m.Tuples, brain.Tuple{Prefix: p, Suffix: string(suf)})
+				}
+				if !yield(&m, nil) {
+					return errStop
+				}
+			}
+			return nil
+		})
+		if err != nil && err != errStop {
+			yield(nil, err)
+		}
+	}
+}
+
+// unrecorded counts the tuples in a tag which no message record refers to.
+func unrecorded(ctx context.Context, txn *badger.Txn, tag []byte) (int64, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = tag
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	var n int64
+	var keys [][]byte
+	for it.Seek(tag); it.ValidForPrefix(tag); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		item := it.Item()
+		k := item.Key()[len(tag):]
+		if isKnowledge(k) {
+			n++
+			continue
+		}
+		if len(k) == 0 || k[0] != '\xfe' {
+			continue
+		}
+		err := item.Value(func(val []byte) error {
+			var err error
+			keys, err = recordKeys(keys[:0], val)
+			return err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("couldn't read message record %q: %w", item.Key(), err)
+		}
+		n -= int64(len(keys))
+	}
+	return n, nil
+}
+
+// errStop is a sentinel error to stop iterating when the consumer stops.
+var errStop = errors.New("stop")
+
+// keyPrefix gets the prefix terms from a knowledge key.
+func keyPrefix(key []byte) ([]string, error) {
+	if len(key) < tagHashLen {
+		return nil, fmt.Errorf("short knowledge key")
+	}
+	key = key[tagHashLen:]
+	var p []string
+	for {
+		k := bytes.IndexByte(key, '\xff')
+		if k < 0 {
+			return nil, fmt.Errorf("unterminated prefix")
+		}
+		if k == 0 {
+			return p, nil
+		}
+		p = append(p, string(key[:k]))
+		key = key[k+1:]
+	}
+}
@@ -0,0 +1,56 @@
+package kvbrain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+func TestMessagesUnrecorded(t *testing.T) {
+	ctx := context.Background()
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := New(db)
+	if err := brain.Learn(ctx, br, "kessoku", "1", userhash.Hash{}, time.Unix(1, 0), []string{"bocchi "}); err != nil {
+		t.Fatalf("couldn't learn: %v", err)
+	}
+	n := 0
+	for _, err := range br.Messages(ctx, "kessoku", false) {
+		if err != nil {
+			t.Errorf("couldn't list messages: %v", err)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("wrong number of messages: want 1, got %d", n)
+	}
+	// Add a tuple the way versions without message records learned.
+	err = db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(mkey("kessoku", "\xff", "2")), []byte("ryou "))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n = 0
+	for m, err := range br.Messages(ctx, "kessoku", false) {
+		if !errors.Is(err, ErrUnrecorded) {
+			t.Errorf("wrong result with unrecorded tuples: %+v, %v", m, err)
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("wrong number of results with unrecorded tuples: want 1, got %d", n)
+	}
+	// Other tags are unaffected.
+	for m, err := range br.Messages(ctx, "sickhack", false) {
+		t.Errorf("result from empty tag: %+v, %v", m, err)
+	}
+}
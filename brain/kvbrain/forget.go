@@ -3,132 +3,100 @@ package kvbrain
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"slices"
-	"sync"
 	"time"
 
+	"github.com/dgraph-io/badger/v4"
+
 	"github.com/zephyrtronium/robot/userhash"
 )
 
-type past struct {
-	mu sync.Mutex
-
-	k    uint8
-	key  [256][][]byte
-	id   [256]string
-	user [256]userhash.Hash
-	time [256]int64 // unix nano
-}
-
-// record associates a message with a knowledge key.
-func (p *past) record(id string, user userhash.Hash, nanotime int64, keys [][]byte) {
-	p.mu.Lock()
-	p.key[p.k] = slices.Grow(p.key[p.k][:0], len(keys))[:len(keys)]
-	for i, key := range keys {
-		p.key[p.k][i] = append(p.key[p.k][i][:0], key...)
-	}
-	p.id[p.k] = id
-	p.user[p.k] = user
-	p.time[p.k] = nanotime
-	p.k++
-	p.mu.Unlock()
-}
-
-// findID finds all keys corresponding to the given UUID.
-func (p *past) findID(id string) [][]byte {
-	r := make([][]byte, 0, 64)
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for k, v := range p.id {
-		if v == id {
-			keys := p.key[k]
-			r = slices.Grow(r, len(keys))
-			for _, v := range keys {
-				r = append(r, bytes.Clone(v))
-			}
-			return r
-		}
-	}
-	return nil
-}
-
-// findDuring finds all knowledge keys of messages recorded with timestamps in
-// the given time span.
-func (p *past) findDuring(since, before int64) [][]byte {
-	r := make([][]byte, 0, 64)
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for k, v := range p.time {
-		if since <= v && v <= before {
-			keys := p.key[k]
-			r = slices.Grow(r, len(keys))
-			for _, v := range keys {
-				r = append(r, bytes.Clone(v))
-			}
-		}
-	}
-	return r
-}
-
-// findUser finds all knowledge keys of messages recorded from a given user
-// since a timestamp.
-func (p *past) findUser(user userhash.Hash) [][]byte {
-	r := make([][]byte, 0, 64)
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	for k, v := range p.user {
-		if v == user {
-			keys := p.key[k]
-			r = slices.Grow(r, len(keys))
-			for _, v := range keys {
-				r = append(r, bytes.Clone(v))
-			}
-		}
-	}
-	return r
-}
-
 // ForgetMessage forgets everything learned from a single given message.
 // If nothing has been learned from the message, it should be ignored.
 func (br *Brain) ForgetMessage(ctx context.Context, tag, id string) error {
-	past, _ := br.past.Load(tag)
-	if past == nil {
-		return nil
-	}
-	keys := past.findID(id)
 	batch := br.knowledge.NewWriteBatch()
 	defer batch.Cancel()
-	for _, key := range keys {
-		err := batch.Delete(key)
+	idx := idKey(hashTag(nil, tag), id)
+	err := br.knowledge.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(idx)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
-	}
-	err := batch.Flush()
+		key, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		ok, err := forgetRecord(txn, batch, key)
+		if err != nil || ok {
+			return err
+		}
+		// The index entry outlived the record. Clean it up.
+		return batch.Delete(idx)
+	})
 	if err != nil {
+		return fmt.Errorf("couldn't find message %v: %w", id, err)
+	}
+	if err := batch.Flush(); err != nil {
 		return fmt.Errorf("couldn't commit deleting message %v: %w", id, err)
 	}
 	return nil
 }
 
+// forgetRecord adds deletions of everything about the message with the given
+// record key to a batch. The result is false if the record doesn't exist.
+func forgetRecord(txn *badger.Txn, batch *badger.WriteBatch, key []byte) (bool, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("couldn't get message record %q: %w", key, err)
+	}
+	err = item.Value(func(val []byte) error {
+		return deleteRecord(batch, key, val)
+	})
+	return err == nil, err
+}
+
 // ForgetDuring forgets all messages learned in the given time span.
 func (br *Brain) ForgetDuring(ctx context.Context, tag string, since, before time.Time) error {
-	past, _ := br.past.Load(tag)
-	if past == nil {
-		return nil
-	}
-	keys := past.findDuring(since.UnixNano(), before.UnixNano())
+	prefix := recordPrefix(hashTag(nil, tag))
+	start := appendTime(bytes.Clone(prefix), since.UnixNano())
+	// The span includes before, so end just after it.
+	end := appendTime(bytes.Clone(prefix), before.UnixNano()+1)
 	batch := br.knowledge.NewWriteBatch()
 	defer batch.Cancel()
-	for _, key := range keys {
-		err := batch.Delete(key)
-		if err != nil {
-			return err
+	err := br.knowledge.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			if bytes.Compare(item.Key(), end) >= 0 {
+				break
+			}
+			key := item.KeyCopy(nil)
+			err := item.Value(func(val []byte) error {
+				return deleteRecord(batch, key, val)
+			})
+			if err != nil {
+				return err
+			}
 		}
-	}
-	err := batch.Flush()
+		return nil
+	})
 	if err != nil {
+		return fmt.Errorf("couldn't find messages between times %v and %v: %w", since, before, err)
+	}
+	if err := batch.Flush(); err != nil {
 		return fmt.Errorf("couldn't commit deleting between times %v and %v: %w", since, before, err)
 	}
 	return nil
@@ -136,28 +104,80 @@ func (br *Brain) ForgetDuring(ctx context.Context, tag string, since, before tim
 
 // ForgetUser forgets all messages associated with a userhash.
 func (br *Brain) ForgetUser(ctx context.Context, user *userhash.Hash) error {
-	var rangeErr error
-	u := *user
-	br.past.Range(func(tag string, past *past) bool {
-		keys := past.findUser(u)
-		if len(keys) == 0 {
-			return true
-		}
-		batch := br.knowledge.NewWriteBatch()
-		defer batch.Cancel()
-		for _, key := range keys {
-			err := batch.Delete(key)
+	prefix := userKey(*user, nil)
+	batch := br.knowledge.NewWriteBatch()
+	defer batch.Cancel()
+	err := br.knowledge.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			idx := it.Item().KeyCopy(nil)
+			ok, err := forgetRecord(txn, batch, idx[len(prefix):])
 			if err != nil {
-				rangeErr = err
-				return false
+				return err
+			}
+			if !ok {
+				// The index entry outlived the record. Clean it up.
+				if err := batch.Delete(idx); err != nil {
+					return err
+				}
 			}
 		}
-		err := batch.Flush()
-		if err != nil {
-			rangeErr = fmt.Errorf("couldn't commit deleting messages by user: %w", err)
-			return false
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't find messages by user: %w", err)
+	}
+	if err := batch.Flush(); err != nil {
+		return fmt.Errorf("couldn't commit deleting messages by user: %w", err)
+	}
+	return nil
+}
+
+// Expire deletes all messages in tag learned before the given time.
+// Messages learned before records were introduced have no times, so they
+// never expire.
+func (br *Brain) Expire(ctx context.Context, tag string, before time.Time) (int64, error) {
+	prefix := recordPrefix(hashTag(nil, tag))
+	end := appendTime(bytes.Clone(prefix), before.UnixNano())
+	batch := br.knowledge.NewWriteBatch()
+	defer batch.Cancel()
+	var n int64
+	err := br.knowledge.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			if bytes.Compare(item.Key(), end) >= 0 {
+				break
+			}
+			key := item.KeyCopy(nil)
+			err := item.Value(func(val []byte) error {
+				return deleteRecord(batch, key, val)
+			})
+			if err != nil {
+				return err
+			}
+			n++
 		}
-		return false
+		return nil
 	})
-	return rangeErr
+	if err != nil {
+		return 0, fmt.Errorf("couldn't find expired messages: %w", err)
+	}
+	if err := batch.Flush(); err != nil {
+		return 0, fmt.Errorf("couldn't commit deleting messages before %v: %w", before, err)
+	}
+	return n, nil
 }
@@ -1,9 +1,8 @@
 package kvbrain
 
 import (
-	"bytes"
 	"context"
-	"slices"
+	"strconv"
 	"testing"
 	"time"
 
@@ -13,202 +12,6 @@ import (
 	"github.com/zephyrtronium/robot/userhash"
 )
 
-func TestPastRecord(t *testing.T) {
-	var p past
-	ch := make(chan struct{})
-	for i := range len(p.id) {
-		go func() {
-			p.record("1"+string(byte(i)), userhash.Hash{2, byte(i)}, int64(i), [][]byte{{4, byte(i)}, {5}})
-			ch <- struct{}{}
-		}()
-	}
-	for range len(p.id) {
-		<-ch
-	}
-	if p.k != 0 {
-		t.Errorf("wrong final index: %d should be zero", p.k)
-	}
-	for k := range len(p.id) {
-		key, id, user, time := p.key[k], p.id[k], p.user[k], p.time[k]
-		if want := [][]byte{{4, byte(time)}, {5}}; !slices.EqualFunc(key, want, bytes.Equal) {
-			t.Errorf("wrong association between key and time: want %v, got %v", want, key)
-		}
-		if want := ("1" + string(byte(time))); id != want {
-			t.Errorf("wrong association between id and time: want %v, got %v", want, id)
-		}
-		if want := (userhash.Hash{2, byte(time)}); user != want {
-			t.Errorf("wrong association between user and time: want %v, got %v", want, user)
-		}
-	}
-	// Do it again to verify we overwrite.
-	for i := range len(p.id) {
-		go func() {
-			p.record("5"+string(byte(i)), userhash.Hash{6, byte(i)}, int64(i), [][]byte{{8, byte(i)}, {9}})
-			ch <- struct{}{}
-		}()
-	}
-	for range len(p.id) {
-		<-ch
-	}
-	if p.k != 0 {
-		t.Errorf("wrong final index: %d should be zero", p.k)
-	}
-	for k := range len(p.id) {
-		key, id, user, time := p.key[k], p.id[k], p.user[k], p.time[k]
-		if want := [][]byte{{8, byte(time)}, {9}}; !slices.EqualFunc(key, want, bytes.Equal) {
-			t.Errorf("wrong association between key and time: want %v, got %v", want, key)
-		}
-		if want := ("5" + string(byte(time))); id != want {
-			t.Errorf("wrong association between id and time: want %v, got %v", want, id)
-		}
-		if want := (userhash.Hash{6, byte(time)}); user != want {
-			t.Errorf("wrong association between user and time: want %v, got %v", want, user)
-		}
-	}
-}
-
-func TestPastFindID(t *testing.T) {
-	uu := "1"
-	p := past{
-		k:    127,
-		key:  [256][][]byte{255: {[]byte("bocchi")}},
-		id:   [256]string{255: uu},
-		user: [256]userhash.Hash{255: {2}},
-		time: [256]int64{255: 3},
-	}
-	if got, want := p.findID(uu), [][]byte{[]byte("bocchi")}; !slices.EqualFunc(got, want, bytes.Equal) {
-		t.Errorf("wrong key: want %q, got %q", want, got)
-	}
-	if got := p.findID("fake"); got != nil {
-		t.Errorf("non-nil key %q finding fake uuid", got)
-	}
-}
-
-func TestPastFindDuring(t *testing.T) {
-	p := past{
-		k: 127,
-		key: [256][][]byte{
-			0: {[]byte("bocchi")},
-			1: {[]byte("ryou")},
-			2: {[]byte("nijika")},
-			3: {[]byte("kita")},
-		},
-		id: [256]string{
-			0: "2",
-			1: "3",
-			2: "4",
-			3: "1",
-		},
-		user: [256]userhash.Hash{
-			0: {3},
-			1: {4},
-			2: {5},
-			3: {2},
-		},
-		time: [256]int64{
-			0: 4,
-			1: 5,
-			2: 6,
-			3: 3,
-		},
-	}
-	want := [][]byte{
-		[]byte("bocchi"),
-		[]byte("ryou"),
-	}
-	got := p.findDuring(4, 5)
-	if !slices.EqualFunc(got, want, bytes.Equal) {
-		t.Errorf("wrong result: want %q, got %q", want, got)
-	}
-}
-
-func TestPastFindUser(t *testing.T) {
-	p := past{
-		k: 127,
-		key: [256][][]byte{
-			127: {[]byte("bocchi")},
-			192: {[]byte("ryou")},
-			200: {[]byte("nijika")},
-			255: {[]byte("kita")},
-		},
-		id: [256]string{
-			127: "2",
-			192: "3",
-			200: "4",
-			255: "1",
-		},
-		user: [256]userhash.Hash{
-			127: {8},
-			192: {8},
-			200: {5},
-			255: {5},
-		},
-		time: [256]int64{
-			127: 4,
-			192: 5,
-			200: 6,
-			255: 3,
-		},
-	}
-	want := [][]byte{
-		[]byte("bocchi"),
-		[]byte("ryou"),
-	}
-	got := p.findUser(userhash.Hash{8})
-	if !slices.EqualFunc(got, want, bytes.Equal) {
-		t.Errorf("wrong result: want %q, got %q", want, got)
-	}
-}
-
-func BenchmarkPastRecord(b *testing.B) {
-	var p past
-	uu := "1"
-	user := userhash.Hash{2}
-	b.ReportAllocs()
-	for i := range b.N {
-		p.record(uu, user, int64(i), [][]byte{{byte(i)}})
-	}
-}
-
-func BenchmarkPastFindID(b *testing.B) {
-	var p past
-	for i := range len(p.id) {
-		p.record(string(byte(i)), userhash.Hash{byte(i)}, int64(i), [][]byte{{byte(i)}})
-	}
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i := range b.N {
-		use(p.findID(string(byte(i))))
-	}
-}
-
-func BenchmarkPastFindDuring(b *testing.B) {
-	var p past
-	for i := range len(p.id) {
-		p.record(string(byte(i)), userhash.Hash{byte(i)}, int64(i), [][]byte{{byte(i)}})
-	}
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i := range b.N {
-		use(p.findDuring(int64(i), int64(i)))
-	}
-}
-
-func BenchmarkPastFindUser(b *testing.B) {
-	var p past
-	for i := range len(p.id) {
-		p.record(string(byte(i)), userhash.Hash{byte(i)}, int64(i), [][]byte{{byte(i)}})
-	}
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i := range b.N {
-		use(p.findUser(userhash.Hash{byte(i)}))
-	}
-}
-
-//go:noinline
-func use(x [][]byte) {}
-
 func TestForgetMessage(t *testing.T) {
 	type message struct {
 		id   string
@@ -222,6 +25,8 @@ func TestForgetMessage(t *testing.T) {
 		msgs []message
 		uu   string
 		want map[string]string
+		// records is the number of message records left.
+		records int
 	}{
 		{
 			name: "single",
@@ -236,8 +41,9 @@ func TestForgetMessage(t *testing.T) {
 					},
 				},
 			},
-			uu:   "1",
-			want: map[string]string{},
+			uu:      "1",
+			want:    map[string]string{},
+			records: 0,
 		},
 		{
 			name: "several",
@@ -253,8 +59,9 @@ func TestForgetMessage(t *testing.T) {
 					},
 				},
 			},
-			uu:   "1",
-			want: map[string]string{},
+			uu:      "1",
+			want:    map[string]string{},
+			records: 0,
 		},
 		{
 			name: "tagged",
@@ -273,6 +80,7 @@ func TestForgetMessage(t *testing.T) {
 			want: map[string]string{
 				mkey("sickhack", "bocchi\xff\xff", "1"): "ryou",
 			},
+			records: 1,
 		},
 		{
 			name: "unseen",
@@ -291,6 +99,7 @@ func TestForgetMessage(t *testing.T) {
 			want: map[string]string{
 				mkey("kessoku", "bocchi\xff\xff", "1"): "ryou",
 			},
+			records: 1,
 		},
 	}
 	for _, c := range cases {
@@ -312,6 +121,7 @@ func TestForgetMessage(t *testing.T) {
 				t.Errorf("couldn't forget: %v", err)
 			}
 			dbcheck(t, db, c.want)
+			recordcheck(t, db, c.records)
 		})
 	}
 }
@@ -329,6 +139,8 @@ func TestForgetDuring(t *testing.T) {
 		msgs []message
 		a, b int64
 		want map[string]string
+		// records is the number of message records left.
+		records int
 	}{
 		{
 			name: "single",
@@ -346,9 +158,10 @@ func TestForgetDuring(t *testing.T) {
 					},
 				},
 			},
-			a:    0,
-			b:    2,
-			want: map[string]string{},
+			a:       0,
+			b:       2,
+			want:    map[string]string{},
+			records: 0,
 		},
 		{
 			name: "several",
@@ -378,9 +191,10 @@ func TestForgetDuring(t *testing.T) {
 					},
 				},
 			},
-			a:    0,
-			b:    2,
-			want: map[string]string{},
+			a:       0,
+			b:       2,
+			want:    map[string]string{},
+			records: 0,
 		},
 		{
 			name: "none",
@@ -403,6 +217,7 @@ func TestForgetDuring(t *testing.T) {
 			want: map[string]string{
 				mkey("kessoku", "ryou\xffbocchi\xff\xff", "1"): "kita",
 			},
+			records: 1,
 		},
 		{
 			name: "tagged",
@@ -425,6 +240,7 @@ func TestForgetDuring(t *testing.T) {
 			want: map[string]string{
 				mkey("sickhack", "ryou\xffbocchi\xff\xff", "1"): "kita",
 			},
+			records: 1,
 		},
 	}
 	for _, c := range cases {
@@ -448,6 +264,7 @@ func TestForgetDuring(t *testing.T) {
 				t.Errorf("failed to forget between %v and %v: %v", since, before, err)
 			}
 			dbcheck(t, db, c.want)
+			recordcheck(t, db, c.records)
 		})
 	}
 }
@@ -465,6 +282,8 @@ func TestForgetUserSince(t *testing.T) {
 		msgs []message
 		user userhash.Hash
 		want map[string]string
+		// records is the number of message records left.
+		records int
 	}{
 		{
 			name: "match",
@@ -482,8 +301,9 @@ func TestForgetUserSince(t *testing.T) {
 					},
 				},
 			},
-			user: userhash.Hash{2},
-			want: map[string]string{},
+			user:    userhash.Hash{2},
+			want:    map[string]string{},
+			records: 0,
 		},
 		{
 			name: "different",
@@ -505,6 +325,7 @@ func TestForgetUserSince(t *testing.T) {
 			want: map[string]string{
 				mkey("kessoku", "ryou\xffbocchi\xff\xff", "1"): "kita",
 			},
+			records: 1,
 		},
 	}
 	for _, c := range cases {
@@ -526,6 +347,220 @@ func TestForgetUserSince(t *testing.T) {
 				t.Errorf("failed to forget from user %02x: %v", c.user, err)
 			}
 			dbcheck(t, db, c.want)
+			recordcheck(t, db, c.records)
 		})
 	}
 }
+
+func TestExpire(t *testing.T) {
+	type message struct {
+		id   string
+		tag  string
+		time time.Time
+		tups []brain.Tuple
+	}
+	msgs := []message{
+		{
+			id:   "1",
+			tag:  "kessoku",
+			time: time.Unix(1, 0),
+			tups: []brain.Tuple{
+				{
+					Prefix: []string{"bocchi"},
+					Suffix: "",
+				},
+				{
+					Prefix: nil,
+					Suffix: "bocchi",
+				},
+			},
+		},
+		{
+			id:   "2",
+			tag:  "kessoku",
+			time: time.Unix(3, 0),
+			tups: []brain.Tuple{
+				{
+					Prefix: []string{"ryou"},
+					Suffix: "",
+				},
+			},
+		},
+		{
+			id:   "3",
+			tag:  "sickhack",
+			time: time.Unix(1, 0),
+			tups: []brain.Tuple{
+				{
+					Prefix: []string{"kikuri"},
+					Suffix: "",
+				},
+			},
+		},
+	}
+	cases := []struct {
+		name   string
+		tag    string
+		before int64
+		n      int64
+		want   map[string]string
+		// records is the number of message records left.
+		records int
+	}{
+		{
+			name:   "none",
+			tag:    "kessoku",
+			before: 1,
+			n:      0,
+			want: map[string]string{
+				mkey("kessoku", "bocchi\xff\xff", "1"):  "",
+				mkey("kessoku", "\xff", "1"):            "bocchi",
+				mkey("kessoku", "ryou\xff\xff", "2"):    "",
+				mkey("sickhack", "kikuri\xff\xff", "3"): "",
+			},
+			records: 3,
+		},
+		{
+			name:   "one",
+			tag:    "kessoku",
+			before: 2,
+			n:      1,
+			want: map[string]string{
+				mkey("kessoku", "ryou\xff\xff", "2"):    "",
+				mkey("sickhack", "kikuri\xff\xff", "3"): "",
+			},
+			records: 2,
+		},
+		{
+			name:   "all",
+			tag:    "kessoku",
+			before: 4,
+			n:      2,
+			want: map[string]string{
+				mkey("sickhack", "kikuri\xff\xff", "3"): "",
+			},
+			records: 1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			br := New(db)
+			for _, msg := range msgs {
+				err := br.Learn(ctx, msg.tag, msg.id, userhash.Hash{}, msg.time, msg.tups)
+				if err != nil {
+					t.Errorf("failed to learn: %v", err)
+				}
+			}
+			n, err := br.Expire(ctx, c.tag, time.Unix(c.before, 0))
+			if err != nil {
+				t.Errorf("failed to expire: %v", err)
+			}
+			if n != c.n {
+				t.Errorf("wrong number of expired messages: want %d, got %d", c.n, n)
+			}
+			dbcheck(t, db, c.want)
+			recordcheck(t, db, c.records)
+			// Records should be gone as well.
+			k, err := br.Expire(ctx, c.tag, time.Unix(c.before, 0))
+			if err != nil {
+				t.Errorf("failed to expire again: %v", err)
+			}
+			if k != 0 {
+				t.Errorf("expired %d messages a second time", k)
+			}
+		})
+	}
+}
+
+func TestForgetMessageAfterCopyTag(t *testing.T) {
+	ctx := context.Background()
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := New(db)
+	live := []brain.Tuple{{Prefix: []string{"bocchi"}, Suffix: ""}}
+	if err := br.Learn(ctx, "kessoku", "live", userhash.Hash{}, time.Unix(1000, 0), live); err != nil {
+		t.Fatalf("failed to learn: %v", err)
+	}
+	// Copy more messages than the recent messages can hold.
+	for i := range 300 {
+		tups := []brain.Tuple{{Prefix: []string{"kikuri"}, Suffix: ""}}
+		if err := br.Learn(ctx, "sickhack", strconv.Itoa(i), userhash.Hash{}, time.Unix(int64(i), 0), tups); err != nil {
+			t.Fatalf("failed to learn: %v", err)
+		}
+	}
+	if err := br.CopyTag(ctx, "sickhack", "kessoku"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	if err := br.ForgetMessage(ctx, "kessoku", "live"); err != nil {
+		t.Fatalf("failed to forget: %v", err)
+	}
+	err = db.View(func(txn *badger.Txn) error {
+		_, err := txn.Get([]byte(mkey("kessoku", "bocchi\xff\xff", "live")))
+		return err
+	})
+	if err != badger.ErrKeyNotFound {
+		t.Errorf("live message survived forgetting after copy: %v", err)
+	}
+}
+
+func TestForgetAfterCopyTag(t *testing.T) {
+	ctx := context.Background()
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := New(db)
+	tups := []brain.Tuple{{Prefix: []string{"bocchi"}, Suffix: ""}}
+	for i := range 4 {
+		if err := br.Learn(ctx, "kessoku", strconv.Itoa(i), userhash.Hash{byte(i % 2)}, time.Unix(int64(i), 0), tups); err != nil {
+			t.Fatalf("failed to learn: %v", err)
+		}
+	}
+	if err := br.CopyTag(ctx, "kessoku", "sickhack"); err != nil {
+		t.Fatalf("failed to copy: %v", err)
+	}
+	if err := br.RenameTag(ctx, "kessoku", "seika"); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+	recordcheck(t, db, 8)
+	if err := br.ForgetUser(ctx, &userhash.Hash{1}); err != nil {
+		t.Fatalf("failed to forget user: %v", err)
+	}
+	recordcheck(t, db, 4)
+	if err := br.ForgetDuring(ctx, "sickhack", time.Unix(0, 0), time.Unix(2, 0)); err != nil {
+		t.Fatalf("failed to forget during: %v", err)
+	}
+	recordcheck(t, db, 2)
+	if err := br.ForgetDuring(ctx, "seika", time.Unix(0, 0), time.Unix(2, 0)); err != nil {
+		t.Fatalf("failed to forget during: %v", err)
+	}
+	recordcheck(t, db, 0)
+	dbcheck(t, db, map[string]string{})
+}
+
+func TestDropTagRecords(t *testing.T) {
+	ctx := context.Background()
+	db, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	br := New(db)
+	tups := []brain.Tuple{{Prefix: []string{"bocchi"}, Suffix: ""}}
+	for i, tag := range []string{"kessoku", "sickhack"} {
+		if err := br.Learn(ctx, tag, strconv.Itoa(i), userhash.Hash{2}, time.Unix(1, 0), tups); err != nil {
+			t.Fatalf("failed to learn: %v", err)
+		}
+	}
+	if err := br.DropTag(ctx, "kessoku"); err != nil {
+		t.Fatalf("failed to drop: %v", err)
+	}
+	recordcheck(t, db, 1)
+}
@@ -1,6 +1,9 @@
 package kvbrain
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
 	"hash/fnv"
 	"io"
 
@@ -8,6 +11,7 @@ import (
 	"gopkg.in/typ.v4/sync2"
 
 	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
 )
 
 /*
@@ -21,6 +25,37 @@ Tag × Tuples × UUID
 As with the SQL approach, we record every prefix with its suffix, including the
 final empty prefix.
 
+Record key structure:
+Tag × \xfe × Time × UUID
+- Tag is the same as in message keys.
+- \xfe can never begin a tuple term, since terms are UTF-8, so records never
+	collide with knowledge keys.
+- Time is the message timestamp in nanoseconds as a big-endian uint64 with the
+	sign bit flipped so that records sort by time.
+- The value is the sender userhash followed by each knowledge key learned from
+	the message, each preceded by its length as a uvarint.
+
+ID index key structure:
+Tag × \xfc × UUID
+- Tag is the same as in message keys.
+- \xfc can't begin a tuple term or a record, for the same reason as \xfe.
+- The value is the message's record key, so that messages can be found by ID
+	without scanning every record.
+
+User index key structure:
+Zero × \xfb × Userhash × Record
+- Zero is eight \x00 bytes, the length of a hashed tag.
+- Record is the message's full record key, so that a user's messages can be
+	found in every tag without scanning every record.
+- The value is empty.
+
+Tag registry key structure:
+Zero × \xfd × Tag
+- Zero is eight \x00 bytes, the length of a hashed tag.
+- \xfd can't begin a tuple term or a record, so registry keys can't collide
+	with the keys of a tag which happens to hash to zero.
+- Tag is the full tag name. The value is empty.
+
 Operations:
 - Find a start tuple: Search for a prefix of tag × \xff.
 - Find a continuation:
@@ -31,13 +66,18 @@ Operations:
 - Learn: Construct the key according to above. The suffix is the entire value.
 	Record a mapping of tag, UUID, timestamp, and userhash to keys.
 - Forget tuples: thinking…
-- ForgetMessage, ForgetDuring, ForgetUserSince: Look up the actual keys to
-	delete in the recording taken during learning.
+- ForgetMessage, ForgetDuring, ForgetUser: Find the message records through
+	the ID index, by time, or through the user index, and delete the records,
+	their index entries, and the knowledge keys they list together.
+- Messages learned before records were introduced have no records, so they
+	can't be found to forget by ID, time, or user, and they never expire.
+	Tags holding them can't be exported.
 */
 
 type Brain struct {
 	knowledge *badger.DB
-	past      sync2.Map[string, *past]
+	// tags is the set of tags known to be in the tag registry.
+	tags sync2.Map[string, struct{}]
 }
 
 var _ brain.Learner = (*Brain)(nil)
@@ -56,3 +96,116 @@ func hashTag(b []byte, tag string) []byte {
 }
 
 const tagHashLen = 8
+
+// recordPrefix appends the start of a message record key to b, which should
+// already contain the hashed tag.
+func recordPrefix(b []byte) []byte {
+	return append(b, '\xfe')
+}
+
+// appendTime appends a timestamp to b in an order-preserving encoding.
+func appendTime(b []byte, nanotime int64) []byte {
+	return binary.BigEndian.AppendUint64(b, uint64(nanotime)^(1<<63))
+}
+
+// recordKey creates the key for a message record.
+func recordKey(tag, id string, nanotime int64) []byte {
+	b := make([]byte, 0, tagHashLen+1+8+len(id))
+	b = recordPrefix(hashTag(b, tag))
+	b = appendTime(b, nanotime)
+	return append(b, id...)
+}
+
+// isKnowledge reports whether a key with its tag hash removed is a knowledge
+// key rather than a message record or an ID index entry.
+func isKnowledge(k []byte) bool {
+	return len(k) > 0 && k[0] != '\xfe' && k[0] != '\xfc'
+}
+
+// idKey creates the ID index key for a message with the given tag hash.
+func idKey(tag []byte, id string) []byte {
+	b := make([]byte, 0, len(tag)+1+len(id))
+	b = append(b, tag...)
+	b = append(b, '\xfc')
+	return append(b, id...)
+}
+
+// userPrefix is the start of every user index key.
+var userPrefix = []byte("\x00\x00\x00\x00\x00\x00\x00\x00\xfb")
+
+// userKey creates the user index key for a message record key.
+func userKey(user userhash.Hash, record []byte) []byte {
+	b := make([]byte, 0, len(userPrefix)+len(user)+len(record))
+	b = append(b, userPrefix...)
+	b = append(b, user[:]...)
+	return append(b, record...)
+}
+
+// setRecord adds a message record and its index entries to a batch.
+func setRecord(batch *badger.WriteBatch, key []byte, user userhash.Hash, keys [][]byte) error {
+	if err := batch.Set(key, recordValue(user, keys)); err != nil {
+		return err
+	}
+	id := key[tagHashLen+1+8:]
+	if err := batch.Set(idKey(key[:tagHashLen], string(id)), bytes.Clone(key)); err != nil {
+		return err
+	}
+	return batch.Set(userKey(user, key), nil)
+}
+
+// deleteRecord adds deletions of a message's knowledge, its record, and its
+// index entries to a batch, given the record's key and value.
+// The key must not be modified until the batch is flushed.
+func deleteRecord(batch *badger.WriteBatch, key, val []byte) error {
+	keys, err := recordKeys(nil, val)
+	if err != nil {
+		return fmt.Errorf("couldn't read message record %q: %w", key, err)
+	}
+	var user userhash.Hash
+	copy(user[:], val)
+	if len(key) < tagHashLen+1+8 {
+		return fmt.Errorf("short message record key %q", key)
+	}
+	id := key[tagHashLen+1+8:]
+	keys = append(keys, key, idKey(key[:tagHashLen], string(id)), userKey(user, key))
+	for _, k := range keys {
+		if err := batch.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordValue creates the value for a message record.
+func recordValue(user userhash.Hash, keys [][]byte) []byte {
+	n := len(user)
+	for _, key := range keys {
+		n += binary.MaxVarintLen64 + len(key)
+	}
+	b := make([]byte, 0, n)
+	b = append(b, user[:]...)
+	for _, key := range keys {
+		b = binary.AppendUvarint(b, uint64(len(key)))
+		b = append(b, key...)
+	}
+	return b
+}
+
+// recordKeys appends the knowledge keys listed in a record value to dst.
+// The keys are copied from v.
+func recordKeys(dst [][]byte, v []byte) ([][]byte, error) {
+	if len(v) < userhash.Size {
+		return dst, fmt.Errorf("short message record")
+	}
+	v = v[userhash.Size:]
+	for len(v) > 0 {
+		n, k := binary.Uvarint(v)
+		if k <= 0 || uint64(len(v)-k) < n {
+			return dst, fmt.Errorf("corrupt message record")
+		}
+		v = v[k:]
+		dst = append(dst, bytes.Clone(v[:n]))
+		v = v[n:]
+	}
+	return dst, nil
+}
@@ -36,14 +36,6 @@ func (br *Brain) Learn(ctx context.Context, tag, id string, user userhash.Hash,
 		vals[i] = []byte(t.Suffix)
 	}
 
-	p, _ := br.past.Load(tag)
-	if p == nil {
-		// We might race with others also creating this past. Ensure we don't
-		// overwrite if that happens.
-		p, _ = br.past.LoadOrStore(tag, new(past))
-	}
-	p.record(id, user, t.UnixNano(), keys)
-
 	batch := br.knowledge.NewWriteBatch()
 	defer batch.Cancel()
 	for i, key := range keys {
@@ -52,10 +44,24 @@ func (br *Brain) Learn(ctx context.Context, tag, id string, user userhash.Hash,
 			return err
 		}
 	}
+	// Also write the message record, so that we can find the message's keys
+	// by ID, time, or user to forget them.
+	if err := setRecord(batch, recordKey(tag, id, t.UnixNano()), user, keys); err != nil {
+		return err
+	}
+	_, registered := br.tags.Load(tag)
+	if !registered {
+		if err := batch.Set(registryKey(tag), nil); err != nil {
+			return err
+		}
+	}
 	err := batch.Flush()
 	if err != nil {
 		return fmt.Errorf("couldn't commit learned knowledge: %w", err)
 	}
+	if !registered {
+		br.tags.Store(tag, struct{}{})
+	}
 	return nil
 }
 
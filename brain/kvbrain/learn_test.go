@@ -1,7 +1,9 @@
 package kvbrain
 
 import (
+	"bytes"
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -30,6 +32,14 @@ func dbcheck(t *testing.T, db *badger.DB, want map[string]string) {
 		for it.Rewind(); it.Valid(); it.Next() {
 			item := it.Item()
 			k := string(item.Key())
+			if len(k) > tagHashLen && !isKnowledge(item.Key()[tagHashLen:]) {
+				// Message records and their indices are checked separately.
+				continue
+			}
+			if strings.HasPrefix(k, string(registryPrefix)) || strings.HasPrefix(k, string(userPrefix)) {
+				// So are the tag registry and the user index.
+				continue
+			}
 			v, err := item.ValueCopy(nil)
 			if err != nil {
 				t.Errorf("couldn't get value for key %q: %v", k, err)
@@ -49,6 +59,49 @@ func dbcheck(t *testing.T, db *badger.DB, want map[string]string) {
 	}
 }
 
+// recordcheck checks that a database has exactly n message records, each
+// with its index entries.
+func recordcheck(t *testing.T, db *badger.DB, n int) {
+	t.Helper()
+	var records, ids, users int
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{})
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			k := it.Item().Key()
+			switch {
+			case bytes.HasPrefix(k, userPrefix):
+				users++
+				if _, err := txn.Get(k[len(userPrefix)+userhash.Size:]); err != nil {
+					t.Errorf("user index entry %q without record: %v", k, err)
+				}
+			case len(k) > tagHashLen && k[tagHashLen] == '\xfe':
+				records++
+			case len(k) > tagHashLen && k[tagHashLen] == '\xfc':
+				ids++
+				item, err := txn.Get(k)
+				if err != nil {
+					return err
+				}
+				rk, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if _, err := txn.Get(rk); err != nil {
+					t.Errorf("ID index entry %q without record: %v", k, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("view failed: %v", err)
+	}
+	if records != n || ids != n || users != n {
+		t.Errorf("wrong number of records: want %d each, got %d records, %d IDs, %d users", n, records, ids, users)
+	}
+}
+
 func TestLearn(t *testing.T) {
 	uu := ":)"
 	h := userhash.Hash{2}
@@ -132,6 +185,7 @@ func TestLearn(t *testing.T) {
 				t.Errorf("failed to learn: %v", err)
 			}
 			dbcheck(t, db, c.want)
+			recordcheck(t, db, 1)
 		})
 	}
 }
@@ -3,7 +3,9 @@ package kvbrain
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"iter"
 	"math/rand/v2"
 
 	"github.com/dgraph-io/badger/v4"
@@ -15,37 +17,62 @@ import (
 
 var prependerPool tpool.Pool[deque.Deque[string]]
 
+var _ brain.Streamer = (*Brain)(nil)
+
 // Speak generates a full message and appends it to w.
 // The prompt is in reverse order and has entropy reduction applied.
 func (br *Brain) Speak(ctx context.Context, tag string, prompt []string, w *brain.Builder) error {
-	search := prependerPool.Get().Prepend(prompt...)
-	defer func() { prependerPool.Put(search.Reset()) }()
-
-	tb := hashTag(make([]byte, 0, tagHashLen), tag)
-	b := make([]byte, 0, 128)
-	var id string
-	opts := badger.DefaultIteratorOptions
-	// We don't actually need to iterate over values, only the single value
-	// that we decide to use per suffix. So, we can disable value prefetch.
-	opts.PrefetchValues = false
-	opts.Prefix = hashTag(nil, tag)
-	for range 1024 {
-		var err error
-		var l int
-		b = append(b[:0], tb...)
-		b, id, l, err = br.next(b, search.Slice(), opts)
+	for t, err := range br.Stream(ctx, tag, prompt) {
 		if err != nil {
 			return err
 		}
-		if len(b) == 0 {
-			break
-		}
-		w.Append(id, b)
-		search = search.DropEnd(search.Len() - l - 1).Prepend(brain.ReduceEntropy(string(b)))
+		w.Append(t.ID, []byte(t.Text), t.Context)
 	}
 	return nil
 }
 
+// Stream generates a message, yielding each term as it is chosen.
+// The prompt is in reverse order and has entropy reduction applied.
+// If ctx is canceled between terms, its error is yielded.
+func (br *Brain) Stream(ctx context.Context, tag string, prompt []string) iter.Seq2[brain.Term, error] {
+	return func(yield func(brain.Term, error) bool) {
+		search := prependerPool.Get().Prepend(prompt...)
+		defer func() { prependerPool.Put(search.Reset()) }()
+
+		rng := brain.Rand(ctx)
+		tb := hashTag(make([]byte, 0, tagHashLen), tag)
+		b := make([]byte, 0, 128)
+		var id string
+		opts := badger.DefaultIteratorOptions
+		// We don't actually need to iterate over values, only the single value
+		// that we decide to use per suffix. So, we can disable value prefetch.
+		opts.PrefetchValues = false
+		opts.Prefix = hashTag(nil, tag)
+		for range 1024 {
+			if err := ctx.Err(); err != nil {
+				yield(brain.Term{}, err)
+				return
+			}
+			var err error
+			var l int
+			b = append(b[:0], tb...)
+			b, id, l, err = br.next(rng, b, search.Slice(), opts)
+			if err != nil {
+				yield(brain.Term{}, err)
+				return
+			}
+			if len(b) == 0 {
+				break
+			}
+			t := string(b)
+			if !yield(brain.Term{Text: t, ID: id, Context: l}, nil) {
+				return
+			}
+			search = search.DropEnd(search.Len() - l - 1).Prepend(brain.ReduceEntropy(t))
+		}
+	}
+}
+
 // next finds a single token to continue a prompt.
 // The returned values are, in order,
 // b with its contents replaced with the new term,
@@ -53,7 +80,7 @@ func (br *Brain) Speak(ctx context.Context, tag string, prompt []string, w *brai
 // the number of terms of the prompt which matched to produce the new term,
 // and any error.
 // If the returned term is the empty string, generation should end.
-func (br *Brain) next(b []byte, prompt []string, opts badger.IteratorOptions) ([]byte, string, int, error) {
+func (br *Brain) next(rng *rand.Rand, b []byte, prompt []string, opts badger.IteratorOptions) ([]byte, string, int, error) {
 	// These definitions are outside the loop to ensure we don't bias toward
 	// smaller contexts.
 	var (
@@ -79,7 +106,7 @@ func (br *Brain) next(b []byte, prompt []string, opts badger.IteratorOptions) ([
 					// TODO(zeph): for #43, check deleted uuids so we never
 					// pick a message that has been deleted
 					key = item.KeyCopy(key[:0])
-					n = skip.N(rand.Uint64(), rand.Uint64())
+					n = skip.N(rng.Uint64(), rng.Uint64())
 				}
 				it.Next()
 				n--
@@ -112,6 +139,12 @@ func (br *Brain) next(b []byte, prompt []string, opts badger.IteratorOptions) ([
 			}
 			return nil
 		})
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			// The message was forgotten between choosing it and reading it.
+			// Choose again.
+			key, n = nil, 0
+			continue
+		}
 		// The id is everything after the first byte following the hash for
 		// empty prefixes, and everything after the first \xff\xff otherwise.
 		id := key[tagHashLen+1:]
@@ -0,0 +1,98 @@
+package kvbrain
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+var _ brain.Statter = (*Brain)(nil)
+
+// Stats computes statistics for a tag.
+// Messages and their times are counted only from message records, so messages
+// learned before records were introduced count toward tuples but not
+// messages. Forgotten messages count as deleted only if their records remain.
+func (br *Brain) Stats(ctx context.Context, tag string) (*brain.Stats, error) {
+	var s brain.Stats
+	prefix := hashTag(nil, tag)
+	err := br.knowledge.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		// Knowledge keys are sorted by prefix, so all tuples with the same
+		// prefix are adjacent.
+		var last, cur []byte
+		var keys [][]byte
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			k := item.Key()[len(prefix):]
+			if len(k) == 0 || k[0] == '\xfc' {
+				continue
+			}
+			if k[0] != '\xfe' {
+				s.Tuples++
+				if k[0] == '\xff' {
+					s.Starts++
+					cur = k[:1]
+				} else if i := bytes.Index(k, []byte{'\xff', '\xff'}); i >= 0 {
+					cur = k[:i+2]
+				} else {
+					return fmt.Errorf("malformed knowledge key %q", item.Key())
+				}
+				if s.Prefixes == 0 || !bytes.Equal(last, cur) {
+					s.Prefixes++
+					last = append(last[:0], cur...)
+				}
+				continue
+			}
+			// Message record.
+			if len(k) < 1+8 {
+				return fmt.Errorf("short message record key %q", item.Key())
+			}
+			err := item.Value(func(val []byte) error {
+				var err error
+				keys, err = recordKeys(keys[:0], val)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("couldn't read message record %q: %w", item.Key(), err)
+			}
+			// Forgetting deletes all of a message's knowledge keys together,
+			// so checking one is enough.
+			if len(keys) > 0 {
+				_, err := txn.Get(keys[0])
+				if errors.Is(err, badger.ErrKeyNotFound) {
+					s.Deleted++
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("couldn't check message record %q: %w", item.Key(), err)
+				}
+			}
+			s.Messages++
+			t := time.Unix(0, int64(binary.BigEndian.Uint64(k[1:])^(1<<63)))
+			// Records are sorted by time.
+			if s.Oldest.IsZero() {
+				s.Oldest = t
+			}
+			s.Newest = t
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't compute stats: %w", err)
+	}
+	return &s, nil
+}
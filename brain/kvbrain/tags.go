@@ -0,0 +1,257 @@
+package kvbrain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+var _ brain.Tagger = (*Brain)(nil)
+
+// registryPrefix is the start of every tag registry key.
+var registryPrefix = []byte("\x00\x00\x00\x00\x00\x00\x00\x00\xfd")
+
+// registryKey creates the tag registry key for a tag.
+func registryKey(tag string) []byte {
+	b := make([]byte, 0, len(registryPrefix)+len(tag))
+	b = append(b, registryPrefix...)
+	return append(b, tag...)
+}
+
+// Tags lists the tags the brain knows, in sorted order.
+// Tags which have learned nothing since the tag registry was introduced are
+// not listed, although the other tag operations still work on them.
+func (br *Brain) Tags(ctx context.Context) ([]string, error) {
+	var tags []string
+	err := br.knowledge.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = registryPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(registryPrefix); it.ValidForPrefix(registryPrefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			tags = append(tags, string(it.Item().Key()[len(registryPrefix):]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list tags: %w", err)
+	}
+	return tags, nil
+}
+
+// CopyTag copies everything the brain knows in one tag into another.
+func (br *Brain) CopyTag(ctx context.Context, from, to string) error {
+	if from == to {
+		return brain.ErrSameTag
+	}
+	src, dst := hashTag(nil, from), hashTag(nil, to)
+	batch := br.knowledge.NewWriteBatch()
+	defer batch.Cancel()
+	var n int
+	err := br.knowledge.View(func(txn *badger.Txn) error {
+		// Copy records first to learn which messages the destination already
+		// has, so that their knowledge keys can be skipped.
+		skip, err := copyRecords(ctx, txn, batch, src, dst)
+		if err != nil {
+			return err
+		}
+		n, err = copyKnowledge(ctx, txn, batch, skip, src, dst)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't copy tag %q to %q: %w", from, to, err)
+	}
+	if n == 0 {
+		// Nothing to copy. Don't register a tag that knows nothing.
+		return nil
+	}
+	if err := batch.Set(registryKey(to), nil); err != nil {
+		return err
+	}
+	if err := batch.Flush(); err != nil {
+		return fmt.Errorf("couldn't commit copying tag %q to %q: %w", from, to, err)
+	}
+	br.tags.Store(to, struct{}{})
+	return nil
+}
+
+// copyRecords copies the message records with tag hash src to dst, along with
+// their index entries. The result is the set of message IDs already recorded
+// in dst.
+func copyRecords(ctx context.Context, txn *badger.Txn, batch *badger.WriteBatch, src, dst []byte) (map[string]bool, error) {
+	skip := make(map[string]bool)
+	prefix := recordPrefix(bytes.Clone(src))
+	var keys [][]byte
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		item := it.Item()
+		k := item.Key()[len(prefix):]
+		if len(k) < 8 {
+			return nil, fmt.Errorf("short message record key %q", item.Key())
+		}
+		id := string(k[8:])
+		key := append(recordPrefix(bytes.Clone(dst)), k...)
+		_, err := txn.Get(key)
+		if err == nil {
+			skip[id] = true
+			continue
+		}
+		if !errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, fmt.Errorf("couldn't check message record %q: %w", key, err)
+		}
+		var user userhash.Hash
+		err = item.Value(func(val []byte) error {
+			var err error
+			keys, err = recordKeys(keys[:0], val)
+			copy(user[:], val)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read message record %q: %w", item.Key(), err)
+		}
+		for i := range keys {
+			copy(keys[i], dst)
+		}
+		if err := setRecord(batch, key, user, keys); err != nil {
+			return nil, err
+		}
+	}
+	return skip, nil
+}
+
+// copyKnowledge copies the knowledge keys with tag hash src to dst, except for
+// those belonging to messages in skip. The result is the number of keys copied.
+func copyKnowledge(ctx context.Context, txn *badger.Txn, batch *badger.WriteBatch, skip map[string]bool, src, dst []byte) (int, error) {
+	var n int
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = src
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(src); it.ValidForPrefix(src); it.Next() {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		item := it.Item()
+		k := item.Key()[len(src):]
+		if !isKnowledge(k) {
+			continue
+		}
+		id, err := keyID(k)
+		if err != nil {
+			return n, fmt.Errorf("%w: %q", err, item.Key())
+		}
+		if skip[id] {
+			continue
+		}
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return n, fmt.Errorf("couldn't read knowledge %q: %w", item.Key(), err)
+		}
+		if err := batch.Set(append(bytes.Clone(dst), k...), v); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// RenameTag moves everything the brain knows in one tag into another.
+// The copy and the drop are not atomic, so an error while dropping can leave
+// the knowledge in both tags.
+func (br *Brain) RenameTag(ctx context.Context, from, to string) error {
+	if err := br.CopyTag(ctx, from, to); err != nil {
+		return err
+	}
+	return br.DropTag(ctx, from)
+}
+
+// DropTag permanently deletes everything the brain knows in a tag.
+func (br *Brain) DropTag(ctx context.Context, tag string) error {
+	// Clear the registry first so that a failed drop still allows the tag to
+	// be registered again when it learns.
+	br.tags.Delete(tag)
+	err := br.knowledge.Update(func(txn *badger.Txn) error {
+		return txn.Delete(registryKey(tag))
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't unregister tag %q: %w", tag, err)
+	}
+	// The user index isn't under the tag's prefix, so find its entries from
+	// the records before dropping them.
+	users, err := br.userKeys(ctx, tag)
+	if err != nil {
+		return fmt.Errorf("couldn't find users of tag %q: %w", tag, err)
+	}
+	if err := br.knowledge.DropPrefix(hashTag(nil, tag)); err != nil {
+		return fmt.Errorf("couldn't drop tag %q: %w", tag, err)
+	}
+	batch := br.knowledge.NewWriteBatch()
+	defer batch.Cancel()
+	for _, k := range users {
+		if err := batch.Delete(k); err != nil {
+			return err
+		}
+	}
+	if err := batch.Flush(); err != nil {
+		return fmt.Errorf("couldn't commit deleting users of tag %q: %w", tag, err)
+	}
+	return nil
+}
+
+// userKeys lists the user index keys of every message recorded in a tag.
+func (br *Brain) userKeys(ctx context.Context, tag string) ([][]byte, error) {
+	prefix := recordPrefix(hashTag(nil, tag))
+	var r [][]byte
+	err := br.knowledge.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			err := item.Value(func(val []byte) error {
+				if len(val) < userhash.Size {
+					return fmt.Errorf("short message record %q", item.Key())
+				}
+				r = append(r, userKey(userhash.Hash(val[:userhash.Size]), item.Key()))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return r, err
+}
+
+// keyID gets the message ID from a knowledge key with the tag hash removed.
+func keyID(k []byte) (string, error) {
+	if len(k) > 0 && k[0] == '\xff' {
+		return string(k[1:]), nil
+	}
+	i := bytes.Index(k, []byte{'\xff', '\xff'})
+	if i < 0 {
+		return "", errors.New("malformed knowledge key")
+	}
+	return string(k[i+2:]), nil
+}
@@ -36,6 +36,13 @@ type Learner interface {
 	ForgetUser(ctx context.Context, user *userhash.Hash) error
 }
 
+// Expirer is a [Learner] which can permanently remove old messages in bulk.
+type Expirer interface {
+	// Expire deletes all messages in tag learned before the given time.
+	// The result is the number of messages removed.
+	Expire(ctx context.Context, tag string, before time.Time) (int64, error)
+}
+
 var tuplesPool tpool.Pool[[]Tuple]
 
 // Learn records tokens into a Learner.
@@ -0,0 +1,53 @@
+package membrain
+
+import (
+	"context"
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+// Messages iterates over the messages learned in tag in order of time.
+// Forgetting a message removes its tuples, so deleted messages are included
+// without tuples.
+// The yielded messages share storage with the brain and must not be modified.
+func (br *Brain) Messages(ctx context.Context, tag string, deleted bool) iter.Seq2[*brain.Message, error] {
+	return func(yield func(*brain.Message, error) bool) {
+		br.mu.RLock()
+		var msgs []brain.Message
+		if c := br.tags[tag]; c != nil {
+			msgs = make([]brain.Message, 0, len(c.msgs))
+			for _, m := range c.msgs {
+				if m.deleted != "" && !deleted {
+					continue
+				}
+				msgs = append(msgs, brain.Message{
+					Tag:     tag,
+					ID:      m.id,
+					User:    m.user,
+					Time:    m.time,
+					Tuples:  m.tuples,
+					Deleted: m.deleted,
+				})
+			}
+		}
+		br.mu.RUnlock()
+		slices.SortFunc(msgs, func(a, b brain.Message) int {
+			if c := a.Time.Compare(b.Time); c != 0 {
+				return c
+			}
+			return strings.Compare(a.ID, b.ID)
+		})
+		for i := range msgs {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(&msgs[i], nil) {
+				return
+			}
+		}
+	}
+}
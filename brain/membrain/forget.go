@@ -0,0 +1,76 @@
+package membrain
+
+import (
+	"context"
+	"time"
+
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+// ForgetMessage forgets everything learned from a single given message.
+// If nothing has been learned from the message, it is prevented from being
+// learned later.
+func (br *Brain) ForgetMessage(ctx context.Context, tag, id string) error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	c := br.chainLocked(tag)
+	m := c.msgs[id]
+	if m == nil {
+		c.msgs[id] = &message{id: id, deleted: "CLEARMSG"}
+		return nil
+	}
+	m.forgetLocked("CLEARMSG")
+	return nil
+}
+
+// ForgetDuring forgets all messages learned in the given time span.
+func (br *Brain) ForgetDuring(ctx context.Context, tag string, since, before time.Time) error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	c := br.tags[tag]
+	if c == nil {
+		return nil
+	}
+	for _, m := range c.msgs {
+		if !m.time.Before(since) && !m.time.After(before) {
+			m.forgetLocked("TIME")
+		}
+	}
+	return nil
+}
+
+// ForgetUser forgets all messages associated with a userhash.
+func (br *Brain) ForgetUser(ctx context.Context, user *userhash.Hash) error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	for _, c := range br.tags {
+		for _, m := range c.msgs {
+			if m.user == *user {
+				m.forgetLocked("CLEARCHAT")
+			}
+		}
+	}
+	return nil
+}
+
+// Expire permanently deletes all messages in tag learned before the given
+// time, including messages which have already been forgotten.
+// Messages with no recorded time are never expired.
+func (br *Brain) Expire(ctx context.Context, tag string, before time.Time) (int64, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	c := br.tags[tag]
+	if c == nil {
+		return 0, nil
+	}
+	var n int64
+	for id, m := range c.msgs {
+		if m.time.IsZero() || !m.time.Before(before) {
+			continue
+		}
+		m.forgetLocked("EXPIRED")
+		delete(c.msgs, id)
+		n++
+	}
+	return n, nil
+}
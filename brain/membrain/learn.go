@@ -0,0 +1,50 @@
+package membrain
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+// Learn records a set of tuples.
+// If a message with the same ID has already been learned or forgotten in the
+// tag, Learn does nothing.
+func (br *Brain) Learn(ctx context.Context, tag, id string, user userhash.Hash, t time.Time, tuples []brain.Tuple) error {
+	if len(tuples) == 0 {
+		return errors.New("no tuples to learn")
+	}
+	// Copy the tuples outside the lock, since the caller may reuse them.
+	m := &message{
+		id:     id,
+		user:   user,
+		time:   t,
+		tuples: make([]brain.Tuple, len(tuples)),
+		nodes:  make([]*node, 0, len(tuples)),
+	}
+	for i, tt := range tuples {
+		m.tuples[i] = brain.Tuple{Prefix: slices.Clone(tt.Prefix), Suffix: tt.Suffix}
+	}
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	c := br.chainLocked(tag)
+	if c.msgs[id] != nil {
+		return nil
+	}
+	c.insert(m)
+	return nil
+}
+
+// insert adds a message and its tuples to the chain.
+func (c *chain) insert(m *message) {
+	c.msgs[m.id] = m
+	for _, tt := range m.tuples {
+		// Every prefix ends with the empty term marking the start of the message.
+		n := c.root.add(tt.Prefix).add([]string{""})
+		n.entries = append(n.entries, entry{msg: m, suffix: tt.Suffix})
+		m.nodes = append(m.nodes, n)
+	}
+}
@@ -0,0 +1,174 @@
+// Package membrain implements a brain held entirely in memory.
+//
+// A membrain is suitable for tests, for channels whose knowledge should not
+// outlive the process, and as a reference against which to compare other
+// brain implementations. It can be saved to and loaded from the format
+// written by [brain.Export].
+package membrain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+/*
+Each tag holds a trie of prefix terms. The path to a node is the terms of a
+prefix in the order they are stored in tuples, followed by the empty term which
+marks the start of a message. Each node holds the suffixes of tuples with
+exactly that prefix, so the options for a search context are all the entries
+in the subtree at the context's node. This gives the same matching as
+sqlbrain, where a context matches every stored prefix that begins with it.
+
+Each message records the nodes holding its entries so that forgetting it only
+needs to touch those nodes.
+*/
+
+// Brain is an in-memory brain.
+type Brain struct {
+	mu   sync.RWMutex
+	tags map[string]*chain
+}
+
+var (
+	_ brain.Brain    = (*Brain)(nil)
+	_ brain.Expirer  = (*Brain)(nil)
+	_ brain.Exporter = (*Brain)(nil)
+)
+
+// New creates an empty brain.
+func New() *Brain {
+	return &Brain{tags: make(map[string]*chain)}
+}
+
+// chain is the knowledge for a single tag.
+type chain struct {
+	root node
+	msgs map[string]*message
+}
+
+// node is a single prefix term in a chain.
+type node struct {
+	parent *node
+	term   string
+	next   map[string]*node
+	// kids is the values of next in the order they were added, so that
+	// walking the trie is deterministic.
+	kids    []*node
+	entries []entry
+}
+
+// entry is a single suffix for a prefix.
+type entry struct {
+	msg    *message
+	suffix string
+}
+
+// message is a learned message.
+// Forgotten messages remain with no tuples so that they are not learned again.
+type message struct {
+	id     string
+	user   userhash.Hash
+	time   time.Time
+	tuples []brain.Tuple
+	nodes  []*node
+	// deleted is the reason the message was forgotten, or the empty string
+	// if it has not been.
+	deleted string
+}
+
+// chainLocked gets the chain for a tag, creating it if needed.
+// br.mu must be held for writing.
+func (br *Brain) chainLocked(tag string) *chain {
+	c := br.tags[tag]
+	if c == nil {
+		c = &chain{msgs: make(map[string]*message)}
+		br.tags[tag] = c
+	}
+	return c
+}
+
+// find finds the node for a search context.
+// The result is nil if no learned prefix begins with the context.
+func (n *node) find(terms []string) *node {
+	for _, w := range terms {
+		n = n.next[w]
+		if n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// add finds the node for a search context, creating nodes as needed.
+func (n *node) add(terms []string) *node {
+	for _, w := range terms {
+		m := n.next[w]
+		if m == nil {
+			if n.next == nil {
+				n.next = make(map[string]*node)
+			}
+			m = &node{parent: n, term: w}
+			n.next[w] = m
+			n.kids = append(n.kids, m)
+		}
+		n = m
+	}
+	return n
+}
+
+// prune removes n and its ancestors from the trie while they hold nothing.
+func (n *node) prune() {
+	for n.parent != nil && len(n.entries) == 0 && len(n.next) == 0 {
+		if n.parent.next[n.term] == n {
+			delete(n.parent.next, n.term)
+			n.parent.kids = slices.DeleteFunc(n.parent.kids, func(m *node) bool { return m == n })
+		}
+		n = n.parent
+	}
+}
+
+// forgetLocked removes the tuples of a message from its chain, recording the
+// reason it was forgotten.
+func (m *message) forgetLocked(reason string) {
+	if m.deleted == "" {
+		m.deleted = reason
+	}
+	for _, n := range m.nodes {
+		n.entries = slices.DeleteFunc(n.entries, func(e entry) bool { return e.msg == m })
+		n.prune()
+	}
+	m.nodes = nil
+	m.tuples = nil
+}
+
+// Save writes the brain's messages to w in the format written by
+// [brain.Export]. Forgotten messages are written without their tuples so that
+// loading the result still prevents them from being learned again.
+func (br *Brain) Save(ctx context.Context, w io.Writer) error {
+	br.mu.RLock()
+	tags := make([]string, 0, len(br.tags))
+	for tag := range br.tags {
+		tags = append(tags, tag)
+	}
+	br.mu.RUnlock()
+	slices.Sort(tags)
+	if _, err := brain.Export(ctx, w, br, tags, true); err != nil {
+		return fmt.Errorf("couldn't save brain: %w", err)
+	}
+	return nil
+}
+
+// Load learns messages from r in the format written by [brain.Export].
+func (br *Brain) Load(ctx context.Context, r io.Reader) error {
+	if _, err := brain.Import(ctx, br, r); err != nil {
+		return fmt.Errorf("couldn't load brain: %w", err)
+	}
+	return nil
+}
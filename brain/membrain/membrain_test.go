@@ -0,0 +1,164 @@
+package membrain_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/brain/braintest"
+	"github.com/zephyrtronium/robot/brain/membrain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+func TestIntegrated(t *testing.T) {
+	braintest.Test(context.Background(), t, func(ctx context.Context) brain.Brain { return membrain.New() })
+}
+
+func TestSpeakPrompt(t *testing.T) {
+	ctx := context.Background()
+	br := membrain.New()
+	msgs := [][]string{
+		{"bocchi ", "the ", "rock "},
+		{"bocchi ", "the ", "rock ", "star "},
+		{"the ", "rock "},
+	}
+	for i, toks := range msgs {
+		if err := brain.Learn(ctx, br, "kessoku", string(rune('1'+i)), userhash.Hash{}, time.Unix(int64(i), 0), toks); err != nil {
+			t.Fatalf("couldn't learn: %v", err)
+		}
+	}
+	got := make(map[string]bool)
+	for range 256 {
+		s, _, err := brain.Speak(ctx, br, "kessoku", "bocchi the")
+		if err != nil {
+			t.Fatalf("couldn't speak: %v", err)
+		}
+		got[s] = true
+	}
+	want := map[string]bool{
+		"bocchi the rock":      true,
+		"bocchi the rock star": true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong prompted messages (+got/-want):\n%s", diff)
+	}
+	if s, _, err := brain.Speak(ctx, br, "anime", ""); s != "" || err != nil {
+		t.Errorf("spoke from empty tag: %q, %v", s, err)
+	}
+}
+
+func TestStreamDoesNotBlockLearn(t *testing.T) {
+	ctx := context.Background()
+	br := membrain.New()
+	if err := brain.Learn(ctx, br, "kessoku", "1", userhash.Hash{}, time.Unix(0, 0), []string{"bocchi ", "the ", "rock "}); err != nil {
+		t.Fatalf("couldn't learn: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		// Learn from inside the consumer of the stream. If the stream held
+		// the brain locked while yielding, this would never finish.
+		for _, err := range br.Stream(ctx, "kessoku", nil) {
+			if err != nil {
+				done <- err
+				return
+			}
+			done <- brain.Learn(ctx, br, "kessoku", "2", userhash.Hash{}, time.Unix(1, 0), []string{"kita "})
+			return
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("couldn't learn while streaming: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("learning blocked while streaming")
+	}
+}
+
+func TestForgetBeforeLearn(t *testing.T) {
+	ctx := context.Background()
+	br := membrain.New()
+	if err := br.ForgetMessage(ctx, "kessoku", "1"); err != nil {
+		t.Fatalf("couldn't forget: %v", err)
+	}
+	if err := brain.Learn(ctx, br, "kessoku", "1", userhash.Hash{}, time.Unix(0, 0), []string{"bocchi "}); err != nil {
+		t.Fatalf("couldn't learn: %v", err)
+	}
+	if s, _, err := brain.Speak(ctx, br, "kessoku", ""); s != "" || err != nil {
+		t.Errorf("spoke forgotten message: %q, %v", s, err)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	br := membrain.New()
+	msgs := []struct {
+		tag, id string
+		toks    []string
+	}{
+		{"kessoku", "1", []string{"bocchi ", "the ", "rock "}},
+		{"kessoku", "2", []string{"ryo ", "yamada "}},
+		{"sickhack", "3", []string{"kikuri ", "hiroi "}},
+	}
+	for i, m := range msgs {
+		if err := brain.Learn(ctx, br, m.tag, m.id, userhash.Hash{byte(i)}, time.Unix(int64(i), 0), m.toks); err != nil {
+			t.Fatalf("couldn't learn: %v", err)
+		}
+	}
+	if err := br.ForgetMessage(ctx, "kessoku", "2"); err != nil {
+		t.Fatalf("couldn't forget: %v", err)
+	}
+	// Forget a message that was never learned, too.
+	if err := br.ForgetMessage(ctx, "sickhack", "4"); err != nil {
+		t.Fatalf("couldn't forget: %v", err)
+	}
+	var b bytes.Buffer
+	if err := br.Save(ctx, &b); err != nil {
+		t.Fatalf("couldn't save: %v", err)
+	}
+	cp := membrain.New()
+	if err := cp.Load(ctx, &b); err != nil {
+		t.Fatalf("couldn't load: %v", err)
+	}
+	// Forgotten messages must stay forgotten.
+	if err := brain.Learn(ctx, cp, "kessoku", "2", userhash.Hash{}, time.Unix(5, 0), []string{"ryo "}); err != nil {
+		t.Fatalf("couldn't relearn: %v", err)
+	}
+	if err := brain.Learn(ctx, cp, "sickhack", "4", userhash.Hash{}, time.Unix(5, 0), []string{"seika "}); err != nil {
+		t.Fatalf("couldn't relearn: %v", err)
+	}
+	for _, tag := range []string{"kessoku", "sickhack"} {
+		var want, got []brain.Message
+		for m, err := range br.Messages(ctx, tag, false) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, *m)
+		}
+		for m, err := range cp.Messages(ctx, tag, false) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, *m)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("wrong messages in %s after load (+got/-want):\n%s", tag, diff)
+		}
+	}
+}
+
+func BenchmarkLearn(b *testing.B) {
+	new := func(ctx context.Context, b *testing.B) brain.Learner { return membrain.New() }
+	braintest.BenchLearn(context.Background(), b, new, nil)
+}
+
+func BenchmarkSpeak(b *testing.B) {
+	new := func(ctx context.Context, b *testing.B) brain.Brain { return membrain.New() }
+	braintest.BenchSpeak(context.Background(), b, new, nil)
+}
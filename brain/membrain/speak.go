@@ -0,0 +1,125 @@
+package membrain
+
+import (
+	"context"
+	"iter"
+	"math/rand/v2"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/deque"
+	"github.com/zephyrtronium/robot/tpool"
+)
+
+var prependerPool tpool.Pool[deque.Deque[string]]
+
+var _ brain.Streamer = (*Brain)(nil)
+
+// Speak generates a full message and appends it to w.
+// The prompt is in reverse order and has entropy reduction applied.
+func (br *Brain) Speak(ctx context.Context, tag string, prompt []string, w *brain.Builder) error {
+	for t, err := range br.Stream(ctx, tag, prompt) {
+		if err != nil {
+			return err
+		}
+		w.Append(t.ID, []byte(t.Text), t.Context)
+	}
+	return nil
+}
+
+// Stream generates a message, yielding each term as it is chosen.
+// The prompt is in reverse order and has entropy reduction applied.
+// The brain is locked for reading only while choosing each term, so slow
+// consumers don't block learning.
+// If ctx is canceled between terms, its error is yielded.
+func (br *Brain) Stream(ctx context.Context, tag string, prompt []string) iter.Seq2[brain.Term, error] {
+	return func(yield func(brain.Term, error) bool) {
+		search := prependerPool.Get().Append("").Prepend(prompt...)
+		defer func() { prependerPool.Put(search.Reset()) }()
+
+		rng := brain.Rand(ctx)
+		for range 1024 {
+			if err := ctx.Err(); err != nil {
+				yield(brain.Term{}, err)
+				return
+			}
+			s, id, l := br.next(rng, tag, search.Slice())
+			if s == "" {
+				break
+			}
+			// The search always ends with the empty string marking the start
+			// of the message, which isn't a term of context.
+			c := min(l, search.Len()-1)
+			if !yield(brain.Term{Text: s, ID: id, Context: c}, nil) {
+				return
+			}
+			search = search.DropEnd(search.Len() - l - 1).Prepend(brain.ReduceEntropy(s))
+		}
+	}
+}
+
+// next chooses a single term to continue a search context in a tag.
+// The results are the same as for [chain.next], copied out from under the
+// brain's lock.
+func (br *Brain) next(rng *rand.Rand, tag string, prompt []string) (string, string, int) {
+	br.mu.RLock()
+	defer br.mu.RUnlock()
+	c := br.tags[tag]
+	if c == nil {
+		return "", "", len(prompt)
+	}
+	return c.next(rng, prompt)
+}
+
+// next finds a single term to continue a search context.
+// The returned values are, in order, the new term, the ID of the message used
+// for the term, and the number of terms of the context which matched to
+// produce it. If the returned term is the empty string, generation should end.
+func (c *chain) next(rng *rand.Rand, prompt []string) (string, string, int) {
+	// These definitions are outside the loop to ensure we don't bias toward
+	// smaller contexts.
+	var (
+		pick   *entry
+		skip   brain.Skip
+		picked int
+	)
+	for {
+		c.root.find(prompt).walk(0, func(e *entry) uint64 {
+			pick = e
+			picked++
+			return skip.N(rng.Uint64(), rng.Uint64())
+		})
+		if picked < 3 && len(prompt) > 3 {
+			// We haven't seen enough options, and we have context we could
+			// lose. Do so and try again from the beginning.
+			prompt = prompt[:len(prompt)-1]
+			continue
+		}
+		if pick == nil {
+			return "", "", len(prompt)
+		}
+		return pick.suffix, pick.msg.id, len(prompt)
+	}
+}
+
+// walk visits entries in the subtree rooted at n, skipping k entries before
+// the first visit and then the number returned by f after each visit.
+// The result is the number of entries which remained to be skipped.
+// It is safe to call on a nil node.
+func (n *node) walk(k uint64, f func(e *entry) uint64) uint64 {
+	if n == nil {
+		return k
+	}
+	for i := uint64(0); ; i++ {
+		r := uint64(len(n.entries)) - i
+		if k >= r {
+			k -= r
+			break
+		}
+		i += k
+		k = f(&n.entries[i])
+	}
+	for _, m := range n.kids {
+		k = m.walk(k, f)
+	}
+	return k
+}
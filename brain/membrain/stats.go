@@ -0,0 +1,54 @@
+package membrain
+
+import (
+	"context"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+var _ brain.Statter = (*Brain)(nil)
+
+// Stats computes statistics for a tag.
+func (br *Brain) Stats(ctx context.Context, tag string) (*brain.Stats, error) {
+	br.mu.RLock()
+	defer br.mu.RUnlock()
+	var s brain.Stats
+	c := br.tags[tag]
+	if c == nil {
+		return &s, nil
+	}
+	for _, m := range c.msgs {
+		if len(m.tuples) == 0 {
+			s.Deleted++
+			continue
+		}
+		s.Messages++
+		s.Tuples += int64(len(m.tuples))
+		if m.time.IsZero() {
+			continue
+		}
+		if s.Oldest.IsZero() || m.time.Before(s.Oldest) {
+			s.Oldest = m.time
+		}
+		if m.time.After(s.Newest) {
+			s.Newest = m.time
+		}
+	}
+	c.root.nodes(func(n *node) {
+		if len(n.entries) != 0 {
+			s.Prefixes++
+		}
+	})
+	if n := c.root.next[""]; n != nil {
+		s.Starts = int64(len(n.entries))
+	}
+	return &s, nil
+}
+
+// nodes calls f with every node in the subtree rooted at n.
+func (n *node) nodes(f func(*node)) {
+	f(n)
+	for _, m := range n.kids {
+		m.nodes(f)
+	}
+}
@@ -0,0 +1,79 @@
+package membrain
+
+import (
+	"context"
+	"slices"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+var _ brain.Tagger = (*Brain)(nil)
+
+// Tags lists the tags the brain knows, in sorted order.
+func (br *Brain) Tags(ctx context.Context) ([]string, error) {
+	br.mu.RLock()
+	defer br.mu.RUnlock()
+	tags := make([]string, 0, len(br.tags))
+	for tag, c := range br.tags {
+		if len(c.msgs) != 0 {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags, nil
+}
+
+// CopyTag copies everything the brain knows in one tag into another.
+func (br *Brain) CopyTag(ctx context.Context, from, to string) error {
+	if from == to {
+		return brain.ErrSameTag
+	}
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.copyLocked(from, to)
+	return nil
+}
+
+// RenameTag moves everything the brain knows in one tag into another.
+func (br *Brain) RenameTag(ctx context.Context, from, to string) error {
+	if from == to {
+		return brain.ErrSameTag
+	}
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	br.copyLocked(from, to)
+	delete(br.tags, from)
+	return nil
+}
+
+// DropTag permanently deletes everything the brain knows in a tag.
+func (br *Brain) DropTag(ctx context.Context, tag string) error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	delete(br.tags, tag)
+	return nil
+}
+
+// copyLocked copies the messages of one tag into another.
+// br.mu must be held for writing.
+func (br *Brain) copyLocked(from, to string) {
+	src := br.tags[from]
+	if src == nil {
+		return
+	}
+	dst := br.chainLocked(to)
+	for id, m := range src.msgs {
+		if dst.msgs[id] != nil {
+			continue
+		}
+		// Tuples are never modified once learned, so the copy can share them.
+		dst.insert(&message{
+			id:      m.id,
+			user:    m.user,
+			time:    m.time,
+			tuples:  m.tuples,
+			nodes:   make([]*node, 0, len(m.tuples)),
+			deleted: m.deleted,
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package brain
+
+import (
+	"context"
+	"math/rand/v2"
+)
+
+type randKey struct{}
+
+// globalSource is a [rand.Source] drawing from the global source.
+// It is safe for concurrent use.
+type globalSource struct{}
+
+func (globalSource) Uint64() uint64 { return rand.Uint64() }
+
+var globalRand = rand.New(globalSource{})
+
+// WithRand returns a context which causes brains to draw random numbers
+// from r when generating messages.
+// Since r is not generally safe for concurrent use, the resulting context
+// should be used for only one generation at a time.
+func WithRand(ctx context.Context, r *rand.Rand) context.Context {
+	return context.WithValue(ctx, randKey{}, r)
+}
+
+// WithSeed returns a context which causes brains to generate messages
+// deterministically according to seed.
+// The same seed with the same prompt and knowledge always produces the same
+// message.
+func WithSeed(ctx context.Context, seed uint64) context.Context {
+	return WithRand(ctx, NewRand(seed))
+}
+
+// NewRand creates a deterministic random source from a seed.
+func NewRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+// Rand returns the random source brains should use for ctx.
+// If ctx has no source attached by [WithRand] or [WithSeed], the result
+// draws from the global source and is safe for concurrent use.
+func Rand(ctx context.Context) *rand.Rand {
+	r, _ := ctx.Value(randKey{}).(*rand.Rand)
+	if r == nil {
+		return globalRand
+	}
+	return r
+}
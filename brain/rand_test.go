@@ -0,0 +1,26 @@
+package brain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+func TestRand(t *testing.T) {
+	ctx := context.Background()
+	if brain.Rand(ctx) == nil {
+		t.Fatal("no default source")
+	}
+	a, b := brain.Rand(brain.WithSeed(ctx, 1)), brain.Rand(brain.WithSeed(ctx, 1))
+	for i := range 16 {
+		x, y := a.Uint64(), b.Uint64()
+		if x != y {
+			t.Errorf("sources with the same seed differ at %d: %#x vs %#x", i, x, y)
+		}
+	}
+	r := brain.NewRand(2)
+	if got := brain.Rand(brain.WithRand(ctx, r)); got != r {
+		t.Errorf("wrong source: want %p, got %p", r, got)
+	}
+}
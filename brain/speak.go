@@ -13,6 +13,7 @@ import (
 type Speaker interface {
 	// Speak generates a full message and appends it to w.
 	// The prompt is in reverse order and has entropy reduction applied.
+	// Random choices should draw from [Rand] so that seeds are respected.
 	Speak(ctx context.Context, tag string, prompt []string, w *Builder) error
 }
 
@@ -25,6 +26,8 @@ var (
 // from the given prompt.
 // If the speaker does not produce any terms, the result is the empty string
 // regardless of the prompt, with no error.
+// If ctx is done after some terms have been generated, the result is the
+// partial message with an error wrapping [ErrTruncated] and the context error.
 func Speak(ctx context.Context, s Speaker, tag, prompt string) (string, []string, error) {
 	w := builderPool.Get()
 	toks := Tokens(tokensPool.Get(), prompt)
@@ -41,6 +44,9 @@ func Speak(ctx context.Context, s Speaker, tag, prompt string) (string, []string
 	slices.Reverse(toks)
 	err := s.Speak(ctx, tag, toks, w)
 	if err != nil {
+		if ctx.Err() != nil && len(w.Trace()) != 0 {
+			return strings.TrimSpace(w.String()), slices.Clone(w.Trace()), fmt.Errorf("%w: %w", ErrTruncated, ctx.Err())
+		}
 		return "", nil, fmt.Errorf("couldn't speak: %w", err)
 	}
 	if len(w.Trace()) == 0 {
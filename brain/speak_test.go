@@ -18,7 +18,7 @@ type testSpeaker struct {
 
 func (t *testSpeaker) Speak(ctx context.Context, tag string, prompt []string, w *brain.Builder) error {
 	t.prompt = prompt
-	w.Append(t.id, t.append)
+	w.Append(t.id, t.append, 0)
 	return nil
 }
 
@@ -7,6 +7,8 @@ import (
 
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/migrate"
 )
 
 // Brain is an implementation of knowledge using an SQLite database.
@@ -22,16 +24,49 @@ func Open(ctx context.Context, db *sqlitex.Pool) (*Brain, error) {
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get connection from pool: %w", err)
 	}
-	if err := sqlitex.ExecuteScript(conn, schemaSQL, nil); err != nil {
+	// WAL mode can't be set inside the migration transactions.
+	if err := sqlitex.ExecuteTransient(conn, `PRAGMA journal_mode = WAL`, nil); err != nil {
+		return nil, fmt.Errorf("couldn't set journal mode: %w", err)
+	}
+	if _, err := migrate.Apply(conn, &Schema); err != nil {
 		return nil, fmt.Errorf("couldn't run migration: %w", err)
 	}
 	br := Brain{db}
 	return &br, nil
 }
 
+// OpenCurrent returns a brain within a database whose schema is already up to
+// date. Unlike [Open], it never writes to the database, so db may be opened
+// read-only. The db must remain open for the lifetime of the brain.
+func OpenCurrent(ctx context.Context, db *sqlitex.Pool) (*Brain, error) {
+	conn, err := db.Take(ctx)
+	defer db.Put(conn)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get connection from pool: %w", err)
+	}
+	p, err := migrate.Pending(conn, &Schema)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't check schema: %w", err)
+	}
+	if len(p) != 0 {
+		return nil, fmt.Errorf("database schema is %d migrations behind", len(p))
+	}
+	br := Brain{db}
+	return &br, nil
+}
+
 //go:embed schema.sql
 var schemaSQL string
 
+// Schema is the list of migrations for sqlbrain databases.
+var Schema = migrate.Schema{
+	Name: "sqlbrain",
+	Migrations: []migrate.Migration{
+		{Name: "initial schema", SQL: schemaSQL},
+		{Name: "intern terms", SQL: internSQL, Func: migrateIntern},
+	},
+}
+
 // Close closes the underlying database.
 func (br *Brain) Close() error {
 	return br.db.Close()
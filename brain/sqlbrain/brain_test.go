@@ -12,6 +12,7 @@ import (
 	"github.com/zephyrtronium/robot/brain"
 	"github.com/zephyrtronium/robot/brain/braintest"
 	"github.com/zephyrtronium/robot/brain/sqlbrain"
+	"github.com/zephyrtronium/robot/migrate"
 )
 
 var dbCount atomic.Int64
@@ -46,3 +47,92 @@ func TestIntegrated(t *testing.T) {
 	}
 	braintest.Test(ctx, t, new)
 }
+
+func TestOpenCurrent(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx)
+	if _, err := sqlbrain.OpenCurrent(ctx, db); err == nil {
+		t.Error("opened a database with no schema")
+	}
+	conn, err := db.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := migrate.Version(conn, &sqlbrain.Schema)
+	db.Put(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0 {
+		t.Errorf("schema changed to version %d", v)
+	}
+	if _, err := sqlbrain.Open(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlbrain.OpenCurrent(ctx, db); err != nil {
+		t.Errorf("couldn't open migrated database: %v", err)
+	}
+}
+
+func TestMigrateIntern(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(ctx)
+	conn, err := db.Take(ctx)
+	defer db.Put(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Set up a database with only the original layout.
+	orig := sqlbrain.Schema
+	orig.Migrations = orig.Migrations[:1]
+	if _, err := migrate.Apply(conn, &orig); err != nil {
+		t.Fatalf("couldn't create original schema: %v", err)
+	}
+	old := []know{
+		{tag: "kessoku", id: "1", prefix: "\x00", suffix: "bocchi "},
+		{tag: "kessoku", id: "1", prefix: "bocchi \x00\x00", suffix: "ryo "},
+		{tag: "kessoku", id: "1", prefix: "ryo \x00bocchi \x00\x00", suffix: ""},
+		{tag: "kessoku", id: "2", prefix: "\x00", suffix: "bocchi ", deleted: ref("CLEARMSG")},
+		{tag: "kessoku", id: "2", prefix: "bocchi \x00\x00", suffix: "nijika ", deleted: ref("CLEARMSG")},
+		{tag: "kessoku", id: "2", prefix: "nijika \x00bocchi \x00\x00", suffix: "", deleted: ref("CLEARMSG")},
+		{tag: "sickhack", id: "3", prefix: "\x00", suffix: "kikuri "},
+		{tag: "sickhack", id: "3", prefix: "kikuri \x00\x00", suffix: ""},
+	}
+	for _, v := range old {
+		opts := sqlitex.ExecOptions{
+			Named: map[string]any{
+				":tag":     v.tag,
+				":id":      v.id,
+				":prefix":  []byte(v.prefix),
+				":suffix":  []byte(v.suffix),
+				":deleted": nil,
+			},
+		}
+		if v.deleted != nil {
+			opts.Named[":deleted"] = *v.deleted
+		}
+		err := sqlitex.Execute(conn, `INSERT INTO knowledge(tag, id, prefix, suffix, deleted) VALUES (:tag, :id, :prefix, :suffix, :deleted)`, &opts)
+		if err != nil {
+			t.Fatalf("couldn't insert old tuple: %v", err)
+		}
+	}
+
+	br, err := sqlbrain.Open(ctx, db)
+	if err != nil {
+		t.Fatalf("couldn't open brain: %v", err)
+	}
+	contents(t, conn, old, nil)
+	if p, err := migrate.Pending(conn, &sqlbrain.Schema); err != nil || len(p) != 0 {
+		t.Errorf("migrations still pending after open: %v (%v)", p, err)
+	}
+	var w brain.Builder
+	for range 20 {
+		w.Reset()
+		if err := br.Speak(ctx, "kessoku", nil, &w); err != nil {
+			t.Errorf("couldn't speak: %v", err)
+		}
+		if got, want := w.String(), "bocchi ryo "; got != want {
+			t.Errorf("wrong speech after migration: want %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package sqlbrain
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"iter"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+var _ brain.Exporter = (*Brain)(nil)
+
+// Messages iterates over the messages learned in tag in order of time.
+// A message counts as deleted if it or any of its tuples has been deleted.
+func (br *Brain) Messages(ctx context.Context, tag string, deleted bool) iter.Seq2[*brain.Message, error] {
+	return func(yield func(*brain.Message, error) bool) {
+		conn, err := br.db.Take(ctx)
+		defer br.db.Put(conn)
+		if err != nil {
+			yield(nil, fmt.Errorf("couldn't get connection to export messages: %w", err))
+			return
+		}
+		sm, err := conn.Prepare(`SELECT id, time, user, deleted FROM messages WHERE tag=:tag ORDER BY time, id`)
+		if err != nil {
+			yield(nil, fmt.Errorf("couldn't prepare message selection: %w", err))
+			return
+		}
+		defer sm.Reset()
+		sm.SetText(":tag", tag)
+		const selTuples = `
+			SELECT k.prefix, t.text, k.deleted FROM knowledge AS k
+				JOIN terms AS t ON t.id = k.suffix
+			WHERE k.tag=:tag AND k.id=:id
+			ORDER BY k.rowid
+		`
+		st, err := conn.Prepare(selTuples)
+		if err != nil {
+			yield(nil, fmt.Errorf("couldn't prepare tuple selection: %w", err))
+			return
+		}
+		st.SetText(":tag", tag)
+		memo := make(map[int64]string)
+		var b []byte
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			ok, err := sm.Step()
+			if err != nil {
+				yield(nil, fmt.Errorf("couldn't step message selection: %w", err))
+				return
+			}
+			if !ok {
+				return
+			}
+			m := brain.Message{
+				Tag:     tag,
+				ID:      sm.ColumnText(0),
+				Deleted: sm.ColumnText(3),
+			}
+			if sm.ColumnType(1) != sqlite.TypeNull {
+				m.Time = time.Unix(0, sm.ColumnInt64(1))
+			}
+			sm.ColumnBytes(2, m.User[:])
+			if m.Deleted != "" && !deleted {
+				continue
+			}
+			if len(memo) > 1<<16 {
+				clear(memo)
+			}
+			st.SetText(":id", m.ID)
+			for {
+				ok, err := st.Step()
+				if err != nil {
+					st.Reset()
+					yield(nil, fmt.Errorf("couldn't step tuple selection for message %s: %w", m.ID, err))
+					return
+				}
+				if !ok {
+					break
+				}
+				if m.Deleted == "" {
+					m.Deleted = st.ColumnText(2)
+				}
+				b = column(st, 0, b)
+				p, err := decodePrefix(conn, memo, b)
+				if err != nil {
+					st.Reset()
+					yield(nil, fmt.Errorf("couldn't decode prefix for message %s: %w", m.ID, err))
+					return
+				}
+				m.Tuples = append(m.Tuples, brain.Tuple{Prefix: p, Suffix: st.ColumnText(1)})
+			}
+			if err := st.Reset(); err != nil {
+				yield(nil, fmt.Errorf("couldn't reset tuple selection: %w", err))
+				return
+			}
+			// Messages can be deleted before they are learned, in which case
+			// there is nothing to export.
+			if len(m.Tuples) == 0 || m.Deleted != "" && !deleted {
+				continue
+			}
+			if !yield(&m, nil) {
+				return
+			}
+		}
+	}
+}
+
+// decodePrefix converts an encoded prefix back to its terms.
+func decodePrefix(conn *sqlite.Conn, memo map[int64]string, b []byte) ([]string, error) {
+	var p []string
+	for len(b) > 0 {
+		id, k := binary.Uvarint(b)
+		if k <= 0 {
+			return nil, fmt.Errorf("malformed prefix %q", b)
+		}
+		b = b[k:]
+		if id == startTerm {
+			if len(b) != 0 {
+				return nil, fmt.Errorf("start of message in middle of prefix")
+			}
+			break
+		}
+		w, err := termText(conn, memo, int64(id))
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, w)
+	}
+	return p, nil
+}
+
+// termText returns the text of an interned term.
+func termText(conn *sqlite.Conn, memo map[int64]string, id int64) (string, error) {
+	if w, ok := memo[id]; ok {
+		return w, nil
+	}
+	st, err := conn.Prepare(`SELECT text FROM terms WHERE id = :id`)
+	if err != nil {
+		return "", fmt.Errorf("couldn't prepare term text lookup: %w", err)
+	}
+	st.SetInt64(":id", id)
+	ok, err := st.Step()
+	if err != nil {
+		st.Reset()
+		return "", fmt.Errorf("couldn't look up term text: %w", err)
+	}
+	if !ok {
+		st.Reset()
+		return "", fmt.Errorf("no term with id %d", id)
+	}
+	w := st.ColumnText(0)
+	if err := st.Reset(); err != nil {
+		return "", fmt.Errorf("couldn't reset term text lookup: %w", err)
+	}
+	memo[id] = w
+	return w, nil
+}
@@ -54,7 +54,7 @@ func (br *Brain) ForgetMessage(ctx context.Context, tag, id string) (err error)
 }
 
 // ForgetDuring forgets all messages learned in the given time span.
-func (br *Brain) ForgetDuring(ctx context.Context, tag string, since time.Time, before time.Time) error {
+func (br *Brain) ForgetDuring(ctx context.Context, tag string, since time.Time, before time.Time) (err error) {
 	conn, err := br.db.Take(ctx)
 	defer br.db.Put(conn)
 	if err != nil {
@@ -98,7 +98,7 @@ func (br *Brain) ForgetDuring(ctx context.Context, tag string, since time.Time,
 }
 
 // ForgetUser forgets all messages associated with a userhash.
-func (br *Brain) ForgetUser(ctx context.Context, user *userhash.Hash) error {
+func (br *Brain) ForgetUser(ctx context.Context, user *userhash.Hash) (err error) {
 	conn, err := br.db.Take(ctx)
 	defer br.db.Put(conn)
 	if err != nil {
@@ -140,6 +140,53 @@ func (br *Brain) ForgetUser(ctx context.Context, user *userhash.Hash) error {
 	return nil
 }
 
+// Expire permanently deletes all messages in tag learned before the given
+// time, including tuples which have already been forgotten.
+// Messages with no recorded time are never expired.
+// Prefixes which are no longer used by any tuple are deleted as well.
+// Interned terms are kept, since on their own they are single words.
+func (br *Brain) Expire(ctx context.Context, tag string, before time.Time) (n int64, err error) {
+	conn, err := br.db.Take(ctx)
+	defer br.db.Put(conn)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get connection to expire messages: %w", err)
+	}
+	defer sqlitex.Transaction(conn)(&err)
+	// Unlike forgetting, expiring actually deletes data, so we don't keep the
+	// message rows around either.
+	const expireMessages = `DELETE FROM messages WHERE tag=:tag AND time < :before RETURNING id`
+	sm, err := conn.Prepare(expireMessages)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't prepare delete for expired messages: %w", err)
+	}
+	sm.SetText(":tag", tag)
+	sm.SetInt64(":before", before.UnixNano())
+	const expireTuples = `DELETE FROM knowledge WHERE tag=:tag AND id=:id`
+	st, err := conn.Prepare(expireTuples)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't prepare delete for expired tuples: %w", err)
+	}
+	st.SetText(":tag", tag)
+	for {
+		ok, err := sm.Step()
+		if err != nil {
+			return n, fmt.Errorf("couldn't step delete for expired messages: %w", err)
+		}
+		if !ok {
+			break
+		}
+		n++
+		st.SetText(":id", sm.GetText("id"))
+		if err := allsteps(st); err != nil {
+			return n, fmt.Errorf("couldn't step delete for expired tuples: %w", err)
+		}
+		if err := st.Reset(); err != nil {
+			return n, fmt.Errorf("couldn't reset delete for expired tuples: %w", err)
+		}
+	}
+	return n, nil
+}
+
 func allsteps(st *sqlite.Stmt) error {
 	for {
 		ok, err := st.Step()
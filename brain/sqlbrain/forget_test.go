@@ -6,6 +6,9 @@ import (
 	"testing"
 	"time"
 
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
 	"github.com/zephyrtronium/robot/brain"
 	"github.com/zephyrtronium/robot/brain/sqlbrain"
 	"github.com/zephyrtronium/robot/userhash"
@@ -1181,3 +1184,123 @@ func TestForgetUser(t *testing.T) {
 		})
 	}
 }
+
+func TestExpire(t *testing.T) {
+	learn := []learn{
+		{
+			tag:  "kessoku",
+			user: userhash.Hash{1},
+			id:   "2",
+			t:    3,
+			tups: []brain.Tuple{
+				{Prefix: strings.Fields("ryo bocchi"), Suffix: ""},
+				{Prefix: strings.Fields("bocchi"), Suffix: "ryo"},
+				{Prefix: nil, Suffix: "bocchi"},
+			},
+		},
+		{
+			tag:  "kessoku",
+			user: userhash.Hash{1},
+			id:   "5",
+			t:    6,
+			tups: []brain.Tuple{
+				{Prefix: []string{"bocchi"}, Suffix: ""},
+				{Prefix: nil, Suffix: "bocchi"},
+			},
+		},
+		{
+			tag:  "sickhack",
+			user: userhash.Hash{4},
+			id:   "2",
+			t:    3,
+			tups: []brain.Tuple{
+				{Prefix: []string{"kikuri"}, Suffix: ""},
+				{Prefix: nil, Suffix: "kikuri"},
+			},
+		},
+	}
+	cases := []struct {
+		name   string
+		tag    string
+		before int64
+		n      int64
+		// left is the number of knowledge and message rows remaining in each tag.
+		left map[string][2]int
+	}{
+		{
+			name:   "none",
+			tag:    "kessoku",
+			before: 3,
+			n:      0,
+			left:   map[string][2]int{"kessoku": {5, 2}, "sickhack": {2, 1}},
+		},
+		{
+			name:   "one",
+			tag:    "kessoku",
+			before: 4,
+			n:      1,
+			left:   map[string][2]int{"kessoku": {2, 1}, "sickhack": {2, 1}},
+		},
+		{
+			name:   "all",
+			tag:    "kessoku",
+			before: 7,
+			n:      2,
+			left:   map[string][2]int{"kessoku": {0, 0}, "sickhack": {2, 1}},
+		},
+		{
+			name:   "tagged",
+			tag:    "sickhack",
+			before: 7,
+			n:      1,
+			left:   map[string][2]int{"kessoku": {5, 2}, "sickhack": {0, 0}},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			db := testDB(ctx)
+			br, err := sqlbrain.Open(ctx, db)
+			if err != nil {
+				t.Fatalf("couldn't open brain: %v", err)
+			}
+			for _, m := range learn {
+				err := br.Learn(ctx, m.tag, m.id, m.user, time.Unix(0, m.t), m.tups)
+				if err != nil {
+					t.Errorf("failed to learn %v/%v: %v", m.tag, m.id, err)
+				}
+			}
+			n, err := br.Expire(ctx, c.tag, time.Unix(0, c.before))
+			if err != nil {
+				t.Errorf("couldn't expire: %v", err)
+			}
+			if n != c.n {
+				t.Errorf("wrong number of expired messages: want %d, got %d", c.n, n)
+			}
+			conn, err := db.Take(ctx)
+			defer db.Put(conn)
+			if err != nil {
+				t.Fatalf("couldn't get conn to check db state: %v", err)
+			}
+			for tag, want := range c.left {
+				var got [2]int
+				opts := sqlitex.ExecOptions{
+					Named: map[string]any{":tag": tag},
+					ResultFunc: func(stmt *sqlite.Stmt) error {
+						got[0] = stmt.ColumnInt(0)
+						got[1] = stmt.ColumnInt(1)
+						return nil
+					},
+				}
+				err := sqlitex.Execute(conn, `SELECT (SELECT COUNT(*) FROM knowledge WHERE tag=:tag), (SELECT COUNT(*) FROM messages WHERE tag=:tag)`, &opts)
+				if err != nil {
+					t.Errorf("couldn't count rows in %s: %v", tag, err)
+				}
+				if got != want {
+					t.Errorf("wrong rows left in %s: want %v, got %v", tag, want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package sqlbrain
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+//go:embed intern.sql
+var internSQL string
+
+// lookup returns the ID of a term, or 0 if the term has never been learned.
+// If memo is not nil, it is used to cache results.
+func lookup(conn *sqlite.Conn, memo map[string]int64, w string) (int64, error) {
+	if id, ok := memo[w]; ok {
+		return id, nil
+	}
+	st, err := conn.Prepare(`SELECT id FROM terms WHERE text = :text`)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't prepare term lookup: %w", err)
+	}
+	st.SetBytes(":text", []byte(w))
+	var id int64
+	ok, err := st.Step()
+	if err != nil {
+		return 0, fmt.Errorf("couldn't look up term: %w", err)
+	}
+	if ok {
+		id = st.ColumnInt64(0)
+	}
+	if err := st.Reset(); err != nil {
+		return 0, fmt.Errorf("couldn't reset term lookup: %w", err)
+	}
+	if memo != nil {
+		memo[w] = id
+	}
+	return id, nil
+}
+
+// intern returns the ID of a term, adding it if it doesn't yet exist.
+// If memo is not nil, it is used to cache results.
+func intern(conn *sqlite.Conn, memo map[string]int64, w string) (int64, error) {
+	id, err := lookup(conn, memo, w)
+	if err != nil || id != 0 {
+		return id, err
+	}
+	st, err := conn.Prepare(`INSERT INTO terms(text) VALUES (:text) RETURNING id`)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't prepare term insert: %w", err)
+	}
+	st.SetBytes(":text", []byte(w))
+	id, err = sqlitex.ResultInt64(st)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't insert term: %w", err)
+	}
+	if memo != nil {
+		memo[w] = id
+	}
+	return id, nil
+}
+
+// startTerm is the ID of the empty term, which marks the start of a message at
+// the end of every prefix.
+const startTerm = 1
+
+// appendTerm appends the encoding of a term ID to a prefix.
+func appendTerm(p []byte, id int64) []byte {
+	return binary.AppendUvarint(p, uint64(id))
+}
+
+// migrateIntern moves tuples from the original layout, which stored each
+// prefix in full as \x00-terminated terms, into the interned layout.
+func migrateIntern(conn *sqlite.Conn) error {
+	// The old table is about to be dropped, so don't cache statements on it.
+	sel, _, err := conn.PrepareTransient(`SELECT tag, id, prefix, suffix, deleted FROM knowledge_v1`)
+	if err != nil {
+		return fmt.Errorf("couldn't prepare old tuple selection: %w", err)
+	}
+	defer sel.Finalize()
+	ins, err := conn.Prepare(`INSERT INTO knowledge(tag, id, prefix, suffix, deleted) VALUES (:tag, :id, :prefix, :suffix, :deleted)`)
+	if err != nil {
+		return fmt.Errorf("couldn't prepare tuple insert: %w", err)
+	}
+	memo := make(map[string]int64)
+	var old, p, s []byte
+	for {
+		ok, err := sel.Step()
+		if err != nil {
+			return fmt.Errorf("couldn't step old tuple selection: %w", err)
+		}
+		if !ok {
+			break
+		}
+		if len(memo) > 1<<16 {
+			clear(memo)
+		}
+		tag := sel.ColumnText(0)
+		old = column(sel, 2, old)
+		// Every term is terminated by \x00, including the empty term at the
+		// end which marks the start of the message.
+		p = p[:0]
+		for len(old) > 0 {
+			k := bytes.IndexByte(old, 0)
+			if k < 0 {
+				return fmt.Errorf("malformed prefix %q in tag %s message %s", old, tag, sel.ColumnText(1))
+			}
+			id, err := intern(conn, memo, string(old[:k]))
+			if err != nil {
+				return err
+			}
+			p = appendTerm(p, id)
+			old = old[k+1:]
+		}
+		s = column(sel, 3, s)
+		sid, err := intern(conn, memo, string(s))
+		if err != nil {
+			return err
+		}
+		ins.SetText(":tag", tag)
+		ins.SetText(":id", sel.ColumnText(1))
+		ins.SetBytes(":prefix", p)
+		ins.SetInt64(":suffix", sid)
+		if sel.ColumnType(4) == sqlite.TypeNull {
+			ins.SetNull(":deleted")
+		} else {
+			ins.SetText(":deleted", sel.ColumnText(4))
+		}
+		if err := allsteps(ins); err != nil {
+			return fmt.Errorf("couldn't insert migrated tuple: %w", err)
+		}
+		if err := ins.Reset(); err != nil {
+			return fmt.Errorf("couldn't reset tuple insert: %w", err)
+		}
+	}
+	if err := sel.Reset(); err != nil {
+		return fmt.Errorf("couldn't reset old tuple selection: %w", err)
+	}
+	if err := sqlitex.ExecuteTransient(conn, `DROP TABLE knowledge_v1`, nil); err != nil {
+		return fmt.Errorf("couldn't drop old tuples: %w", err)
+	}
+	return nil
+}
+
+// column reads a blob column into b, reusing its storage if possible.
+func column(st *sqlite.Stmt, col int, b []byte) []byte {
+	n := st.ColumnLen(col)
+	if cap(b) < n {
+		b = make([]byte, n)
+	}
+	return b[:st.ColumnBytes(col, b[:n])]
+}
@@ -0,0 +1,44 @@
+-- Interned layout for tuples.
+-- Every distinct term is stored once in terms. Tuples then store their prefix
+-- as a sequence of term IDs and refer to their suffix by ID rather than
+-- repeating the text. Prefixes stay inline so that speaking searches a single
+-- index, as in the original layout.
+
+-- The old indexes must go first because their names are reused.
+DROP INDEX IF EXISTS ids;
+DROP INDEX IF EXISTS prefixes;
+ALTER TABLE knowledge RENAME TO knowledge_v1;
+
+CREATE TABLE terms (
+	-- Term ID. IDs start from 1; 0 never names a term.
+	id INTEGER PRIMARY KEY,
+	-- Term text. Holds both entropy-reduced prefix terms and full-entropy
+	-- suffixes.
+	text BLOB NOT NULL UNIQUE
+) STRICT;
+
+-- The empty term marks the start of a message at the end of a prefix, and
+-- the end of a message as a suffix. It always has ID 1.
+INSERT INTO terms(id, text) VALUES (1, x'');
+
+CREATE TABLE knowledge (
+	-- Tag or tenant for the entry.
+	tag TEXT NOT NULL,
+	-- Message ID, particularly UUID.
+	id TEXT NOT NULL,
+	-- Term IDs of the prefix in reverse order, each encoded as a uvarint.
+	-- Since uvarints are a prefix code, a prefix starts with the encoding of
+	-- a search context exactly when it starts with the context's terms.
+	-- Every prefix ends with the empty term, so x'01' alone is the start of
+	-- a message.
+	prefix BLOB NOT NULL,
+	-- Suffix term ID.
+	suffix INTEGER NOT NULL REFERENCES terms(id),
+	-- Reason for delete, if any.
+	-- Values are the same as in the original knowledge table:
+	-- 'FORGET', 'CLEARMSG', 'CLEARCHAT', 'TIME', or NULL if not deleted.
+	deleted TEXT
+) STRICT;
+
+CREATE INDEX ids ON knowledge (tag, id);
+CREATE INDEX prefixes ON knowledge (tag, prefix);
@@ -18,22 +18,40 @@ func (br *Brain) Learn(ctx context.Context, tag, id string, user userhash.Hash,
 	if err != nil {
 		return fmt.Errorf("couldn't get connection to learn: %w", err)
 	}
-	defer sqlitex.Transaction(conn)(&err)
+	// Learning reads before it writes. Take the write lock up front so that
+	// concurrent learners wait for each other instead of deadlocking.
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't start transaction to learn: %w", err)
+	}
+	defer end(&err)
 
 	st, err := conn.Prepare(`INSERT INTO knowledge(tag, id, prefix, suffix) VALUES (:tag, :id, :prefix, :suffix)`)
 	if err != nil {
 		return fmt.Errorf("couldn't prepare tuple insert: %w", err)
 	}
-	p := make([]byte, 0, 256)
-	s := make([]byte, 0, 32)
+	// Tuples from the same message share most of their terms.
+	memo := make(map[string]int64, len(tuples))
+	p := make([]byte, 0, 64)
 	for _, tt := range tuples {
-		p = append(prefix(p[:0], tt.Prefix), 0)
-		s = append(s[:0], tt.Suffix...)
+		p = p[:0]
+		for _, w := range tt.Prefix {
+			k, err := intern(conn, memo, w)
+			if err != nil {
+				return err
+			}
+			p = appendTerm(p, k)
+		}
+		p = appendTerm(p, startTerm)
+		sid, err := intern(conn, memo, tt.Suffix)
+		if err != nil {
+			return err
+		}
 		st.SetText(":tag", tag)
 		st.SetText(":id", id)
 		st.SetBytes(":prefix", p)
-		st.SetBytes(":suffix", s)
-		_, err := st.Step()
+		st.SetInt64(":suffix", sid)
+		_, err = st.Step()
 		if err != nil {
 			return fmt.Errorf("couldn't insert tuple: %w", err)
 		}
@@ -55,11 +73,3 @@ func (br *Brain) Learn(ctx context.Context, tag, id string, user userhash.Hash,
 
 	return nil
 }
-
-func prefix(b []byte, tup []string) []byte {
-	for _, w := range tup {
-		b = append(b, w...)
-		b = append(b, 0)
-	}
-	return b
-}
@@ -2,9 +2,12 @@ package sqlbrain_test
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -14,6 +17,7 @@ import (
 	"github.com/zephyrtronium/robot/brain"
 	"github.com/zephyrtronium/robot/brain/braintest"
 	"github.com/zephyrtronium/robot/brain/sqlbrain"
+	"github.com/zephyrtronium/robot/migrate"
 	"github.com/zephyrtronium/robot/userhash"
 )
 
@@ -43,14 +47,53 @@ type msg struct {
 
 func ref[T any](x T) *T { return &x }
 
+// term gets the interned ID of a term. If add is true, the term is interned
+// if it doesn't exist; otherwise, the result is 0 for unknown terms.
+func term(t *testing.T, conn *sqlite.Conn, w string, add bool) int64 {
+	t.Helper()
+	var id int64
+	opts := sqlitex.ExecOptions{
+		Named: map[string]any{":text": []byte(w)},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			id = stmt.ColumnInt64(0)
+			return nil
+		},
+	}
+	q := `SELECT id FROM terms WHERE text=:text`
+	if add {
+		q = `INSERT INTO terms(text) VALUES (:text) ON CONFLICT DO UPDATE SET text=excluded.text RETURNING id`
+	}
+	if err := sqlitex.Execute(conn, q, &opts); err != nil {
+		t.Fatalf("couldn't get term %q: %v", w, err)
+	}
+	return id
+}
+
+// encode converts a prefix written as each term terminated by \x00, ending
+// with the empty term, into its interned encoding.
+func encode(t *testing.T, conn *sqlite.Conn, prefix string, add bool) []byte {
+	t.Helper()
+	var b []byte
+	for prefix != "" {
+		w, rest, _ := strings.Cut(prefix, "\x00")
+		b = binary.AppendUvarint(b, uint64(term(t, conn, w, add)))
+		prefix = rest
+	}
+	return b
+}
+
 func contents(t *testing.T, conn *sqlite.Conn, know []know, msgs []msg) {
 	t.Helper()
+	const sel = `
+		SELECT COUNT(*) FROM knowledge AS k
+			JOIN terms AS s ON s.id = k.suffix
+		WHERE k.tag=:tag AND k.id=:id AND k.prefix=:prefix AND s.text=:suffix`
 	for _, want := range know {
 		opts := sqlitex.ExecOptions{
 			Named: map[string]any{
 				":tag":    want.tag,
 				":id":     want.id,
-				":prefix": []byte(want.prefix),
+				":prefix": encode(t, conn, want.prefix, false),
 				":suffix": []byte(want.suffix),
 			},
 			ResultFunc: func(stmt *sqlite.Stmt) error {
@@ -62,13 +105,13 @@ func contents(t *testing.T, conn *sqlite.Conn, know []know, msgs []msg) {
 			},
 		}
 		if want.deleted == nil {
-			err := sqlitex.Execute(conn, `SELECT COUNT(*) FROM knowledge WHERE tag=:tag AND id=:id AND prefix=:prefix AND suffix=:suffix AND deleted IS NULL`, &opts)
+			err := sqlitex.Execute(conn, sel+` AND k.deleted IS NULL`, &opts)
 			if err != nil {
 				t.Errorf("couldn't check for tag=%q id=%v prefix=%q suffix=%q: %v", want.tag, want.id, want.prefix, want.suffix, err)
 			}
 		} else {
 			opts.Named[":deleted"] = *want.deleted
-			err := sqlitex.Execute(conn, `SELECT COUNT(*) FROM knowledge WHERE tag=:tag AND id=:id AND prefix=:prefix AND suffix=:suffix AND deleted=:deleted`, &opts)
+			err := sqlitex.Execute(conn, sel+` AND k.deleted=:deleted`, &opts)
 			if err != nil {
 				t.Errorf("couldn't check for tag=%q id=%v prefix=%q suffix=%q: %v", want.tag, want.id, want.prefix, want.suffix, err)
 			}
@@ -413,3 +456,222 @@ func BenchmarkLearn(b *testing.B) {
 	}
 	braintest.BenchLearn(context.Background(), b, new, func(l brain.Learner) { l.(*sqlbrain.Brain).Close() })
 }
+
+// layouts are the sqlbrain layouts to compare in benchmarks: the original
+// layout, which stored every tuple's text in full, and the current interned
+// layout.
+var layouts = []struct {
+	name string
+	open func(ctx context.Context, b *testing.B, db *sqlitex.Pool) brain.Brain
+}{
+	{"v1", openV1},
+	{"interned", func(ctx context.Context, b *testing.B, db *sqlitex.Pool) brain.Brain {
+		br, err := sqlbrain.Open(ctx, db)
+		if err != nil {
+			b.Fatalf("couldn't open brain: %v", err)
+		}
+		return br
+	}},
+}
+
+func BenchmarkSize(b *testing.B) {
+	for _, layout := range layouts {
+		b.Run(layout.name, func(b *testing.B) {
+			var dbs atomic.Uint64
+			pools := make(map[brain.Learner]*sqlitex.Pool)
+			new := func(ctx context.Context, b *testing.B) brain.Learner {
+				k := dbs.Add(1)
+				dsn := fmt.Sprintf("file:%s/benchmark_size_%d.db", filepath.ToSlash(b.TempDir()), k)
+				db, err := sqlitex.NewPool(dsn, sqlitex.PoolOptions{PrepareConn: sqlbrain.RecommendedPrep})
+				if err != nil {
+					b.Fatal(err)
+				}
+				l := layout.open(ctx, b, db)
+				pools[l] = db
+				return l
+			}
+			size := func(l brain.Learner) int64 {
+				db := pools[l]
+				conn, err := db.Take(context.Background())
+				defer db.Put(conn)
+				if err != nil {
+					b.Fatal(err)
+				}
+				// Move everything out of the WAL so that page counts are accurate.
+				if err := sqlitex.ExecuteTransient(conn, `PRAGMA wal_checkpoint(TRUNCATE)`, nil); err != nil {
+					b.Fatalf("couldn't checkpoint: %v", err)
+				}
+				var n int64
+				opts := sqlitex.ExecOptions{
+					ResultFunc: func(stmt *sqlite.Stmt) error {
+						n = stmt.ColumnInt64(0)
+						return nil
+					},
+				}
+				if err := sqlitex.ExecuteTransient(conn, `SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`, &opts); err != nil {
+					b.Fatalf("couldn't get database size: %v", err)
+				}
+				return n
+			}
+			cleanup := func(l brain.Learner) {
+				pools[l].Close()
+				delete(pools, l)
+			}
+			braintest.BenchSize(context.Background(), b, new, size, cleanup)
+		})
+	}
+}
+
+// v1Brain learns into and speaks from the original sqlbrain layout, for
+// comparison.
+type v1Brain struct {
+	db *sqlitex.Pool
+}
+
+// openV1 creates a brain using the original layout in db.
+func openV1(ctx context.Context, b *testing.B, db *sqlitex.Pool) brain.Brain {
+	conn, err := db.Take(ctx)
+	defer db.Put(conn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := sqlitex.ExecuteTransient(conn, `PRAGMA journal_mode = WAL`, nil); err != nil {
+		b.Fatalf("couldn't set journal mode: %v", err)
+	}
+	orig := sqlbrain.Schema
+	orig.Migrations = orig.Migrations[:1]
+	if _, err := migrate.Apply(conn, &orig); err != nil {
+		b.Fatalf("couldn't create original schema: %v", err)
+	}
+	return &v1Brain{db: db}
+}
+
+// Learn records tuples the way sqlbrain did before interning.
+func (l *v1Brain) Learn(ctx context.Context, tag, id string, user userhash.Hash, t time.Time, tuples []brain.Tuple) (err error) {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return err
+	}
+	defer sqlitex.Transaction(conn)(&err)
+	st, err := conn.Prepare(`INSERT INTO knowledge(tag, id, prefix, suffix) VALUES (:tag, :id, :prefix, :suffix)`)
+	if err != nil {
+		return err
+	}
+	p := make([]byte, 0, 256)
+	for _, tt := range tuples {
+		p = p[:0]
+		for _, w := range tt.Prefix {
+			p = append(p, w...)
+			p = append(p, 0)
+		}
+		p = append(p, 0)
+		st.SetText(":tag", tag)
+		st.SetText(":id", id)
+		st.SetBytes(":prefix", p)
+		st.SetBytes(":suffix", []byte(tt.Suffix))
+		if _, err := st.Step(); err != nil {
+			return err
+		}
+		st.Reset()
+	}
+	sm, err := conn.Prepare(`INSERT INTO messages(tag, id, time, user) VALUES (:tag, :id, :time, :user)`)
+	if err != nil {
+		return err
+	}
+	sm.SetText(":tag", tag)
+	sm.SetText(":id", id)
+	sm.SetInt64(":time", t.UnixNano())
+	sm.SetBytes(":user", user[:])
+	_, err = sm.Step()
+	return err
+}
+
+func (l *v1Brain) ForgetMessage(ctx context.Context, tag, id string) error {
+	return errors.ErrUnsupported
+}
+
+func (l *v1Brain) ForgetDuring(ctx context.Context, tag string, since, before time.Time) error {
+	return errors.ErrUnsupported
+}
+
+func (l *v1Brain) ForgetUser(ctx context.Context, user *userhash.Hash) error {
+	return errors.ErrUnsupported
+}
+
+// Speak generates a message the way sqlbrain did before interning.
+func (l *v1Brain) Speak(ctx context.Context, tag string, prompt []string, w *brain.Builder) error {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return err
+	}
+	st, err := conn.Prepare(`SELECT id, suffix FROM knowledge WHERE tag = :tag AND prefix >= :lower AND prefix < :upper AND LIKELY(deleted IS NULL)`)
+	if err != nil {
+		return err
+	}
+	rng := brain.Rand(ctx)
+	search := append([]string{}, prompt...)
+	search = append(search, "")
+	var b, t []byte
+	for range 1024 {
+		l := len(search)
+		var id string
+		picked := 0
+		for {
+			// Each prefix term is followed by a 0 byte, so the supremum of all
+			// prefixes starting with the search is the same with its last
+			// byte replaced by 1.
+			b = b[:0]
+			for _, w := range search[:l] {
+				b = append(b, w...)
+				b = append(b, 0)
+			}
+			upper := append([]byte{}, b...)
+			upper[len(upper)-1] = 1
+			st.SetText(":tag", tag)
+			st.SetBytes(":lower", b)
+			st.SetBytes(":upper", upper)
+			var skip brain.Skip
+		sel:
+			for {
+				ok, err := st.Step()
+				if err != nil {
+					return err
+				}
+				if !ok {
+					break
+				}
+				id = st.ColumnText(0)
+				t = append(t[:0], st.ColumnText(1)...)
+				picked++
+				for range skip.N(rng.Uint64(), rng.Uint64()) {
+					ok, err := st.Step()
+					if err != nil {
+						return err
+					}
+					if !ok {
+						break sel
+					}
+				}
+			}
+			if err := st.Reset(); err != nil {
+				return err
+			}
+			if picked < 3 && l > 3 {
+				l--
+				continue
+			}
+			break
+		}
+		if picked == 0 || len(t) == 0 {
+			return nil
+		}
+		// The search always ends with the empty string marking the start of
+		// the message, which isn't a term of context. Restore one term of
+		// context we dropped, if any.
+		w.Append(id, t, min(l, len(search)-1))
+		search = append([]string{brain.ReduceEntropy(string(t))}, search[:min(l+1, len(search))]...)
+	}
+	return nil
+}
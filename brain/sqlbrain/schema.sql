@@ -1,5 +1,3 @@
-PRAGMA journal_mode = WAL;
-
 CREATE TABLE IF NOT EXISTS knowledge (
 	-- Tag or tenant for the entry.
 	tag TEXT NOT NULL,
@@ -3,6 +3,7 @@ package sqlbrain
 import (
 	"context"
 	"fmt"
+	"iter"
 	"math/rand/v2"
 
 	"zombiezen.com/go/sqlite"
@@ -14,44 +15,79 @@ import (
 
 var prependerPool tpool.Pool[deque.Deque[string]]
 
+var _ brain.Streamer = (*Brain)(nil)
+
 // Speak generates a full message and appends it to w.
 // The prompt is in reverse order and has entropy reduction applied.
 func (br *Brain) Speak(ctx context.Context, tag string, prompt []string, w *brain.Builder) error {
-	search := prependerPool.Get().Append("").Prepend(prompt...)
-	defer func() { prependerPool.Put(search.Reset()) }()
-
-	conn, err := br.db.Take(ctx)
-	defer br.db.Put(conn)
-	if err != nil {
-		return fmt.Errorf("couldn't get connection to speak: %w", err)
+	for t, err := range br.Stream(ctx, tag, prompt) {
+		if err != nil {
+			return err
+		}
+		w.Append(t.ID, []byte(t.Text), t.Context)
 	}
+	return nil
+}
 
-	b := make([]byte, 0, 128)
-	for range 1024 {
-		var err error
-		var l int
-		var id string
-		b, id, l, err = next(conn, tag, b, search.Slice())
+// Stream generates a message, yielding each term as it is chosen.
+// The prompt is in reverse order and has entropy reduction applied.
+// If ctx is canceled between terms, its error is yielded.
+func (br *Brain) Stream(ctx context.Context, tag string, prompt []string) iter.Seq2[brain.Term, error] {
+	return func(yield func(brain.Term, error) bool) {
+		search := prependerPool.Get().Append("").Prepend(prompt...)
+		defer func() { prependerPool.Put(search.Reset()) }()
+
+		conn, err := br.db.Take(ctx)
+		defer br.db.Put(conn)
 		if err != nil {
-			return err
+			yield(brain.Term{}, fmt.Errorf("couldn't get connection to speak: %w", err))
+			return
 		}
-		if len(b) == 0 {
-			break
+
+		rng := brain.Rand(ctx)
+		b := make([]byte, 0, 128)
+		memo := make(map[string]int64)
+		for range 1024 {
+			if err := ctx.Err(); err != nil {
+				yield(brain.Term{}, err)
+				return
+			}
+			var err error
+			var l int
+			var id string
+			b, id, l, err = next(conn, rng, tag, b, search.Slice(), memo)
+			if err != nil {
+				yield(brain.Term{}, err)
+				return
+			}
+			if len(b) == 0 {
+				break
+			}
+			t := string(b)
+			// The search always ends with the empty string marking the start
+			// of the message, which isn't a term of context.
+			c := min(l, search.Len()-1)
+			if !yield(brain.Term{Text: t, ID: id, Context: c}, nil) {
+				return
+			}
+			search = search.DropEnd(search.Len() - l - 1).Prepend(brain.ReduceEntropy(t))
 		}
-		w.Append(id, b)
-		search = search.DropEnd(search.Len() - l - 1).Prepend(brain.ReduceEntropy(string(b)))
 	}
-	return nil
 }
 
-func next(conn *sqlite.Conn, tag string, b []byte, prompt []string) ([]byte, string, int, error) {
+func next(conn *sqlite.Conn, rng *rand.Rand, tag string, b []byte, prompt []string, memo map[string]int64) ([]byte, string, int, error) {
 	var id string
 	if len(prompt) == 0 {
 		var err error
-		b, id, err = first(conn, tag, b)
+		b, id, err = first(conn, rng, tag, b)
 		return b, id, 0, err
 	}
-	st, err := conn.Prepare(`SELECT id, suffix FROM knowledge WHERE tag = :tag AND prefix >= :lower AND prefix < :upper AND LIKELY(deleted IS NULL)`)
+	const sel = `
+		SELECT k.id, t.text FROM knowledge AS k
+			JOIN terms AS t ON t.id = k.suffix
+		WHERE k.tag = :tag AND k.prefix >= :lower AND k.prefix < :upper AND LIKELY(k.deleted IS NULL)
+	`
+	st, err := conn.Prepare(sel)
 	if err != nil {
 		return b[:0], "", len(prompt), fmt.Errorf("couldn't prepare term selection: %w", err)
 	}
@@ -61,7 +97,15 @@ func next(conn *sqlite.Conn, tag string, b []byte, prompt []string) ([]byte, str
 	var skip brain.Skip
 	picked := 0
 	for {
-		b = prefix(b[:0], prompt)
+		b = b[:0]
+		for _, w := range prompt {
+			// Terms we've never seen get ID 0, which matches nothing.
+			k, err := lookup(conn, memo, w)
+			if err != nil {
+				return b[:0], "", len(prompt), err
+			}
+			b = appendTerm(b, k)
+		}
 		b, d = searchbounds(b)
 		st.SetBytes(":lower", b)
 		st.SetBytes(":upper", d)
@@ -81,7 +125,7 @@ func next(conn *sqlite.Conn, tag string, b []byte, prompt []string) ([]byte, str
 			}
 			w = w[:st.ColumnBytes(1, w[:n])]
 			picked++
-			for range skip.N(rand.Uint64(), rand.Uint64()) {
+			for range skip.N(rng.Uint64(), rng.Uint64()) {
 				ok, err := st.Step()
 				if err != nil {
 					return b[:0], "", len(prompt), fmt.Errorf("couldn't step term selection: %w", err)
@@ -112,18 +156,23 @@ func searchbounds(prefix []byte) (lower, upper []byte) {
 	lower = append(prefix, prefix...)
 	lower, upper = lower[:len(prefix)], lower[len(prefix):]
 	if len(upper) != 0 {
-		// The prefix is a list of terms each followed by a 0 byte.
-		// So, the supremum of all strings with that prefix is the same with
-		// the last byte replaced by 1.
-		upper[len(upper)-1] = 1
+		// The prefix is a list of uvarints, so its last byte is always below
+		// 0x80. The supremum of all strings with that prefix is therefore the
+		// same with the last byte incremented.
+		upper[len(upper)-1]++
 	}
 	return lower, upper
 }
 
-func first(conn *sqlite.Conn, tag string, b []byte) ([]byte, string, error) {
+func first(conn *sqlite.Conn, rng *rand.Rand, tag string, b []byte) ([]byte, string, error) {
 	var id string
 	b = b[:0] // in case we get no rows
-	s, err := conn.Prepare(`SELECT id, suffix FROM knowledge WHERE tag = :tag AND prefix = x'00' AND LIKELY(deleted IS NULL)`)
+	const sel = `
+		SELECT k.id, t.text FROM knowledge AS k
+			JOIN terms AS t ON t.id = k.suffix
+		WHERE k.tag = :tag AND k.prefix = x'01' AND LIKELY(k.deleted IS NULL)
+	`
+	s, err := conn.Prepare(sel)
 	if err != nil {
 		return b, "", fmt.Errorf("couldn't prepare first term selection: %w", err)
 	}
@@ -144,7 +193,7 @@ sel:
 			b = make([]byte, n)
 		}
 		b = b[:s.ColumnBytes(1, b[:n])]
-		for range skip.N(rand.Uint64(), rand.Uint64()) {
+		for range skip.N(rng.Uint64(), rng.Uint64()) {
 			ok, err := s.Step()
 			if err != nil {
 				return b[:0], "", fmt.Errorf("couldn't step first term selection: %w", err)
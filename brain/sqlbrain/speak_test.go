@@ -479,8 +479,8 @@ func insert(t *testing.T, conn *sqlite.Conn, know []know, msgs []msg) {
 			Named: map[string]any{
 				":tag":    v.tag,
 				":id":     v.id[:],
-				":prefix": []byte(v.prefix),
-				":suffix": []byte(v.suffix),
+				":prefix": encode(t, conn, v.prefix, true),
+				":suffix": term(t, conn, v.suffix, true),
 			},
 		}
 		var err error
@@ -517,22 +517,25 @@ func insert(t *testing.T, conn *sqlite.Conn, know []know, msgs []msg) {
 }
 
 func BenchmarkSpeak(b *testing.B) {
-	var dbs atomic.Uint64
-	new := func(ctx context.Context, b *testing.B) brain.Brain {
-		k := dbs.Add(1)
-		db, err := sqlitex.NewPool(fmt.Sprintf("file:%s/bench-%d.sql", b.TempDir(), k), sqlitex.PoolOptions{PrepareConn: sqlbrain.RecommendedPrep})
-		if err != nil {
-			b.Fatal(err)
-		}
-		br, err := sqlbrain.Open(ctx, db)
-		if err != nil {
-			b.Fatal(err)
-		}
-		return br
-	}
-	cleanup := func(l brain.Brain) {
-		br := l.(*sqlbrain.Brain)
-		br.Close()
+	for _, layout := range layouts {
+		b.Run(layout.name, func(b *testing.B) {
+			var dbs atomic.Uint64
+			pools := make(map[brain.Brain]*sqlitex.Pool)
+			new := func(ctx context.Context, b *testing.B) brain.Brain {
+				k := dbs.Add(1)
+				db, err := sqlitex.NewPool(fmt.Sprintf("file:%s/bench-%d.sql", b.TempDir(), k), sqlitex.PoolOptions{PrepareConn: sqlbrain.RecommendedPrep})
+				if err != nil {
+					b.Fatal(err)
+				}
+				br := layout.open(ctx, b, db)
+				pools[br] = db
+				return br
+			}
+			cleanup := func(br brain.Brain) {
+				pools[br].Close()
+				delete(pools, br)
+			}
+			braintest.BenchSpeak(context.Background(), b, new, cleanup)
+		})
 	}
-	braintest.BenchSpeak(context.Background(), b, new, cleanup)
 }
@@ -0,0 +1,72 @@
+package sqlbrain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+var _ brain.Statter = (*Brain)(nil)
+
+// Stats computes statistics for a tag.
+func (br *Brain) Stats(ctx context.Context, tag string) (*brain.Stats, error) {
+	conn, err := br.db.Take(ctx)
+	defer br.db.Put(conn)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get connection for stats: %w", err)
+	}
+	var s brain.Stats
+	{
+		const sel = `
+			SELECT
+				COUNT(*) FILTER (WHERE deleted IS NULL),
+				COUNT(*) FILTER (WHERE deleted IS NOT NULL),
+				MIN(time) FILTER (WHERE deleted IS NULL),
+				MAX(time) FILTER (WHERE deleted IS NULL)
+			FROM messages WHERE tag=:tag
+		`
+		opts := sqlitex.ExecOptions{
+			Named: map[string]any{":tag": tag},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				s.Messages = stmt.ColumnInt64(0)
+				s.Deleted = stmt.ColumnInt64(1)
+				if stmt.ColumnType(2) != sqlite.TypeNull {
+					s.Oldest = time.Unix(0, stmt.ColumnInt64(2))
+					s.Newest = time.Unix(0, stmt.ColumnInt64(3))
+				}
+				return nil
+			},
+		}
+		if err := sqlitex.Execute(conn, sel, &opts); err != nil {
+			return nil, fmt.Errorf("couldn't count messages: %w", err)
+		}
+	}
+	{
+		const sel = `
+			SELECT
+				COUNT(*),
+				COUNT(DISTINCT prefix),
+				COUNT(*) FILTER (WHERE prefix = x'01')
+			FROM knowledge
+			WHERE tag=:tag AND deleted IS NULL
+		`
+		opts := sqlitex.ExecOptions{
+			Named: map[string]any{":tag": tag},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				s.Tuples = stmt.ColumnInt64(0)
+				s.Prefixes = stmt.ColumnInt64(1)
+				s.Starts = stmt.ColumnInt64(2)
+				return nil
+			},
+		}
+		if err := sqlitex.Execute(conn, sel, &opts); err != nil {
+			return nil, fmt.Errorf("couldn't count tuples: %w", err)
+		}
+	}
+	return &s, nil
+}
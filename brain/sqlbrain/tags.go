@@ -0,0 +1,122 @@
+package sqlbrain
+
+import (
+	"context"
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+var _ brain.Tagger = (*Brain)(nil)
+
+// Tags lists the tags the brain knows, in sorted order.
+func (br *Brain) Tags(ctx context.Context) ([]string, error) {
+	conn, err := br.db.Take(ctx)
+	defer br.db.Put(conn)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get connection to list tags: %w", err)
+	}
+	// Every tuple belongs to a message, so messages has every tag.
+	const sel = `SELECT DISTINCT tag FROM messages ORDER BY tag`
+	var tags []string
+	opts := sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			tags = append(tags, stmt.ColumnText(0))
+			return nil
+		},
+	}
+	if err := sqlitex.Execute(conn, sel, &opts); err != nil {
+		return nil, fmt.Errorf("couldn't list tags: %w", err)
+	}
+	return tags, nil
+}
+
+// CopyTag copies everything the brain knows in one tag into another.
+func (br *Brain) CopyTag(ctx context.Context, from, to string) (err error) {
+	if from == to {
+		return brain.ErrSameTag
+	}
+	conn, err := br.db.Take(ctx)
+	defer br.db.Put(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't get connection to copy tag: %w", err)
+	}
+	defer sqlitex.Transaction(conn)(&err)
+	return copyTag(conn, from, to)
+}
+
+// RenameTag moves everything the brain knows in one tag into another.
+func (br *Brain) RenameTag(ctx context.Context, from, to string) (err error) {
+	if from == to {
+		return brain.ErrSameTag
+	}
+	conn, err := br.db.Take(ctx)
+	defer br.db.Put(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't get connection to rename tag: %w", err)
+	}
+	defer sqlitex.Transaction(conn)(&err)
+	// Messages already in the destination conflict with a plain update of the
+	// tag column, so copy and drop instead.
+	if err := copyTag(conn, from, to); err != nil {
+		return err
+	}
+	return dropTag(conn, from)
+}
+
+// DropTag permanently deletes everything the brain knows in a tag.
+func (br *Brain) DropTag(ctx context.Context, tag string) (err error) {
+	conn, err := br.db.Take(ctx)
+	defer br.db.Put(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't get connection to drop tag: %w", err)
+	}
+	defer sqlitex.Transaction(conn)(&err)
+	return dropTag(conn, tag)
+}
+
+// copyTag copies the knowledge and messages of one tag into another.
+// It must be called within a transaction.
+func copyTag(conn *sqlite.Conn, from, to string) error {
+	opts := sqlitex.ExecOptions{
+		Named: map[string]any{":from": from, ":to": to},
+	}
+	// Skip messages the destination already knows, including ones it has
+	// forgotten.
+	const knowledge = `
+		INSERT INTO knowledge(tag, id, prefix, suffix, deleted)
+		SELECT :to, k.id, k.prefix, k.suffix, k.deleted
+		FROM knowledge AS k
+		WHERE k.tag = :from
+			AND NOT EXISTS (SELECT 1 FROM messages WHERE tag = :to AND id = k.id)
+	`
+	if err := sqlitex.Execute(conn, knowledge, &opts); err != nil {
+		return fmt.Errorf("couldn't copy knowledge: %w", err)
+	}
+	const messages = `
+		INSERT OR IGNORE INTO messages(tag, id, time, user, deleted)
+		SELECT :to, id, time, user, deleted FROM messages WHERE tag = :from
+	`
+	if err := sqlitex.Execute(conn, messages, &opts); err != nil {
+		return fmt.Errorf("couldn't copy messages: %w", err)
+	}
+	return nil
+}
+
+// dropTag deletes the knowledge and messages of a tag.
+// It must be called within a transaction.
+func dropTag(conn *sqlite.Conn, tag string) error {
+	opts := sqlitex.ExecOptions{
+		Named: map[string]any{":tag": tag},
+	}
+	for _, table := range []string{"knowledge", "messages"} {
+		del := `DELETE FROM ` + table + ` WHERE tag = :tag`
+		if err := sqlitex.Execute(conn, del, &opts); err != nil {
+			return fmt.Errorf("couldn't drop %s: %w", table, err)
+		}
+	}
+	return nil
+}
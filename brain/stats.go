@@ -0,0 +1,32 @@
+package brain
+
+import (
+	"context"
+	"time"
+)
+
+// Stats summarizes the knowledge a brain has in a single tag.
+type Stats struct {
+	// Messages is the number of messages learned which have not been deleted.
+	Messages int64
+	// Deleted is the number of deleted messages the brain still records.
+	Deleted int64
+	// Tuples is the number of tuples which have not been deleted.
+	Tuples int64
+	// Prefixes is the number of distinct prefixes among tuples which have not
+	// been deleted.
+	Prefixes int64
+	// Starts is the number of tuples which start a message.
+	Starts int64
+	// Oldest and Newest are the times of the earliest and latest messages
+	// which have not been deleted.
+	// They are the zero time if there are no such messages with known times.
+	Oldest, Newest time.Time
+}
+
+// Statter is a [Learner] which can summarize its knowledge.
+type Statter interface {
+	// Stats computes statistics for a tag.
+	// It may need to examine everything the brain knows about the tag.
+	Stats(ctx context.Context, tag string) (*Stats, error)
+}
@@ -0,0 +1,136 @@
+package brain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Term is a single term of a generated message.
+type Term struct {
+	// Text is the full-entropy text of the term.
+	Text string
+	// ID is the ID of the message from which the term was learned.
+	// It is empty for terms which come from the prompt.
+	ID string
+	// Context is the number of terms preceding the term which matched when it
+	// was chosen.
+	Context int
+}
+
+// Streamer is a [Speaker] which can yield terms as it chooses them.
+type Streamer interface {
+	// Stream generates a message, yielding each term as it is chosen.
+	// The prompt is in reverse order and has entropy reduction applied.
+	// Generation stops when the consumer stops iterating.
+	// If an error occurs, it is yielded and iteration ends.
+	Stream(ctx context.Context, tag string, prompt []string) iter.Seq2[Term, error]
+}
+
+// Stream produces the terms of a new message from the given prompt.
+// The prompt's own terms are yielded first, with empty IDs.
+//
+// If s is not a [Streamer], the message is generated in full with Speak and
+// yielded term by term afterward.
+func Stream(ctx context.Context, s Speaker, tag, prompt string) iter.Seq2[Term, error] {
+	return func(yield func(Term, error) bool) {
+		toks := Tokens(tokensPool.Get(), prompt)
+		defer func() { tokensPool.Put(toks[:0]) }()
+		for i, t := range toks {
+			if !yield(Term{Text: t}, nil) {
+				return
+			}
+			toks[i] = ReduceEntropy(t)
+		}
+		slices.Reverse(toks)
+		if st, ok := s.(Streamer); ok {
+			for t, err := range st.Stream(ctx, tag, toks) {
+				if !yield(t, err) || err != nil {
+					return
+				}
+			}
+			return
+		}
+		w := builderPool.Get()
+		defer func() {
+			w.Reset()
+			builderPool.Put(w)
+		}()
+		if err := s.Speak(ctx, tag, toks, w); err != nil {
+			yield(Term{}, err)
+			return
+		}
+		m := w.String()
+		for _, sp := range w.Spans() {
+			t := Term{Text: m[sp.Start:sp.End], ID: sp.ID, Context: sp.Context}
+			if !yield(t, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ErrRejected is returned by [SpeakCheck] when a message is rejected.
+var ErrRejected = errors.New("message rejected")
+
+// ErrTruncated is returned along with a partial message when generation ends
+// early because its context is done.
+var ErrTruncated = errors.New("generation truncated")
+
+// SpeakCheck is like [Speak], but it calls check with the message generated
+// so far after each term is chosen. If check returns false, generation stops
+// and SpeakCheck returns [ErrRejected] along with the rejected partial message.
+// SpeakCheck also returns the spans of the generated terms, with offsets into
+// the returned message.
+//
+// If ctx is done after some terms have been generated, SpeakCheck returns the
+// partial message with an error wrapping [ErrTruncated] and the context error.
+func SpeakCheck(ctx context.Context, s Speaker, tag, prompt string, check func(msg string) bool) (string, []string, []Span, error) {
+	w := builderPool.Get()
+	defer func() {
+		w.Reset()
+		builderPool.Put(w)
+	}()
+	w.grow(len(prompt) + 1)
+	for t, err := range Stream(ctx, s, tag, prompt) {
+		if err != nil {
+			if ctx.Err() != nil && len(w.Trace()) != 0 {
+				m, spans := trimSpans(w.String(), w.Spans())
+				return m, slices.Clone(w.Trace()), spans, fmt.Errorf("%w: %w", ErrTruncated, ctx.Err())
+			}
+			return "", nil, nil, fmt.Errorf("couldn't speak: %w", err)
+		}
+		if t.ID == "" {
+			w.prompt(t.Text)
+			continue
+		}
+		w.Append(t.ID, []byte(t.Text), t.Context)
+		if m := w.String(); !check(m) {
+			m, spans := trimSpans(m, w.Spans())
+			return m, nil, spans, ErrRejected
+		}
+	}
+	if len(w.Trace()) == 0 {
+		return "", nil, nil, nil
+	}
+	m, spans := trimSpans(w.String(), w.Spans())
+	return m, slices.Clone(w.Trace()), spans, nil
+}
+
+// trimSpans trims space from a message and returns a copy of its spans
+// adjusted to refer to the trimmed message.
+func trimSpans(m string, spans []Span) (string, []Span) {
+	lead := len(m) - len(strings.TrimLeftFunc(m, unicode.IsSpace))
+	m = strings.TrimSpace(m)
+	r := make([]Span, len(spans))
+	for i, sp := range spans {
+		sp.Start = min(max(sp.Start-lead, 0), len(m))
+		sp.End = min(max(sp.End-lead, 0), len(m))
+		r[i] = sp
+	}
+	return m, r
+}
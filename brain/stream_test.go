@@ -0,0 +1,196 @@
+package brain_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+type testStreamer struct {
+	testSpeaker
+	terms []brain.Term
+}
+
+func (t *testStreamer) Stream(ctx context.Context, tag string, prompt []string) iter.Seq2[brain.Term, error] {
+	t.prompt = prompt
+	return func(yield func(brain.Term, error) bool) {
+		for _, term := range t.terms {
+			if err := ctx.Err(); err != nil {
+				yield(brain.Term{}, err)
+				return
+			}
+			if !yield(term, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	terms := []brain.Term{
+		{Text: "kita ", ID: "1"},
+		{Text: "ikuyo ", ID: "2"},
+		{Text: "seika ", ID: "1"},
+	}
+	cases := []struct {
+		name   string
+		s      brain.Speaker
+		prompt string
+		stop   int
+		want   []brain.Term
+	}{
+		{
+			name:   "streamer",
+			s:      &testStreamer{terms: terms},
+			prompt: "bocchi ryo",
+			stop:   -1,
+			want: []brain.Term{
+				{Text: "bocchi "},
+				{Text: "ryo "},
+				{Text: "kita ", ID: "1"},
+				{Text: "ikuyo ", ID: "2"},
+				{Text: "seika ", ID: "1"},
+			},
+		},
+		{
+			name:   "stop",
+			s:      &testStreamer{terms: terms},
+			prompt: "bocchi",
+			stop:   2,
+			want: []brain.Term{
+				{Text: "bocchi "},
+				{Text: "kita ", ID: "1"},
+			},
+		},
+		{
+			name:   "speaker",
+			s:      &testSpeaker{id: "kessoku", append: []byte("nijika")},
+			prompt: "bocchi ryo",
+			stop:   -1,
+			want: []brain.Term{
+				{Text: "bocchi "},
+				{Text: "ryo "},
+				{Text: "nijika", ID: "kessoku"},
+			},
+		},
+		{
+			name:   "empty",
+			s:      &testStreamer{},
+			prompt: "",
+			stop:   -1,
+			want:   nil,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var got []brain.Term
+			for term, err := range brain.Stream(context.Background(), c.s, "", c.prompt) {
+				if err != nil {
+					t.Errorf("couldn't stream: %v", err)
+				}
+				got = append(got, term)
+				if len(got) == c.stop {
+					break
+				}
+			}
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("wrong terms (+got/-want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSpeakCheck(t *testing.T) {
+	s := &testStreamer{
+		terms: []brain.Term{
+			{Text: "kita ", ID: "1", Context: 1},
+			{Text: "ikuyo ", ID: "2", Context: 2},
+			{Text: "seika ", ID: "3", Context: 3},
+		},
+	}
+	ctx := context.Background()
+	msg, trace, spans, err := brain.SpeakCheck(ctx, s, "", "bocchi", func(string) bool { return true })
+	if err != nil {
+		t.Errorf("couldn't speak: %v", err)
+	}
+	if msg != "bocchi kita ikuyo seika" {
+		t.Errorf("wrong message: %q", msg)
+	}
+	if diff := cmp.Diff([]string{"1", "2", "3"}, trace); diff != "" {
+		t.Errorf("wrong trace (+got/-want):\n%s", diff)
+	}
+	wantSpans := []brain.Span{
+		{ID: "1", Start: 7, End: 12, Context: 1},
+		{ID: "2", Start: 12, End: 18, Context: 2},
+		{ID: "3", Start: 18, End: 23, Context: 3},
+	}
+	if diff := cmp.Diff(wantSpans, spans); diff != "" {
+		t.Errorf("wrong spans (+got/-want):\n%s", diff)
+	}
+	var checked []string
+	check := func(m string) bool {
+		checked = append(checked, m)
+		return !strings.Contains(m, "ikuyo")
+	}
+	msg, trace, spans, err = brain.SpeakCheck(ctx, s, "", "bocchi", check)
+	if !errors.Is(err, brain.ErrRejected) {
+		t.Errorf("wrong error from rejected message: %v", err)
+	}
+	if msg != "bocchi kita ikuyo" {
+		t.Errorf("wrong rejected message: %q", msg)
+	}
+	if trace != nil {
+		t.Errorf("trace from rejected message: %q", trace)
+	}
+	// Spans of the rejected message identify where the bad term came from.
+	wantSpans[1].End = 17
+	if diff := cmp.Diff(wantSpans[:2], spans); diff != "" {
+		t.Errorf("wrong rejected spans (+got/-want):\n%s", diff)
+	}
+	// Generation must stop at the rejected term.
+	want := []string{"bocchi kita ", "bocchi kita ikuyo "}
+	if diff := cmp.Diff(want, checked); diff != "" {
+		t.Errorf("wrong checked messages (+got/-want):\n%s", diff)
+	}
+}
+
+func TestSpeakCheckTruncated(t *testing.T) {
+	s := &testStreamer{
+		terms: []brain.Term{
+			{Text: "kita ", ID: "1"},
+			{Text: "ikuyo ", ID: "2"},
+			{Text: "seika ", ID: "3"},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Cancel as soon as we have the first generated term.
+	check := func(string) bool {
+		cancel()
+		return true
+	}
+	msg, trace, _, err := brain.SpeakCheck(ctx, s, "", "bocchi", check)
+	if !errors.Is(err, brain.ErrTruncated) || !errors.Is(err, context.Canceled) {
+		t.Errorf("wrong error from truncated message: %v", err)
+	}
+	if msg != "bocchi kita" {
+		t.Errorf("wrong truncated message: %q", msg)
+	}
+	if diff := cmp.Diff([]string{"1"}, trace); diff != "" {
+		t.Errorf("wrong trace (+got/-want):\n%s", diff)
+	}
+	// With nothing generated, there is no partial message.
+	msg, trace, _, err = brain.SpeakCheck(ctx, s, "", "bocchi", check)
+	if err == nil || errors.Is(err, brain.ErrTruncated) {
+		t.Errorf("wrong error from canceled generation: %v", err)
+	}
+	if msg != "" || trace != nil {
+		t.Errorf("message from canceled generation: %q %q", msg, trace)
+	}
+}
@@ -0,0 +1,24 @@
+package brain
+
+import (
+	"context"
+	"errors"
+)
+
+// Tagger is a [Learner] which can administer the tags it holds.
+type Tagger interface {
+	// Tags lists the tags the brain knows, in sorted order.
+	Tags(ctx context.Context) ([]string, error)
+	// CopyTag copies everything the brain knows in one tag into another,
+	// including records of forgotten messages. Messages which are already
+	// known in the destination keep their existing knowledge.
+	CopyTag(ctx context.Context, from, to string) error
+	// RenameTag moves everything the brain knows in one tag into another,
+	// as by CopyTag followed by DropTag.
+	RenameTag(ctx context.Context, from, to string) error
+	// DropTag permanently deletes everything the brain knows in a tag.
+	DropTag(ctx context.Context, tag string) error
+}
+
+// ErrSameTag is returned when copying or renaming a tag to itself.
+var ErrSameTag = errors.New("source and destination tags are the same")
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
+	"github.com/go-json-experiment/json"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/brain/kvbrain"
+	"github.com/zephyrtronium/robot/brain/sqlbrain"
+)
+
+// openBrain opens a brain named by a DSN of the form sqlbrain:<SQLite
+// connection string> or kvbrain:<directory>.
+// If readOnly is true, the brain's database is opened read-only, and an
+// sqlbrain database must already have a current schema.
+// The returned function closes the brain's database.
+func openBrain(ctx context.Context, dsn string, readOnly bool) (brain.Brain, func() error, error) {
+	kind, loc, ok := strings.Cut(dsn, ":")
+	if !ok || loc == "" {
+		return nil, nil, fmt.Errorf("brain DSN %q must be sqlbrain:<connection string> or kvbrain:<directory>", dsn)
+	}
+	switch kind {
+	case "sqlbrain":
+		if readOnly {
+			db, err := sqlitex.NewPool(loc, sqlitex.PoolOptions{Flags: sqlite.OpenReadOnly | sqlite.OpenURI})
+			if err != nil {
+				return nil, nil, fmt.Errorf("couldn't open sqlbrain db: %w", err)
+			}
+			br, err := sqlbrain.OpenCurrent(ctx, db)
+			if err != nil {
+				db.Close()
+				return nil, nil, fmt.Errorf("couldn't open sqlbrain (use robot schema --apply to update it): %w", err)
+			}
+			return br, db.Close, nil
+		}
+		db, err := sqlitex.NewPool(loc, sqlitex.PoolOptions{PrepareConn: sqlbrain.RecommendedPrep})
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't open sqlbrain db: %w", err)
+		}
+		br, err := sqlbrain.Open(ctx, db)
+		if err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("couldn't open sqlbrain: %w", err)
+		}
+		return br, db.Close, nil
+	case "kvbrain":
+		opts := badger.DefaultOptions(loc)
+		opts = opts.WithLogger(nil)
+		opts = opts.WithCompression(options.None)
+		opts = opts.WithBloomFalsePositive(0)
+		opts = opts.WithReadOnly(readOnly)
+		db, err := badger.Open(opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("couldn't open kvbrain db: %w", err)
+		}
+		return kvbrain.New(db), db.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown brain kind %q in DSN; use sqlbrain or kvbrain", kind)
+	}
+}
+
+// migrateProgress is the progress of a brain migration, recorded so that an
+// interrupted migration can resume where it stopped.
+type migrateProgress struct {
+	// Tags maps each tag to the last message migrated from it.
+	Tags map[string]migratePos `json:"tags"`
+
+	// file is the checkpoint file name, or empty to track progress only in
+	// memory.
+	file string
+	// dirty indicates whether Tags has changed since it was last saved.
+	dirty bool
+}
+
+// migratePos is the position of a migration within a tag.
+type migratePos struct {
+	Time time.Time `json:"time"`
+	ID   string    `json:"id"`
+	Done bool      `json:"done,omitempty"`
+}
+
+// after reports whether m comes after the recorded position.
+// Messages are migrated in order of time, then ID.
+func (p migratePos) after(m *brain.Message) bool {
+	if c := m.Time.Compare(p.Time); c != 0 {
+		return c > 0
+	}
+	return m.ID > p.ID
+}
+
+// loadProgress loads a migration checkpoint file, if it exists.
+// If file is empty, progress is tracked only in memory.
+func loadProgress(file string) (*migrateProgress, error) {
+	p := migrateProgress{Tags: make(map[string]migratePos), file: file}
+	if file == "" {
+		return &p, nil
+	}
+	b, err := os.ReadFile(file)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// Fresh migration.
+	case err != nil:
+		return nil, fmt.Errorf("couldn't read checkpoint: %w", err)
+	case len(b) != 0:
+		if err := json.Unmarshal(b, &p); err != nil {
+			return nil, fmt.Errorf("couldn't parse checkpoint: %w", err)
+		}
+		if p.Tags == nil {
+			p.Tags = make(map[string]migratePos)
+		}
+	}
+	return &p, nil
+}
+
+// record notes the position of the last migrated message in a tag.
+// It is saved to the checkpoint file at the next flush.
+func (p *migrateProgress) record(tag string, pos migratePos) {
+	p.Tags[tag] = pos
+	p.dirty = true
+}
+
+// flush saves progress to the checkpoint file, if it has changed.
+// The file is replaced by writing and syncing a temporary file and renaming it,
+// so a crash leaves either the old checkpoint or the new one.
+func (p *migrateProgress) flush() error {
+	if p.file == "" || !p.dirty {
+		return nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	tmp := p.file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("couldn't write checkpoint: %w", err)
+	}
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err := errors.Join(err, f.Close()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("couldn't write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, p.file); err != nil {
+		return fmt.Errorf("couldn't write checkpoint: %w", err)
+	}
+	p.dirty = false
+	return nil
+}
+
+// Close saves any progress not yet written to the checkpoint file.
+func (p *migrateProgress) Close() error {
+	return p.flush()
+}
+
+// migrateTag copies every non-deleted message in a tag from src to dst,
+// starting after the position recorded in p.
+// Progress is saved once per second and when the tag is finished, so a
+// migration stopped by a hard crash may have learned messages after the saved
+// position. When dst is a [brain.Exporter], messages which dst already has
+// after that position are skipped, so that resuming doesn't fail on the
+// duplicates.
+// The result is the number of messages learned.
+func migrateTag(ctx context.Context, dst brain.Learner, src brain.Exporter, tag string, p *migrateProgress) (int64, error) {
+	pos, ok := p.Tags[tag]
+	if pos.Done {
+		slog.InfoContext(ctx, "already migrated", slog.String("tag", tag))
+		return 0, nil
+	}
+	have, err := learnedAfter(ctx, dst, tag, pos, ok)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for m, err := range src.Messages(ctx, tag, false) {
+		if err != nil {
+			return n, fmt.Errorf("couldn't read messages in %s: %w", tag, err)
+		}
+		if ok && !pos.after(m) {
+			continue
+		}
+		if _, dup := have[m.ID]; dup {
+			slog.InfoContext(ctx, "already learned", slog.String("tag", tag), slog.String("id", m.ID))
+			p.record(tag, migratePos{Time: m.Time, ID: m.ID})
+			continue
+		}
+		if err := dst.Learn(ctx, tag, m.ID, m.User, m.Time, m.Tuples); err != nil {
+			return n, fmt.Errorf("couldn't learn message %s in %s: %w", m.ID, tag, err)
+		}
+		n++
+		p.record(tag, migratePos{Time: m.Time, ID: m.ID})
+		select {
+		case <-t.C:
+			slog.InfoContext(ctx, "migrated", slog.String("tag", tag), slog.Int64("n", n), slog.Time("time", m.Time))
+			if err := p.flush(); err != nil {
+				return n, err
+			}
+		default: // do nothing
+		}
+	}
+	pos = p.Tags[tag]
+	pos.Done = true
+	p.record(tag, pos)
+	return n, p.flush()
+}
+
+// learnedAfter returns the IDs of messages that dst has already learned in tag
+// after pos, or all of them if resume is false. It is always empty if dst is
+// not a [brain.Exporter].
+func learnedAfter(ctx context.Context, dst brain.Learner, tag string, pos migratePos, resume bool) (map[string]struct{}, error) {
+	have := make(map[string]struct{})
+	ex, ok := dst.(brain.Exporter)
+	if !ok {
+		return have, nil
+	}
+	for m, err := range ex.Messages(ctx, tag, true) {
+		if err != nil {
+			return nil, fmt.Errorf("couldn't check for messages in %s: %w", tag, err)
+		}
+		if !resume || pos.after(m) {
+			have[m.ID] = struct{}{}
+		}
+	}
+	return have, nil
+}
+
+// countTuples counts the tuples of non-deleted messages in a tag.
+func countTuples(ctx context.Context, e brain.Exporter, tag string) (int64, error) {
+	var n int64
+	for m, err := range e.Messages(ctx, tag, false) {
+		if err != nil {
+			return n, fmt.Errorf("couldn't count tuples in %s: %w", tag, err)
+		}
+		n += int64(len(m.Tuples))
+	}
+	return n, nil
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+func TestOpenBrainBadDSN(t *testing.T) {
+	for _, dsn := range []string{"", "bocchi", "sqlbrain:", "anime:file:bocchi.db"} {
+		if _, _, err := openBrain(context.Background(), dsn, false); err == nil {
+			t.Errorf("opening %q succeeded", dsn)
+		}
+	}
+}
+
+func TestMigrateTag(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcDSN := "sqlbrain:file:" + filepath.ToSlash(filepath.Join(dir, "src.db"))
+	src, closeSrc, err := openBrain(ctx, srcDSN, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, closeDst, err := openBrain(ctx, "kvbrain:"+filepath.Join(dir, "dst"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDst()
+	msgs := []struct {
+		id   string
+		time time.Time
+		toks []string
+	}{
+		{"1", time.Unix(1, 0), []string{"bocchi "}},
+		{"2", time.Unix(2, 0), []string{"ryo "}},
+		{"3", time.Unix(2, 0), []string{"nijika "}},
+		{"4", time.Unix(3, 0), []string{"kita "}},
+	}
+	for _, m := range msgs {
+		if err := brain.Learn(ctx, src, "kessoku", m.id, userhash.Hash{1}, m.time, m.toks); err != nil {
+			t.Fatalf("couldn't learn: %v", err)
+		}
+	}
+	if err := src.ForgetMessage(ctx, "kessoku", "4"); err != nil {
+		t.Fatalf("couldn't forget: %v", err)
+	}
+	// Migrations read the source without writing to it.
+	if err := closeSrc(); err != nil {
+		t.Fatal(err)
+	}
+	src, closeSrc, err = openBrain(ctx, srcDSN, true)
+	if err != nil {
+		t.Fatalf("couldn't reopen source read-only: %v", err)
+	}
+	defer closeSrc()
+	// Resume from a checkpoint after the first message.
+	ck := filepath.Join(dir, "checkpoint.json")
+	if err := os.WriteFile(ck, []byte(`{"tags":{"kessoku":{"time":"1970-01-01T00:00:01Z","id":"1"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := loadProgress(ck)
+	if err != nil {
+		t.Fatalf("couldn't load checkpoint: %v", err)
+	}
+	n, err := migrateTag(ctx, dst, src.(brain.Exporter), "kessoku", p)
+	if err != nil {
+		t.Errorf("couldn't migrate: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("wrong number of migrated messages: want 2, got %d", n)
+	}
+	var got []string
+	for m, err := range dst.(brain.Exporter).Messages(ctx, "kessoku", false) {
+		if err != nil {
+			t.Fatalf("couldn't read migrated messages: %v", err)
+		}
+		got = append(got, m.ID)
+	}
+	if len(got) != 2 || got[0] != "2" || got[1] != "3" {
+		t.Errorf("wrong migrated messages: want [2 3], got %q", got)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("couldn't close checkpoint: %v", err)
+	}
+	// The checkpoint should now mark the tag as done.
+	p, err = loadProgress(ck)
+	if err != nil {
+		t.Fatalf("couldn't reload checkpoint: %v", err)
+	}
+	defer p.Close()
+	if pos := p.Tags["kessoku"]; !pos.Done || pos.ID != "3" {
+		t.Errorf("wrong checkpoint after migration: %+v", pos)
+	}
+	n, err = migrateTag(ctx, dst, src.(brain.Exporter), "kessoku", p)
+	if err != nil || n != 0 {
+		t.Errorf("finished tag migrated again: n=%d err=%v", n, err)
+	}
+	want, err := countTuples(ctx, src.(brain.Exporter), "kessoku")
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := countTuples(ctx, dst.(brain.Exporter), "kessoku")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first message was skipped, so the counts should differ by its two
+	// tuples.
+	if want-have != 2 {
+		t.Errorf("wrong tuple counts: source has %d, destination has %d", want, have)
+	}
+}
+
+// crashLearner is a learner which fails after learning a particular message,
+// as if the process died before recording migration progress.
+type crashLearner struct {
+	brain.Learner
+	brain.Exporter
+	after string
+}
+
+var errCrash = errors.New("crash")
+
+func (l *crashLearner) Learn(ctx context.Context, tag, id string, user userhash.Hash, t time.Time, tuples []brain.Tuple) error {
+	if err := l.Learner.Learn(ctx, tag, id, user, t, tuples); err != nil {
+		return err
+	}
+	if id == l.after {
+		return errCrash
+	}
+	return nil
+}
+
+func TestMigrateTagResumeAfterCrash(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src, closeSrc, err := openBrain(ctx, "kvbrain:"+filepath.Join(dir, "src"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSrc()
+	// sqlbrain refuses to learn the same message twice, so resuming only
+	// works if the migration notices what it already learned.
+	dst, closeDst, err := openBrain(ctx, "sqlbrain:file:"+filepath.ToSlash(filepath.Join(dir, "dst.db")), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeDst()
+	for i, id := range []string{"1", "2", "3", "4"} {
+		if err := brain.Learn(ctx, src, "kessoku", id, userhash.Hash{1}, time.Unix(int64(i+1), 0), []string{"bocchi ", id}); err != nil {
+			t.Fatalf("couldn't learn: %v", err)
+		}
+	}
+	ck := filepath.Join(dir, "checkpoint.json")
+	p, err := loadProgress(ck)
+	if err != nil {
+		t.Fatalf("couldn't load checkpoint: %v", err)
+	}
+	n, err := migrateTag(ctx, &crashLearner{Learner: dst, Exporter: dst.(brain.Exporter), after: "2"}, src.(brain.Exporter), "kessoku", p)
+	if !errors.Is(err, errCrash) {
+		t.Fatalf("wrong error from crashing migration: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("wrong number of messages before crash: want 1, got %d", n)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p, err = loadProgress(ck)
+	if err != nil {
+		t.Fatalf("couldn't reload checkpoint: %v", err)
+	}
+	if pos := p.Tags["kessoku"]; pos.ID != "1" {
+		t.Fatalf("wrong checkpoint after crash: %+v", pos)
+	}
+	// Crash again without saving progress, so that the checkpoint is behind
+	// several learned messages.
+	n, err = migrateTag(ctx, &crashLearner{Learner: dst, Exporter: dst.(brain.Exporter), after: "3"}, src.(brain.Exporter), "kessoku", p)
+	if !errors.Is(err, errCrash) {
+		t.Fatalf("wrong error from second crashing migration: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("wrong number of messages before second crash: want 0, got %d", n)
+	}
+	p, err = loadProgress(ck)
+	if err != nil {
+		t.Fatalf("couldn't reload checkpoint: %v", err)
+	}
+	defer p.Close()
+	n, err = migrateTag(ctx, dst, src.(brain.Exporter), "kessoku", p)
+	if err != nil {
+		t.Fatalf("couldn't resume: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("wrong number of messages after resuming: want 1, got %d", n)
+	}
+	if pos := p.Tags["kessoku"]; !pos.Done || pos.ID != "4" {
+		t.Errorf("wrong checkpoint after resuming: %+v", pos)
+	}
+	want, err := countTuples(ctx, src.(brain.Exporter), "kessoku")
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, err := countTuples(ctx, dst.(brain.Exporter), "kessoku")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want != have {
+		t.Errorf("wrong tuple counts: source has %d, destination has %d", want, have)
+	}
+}
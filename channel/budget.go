@@ -0,0 +1,68 @@
+package channel
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Names of the rate limit budgets for each kind of action.
+const (
+	// BudgetCopypasta is the budget for copying memes.
+	BudgetCopypasta = "copypasta"
+	// BudgetRandom is the budget for random responses to chat.
+	BudgetRandom = "random"
+	// BudgetCommand is the budget for generated responses to commands.
+	BudgetCommand = "command"
+	// BudgetSystem is the budget for fixed responses to commands.
+	BudgetSystem = "system"
+)
+
+// BudgetNames is the list of all budget names.
+var BudgetNames = []string{BudgetCopypasta, BudgetRandom, BudgetCommand, BudgetSystem}
+
+// Names of limits other than budgets which can reject actions.
+const (
+	// LimitShared is the name of the channel's rate limit shared by all
+	// actions.
+	LimitShared = "shared"
+	// LimitGlobalCopypasta is the name of the copypasta rate limit shared by
+	// all channels.
+	LimitGlobalCopypasta = "global-copypasta"
+)
+
+// Reserve reserves one action at t against the named budget, the channel's
+// shared rate limit, and for copypasta, the global copypasta limit.
+// If any of them would delay the action, nothing is consumed, and the result
+// names the limit that rejected it and the delay until it would allow the
+// action. Otherwise the name is empty.
+func (ch *Channel) Reserve(t time.Time, budget string) (string, time.Duration) {
+	type limit struct {
+		name string
+		lim  *rate.Limiter
+	}
+	lims := make([]limit, 0, 3)
+	if l := ch.Budgets[budget]; l != nil {
+		lims = append(lims, limit{budget, l})
+	}
+	if budget == BudgetCopypasta && ch.Copypasta != nil {
+		lims = append(lims, limit{LimitGlobalCopypasta, ch.Copypasta})
+	}
+	if ch.Rate != nil {
+		lims = append(lims, limit{LimitShared, ch.Rate})
+	}
+	rs := make([]*rate.Reservation, 0, len(lims))
+	for _, l := range lims {
+		r := l.lim.ReserveN(t, 1)
+		if d := r.DelayFrom(t); d > 0 {
+			// Give back everything we took, including this one.
+			r.CancelAt(t)
+			for _, r := range rs {
+				r.CancelAt(t)
+			}
+			return l.name, d
+		}
+		rs = append(rs, r)
+	}
+	return "", 0
+}
@@ -0,0 +1,50 @@
+package channel_test
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/zephyrtronium/robot/channel"
+)
+
+func TestReserve(t *testing.T) {
+	ch := channel.Channel{
+		Rate: rate.NewLimiter(rate.Every(time.Minute), 2),
+		Budgets: map[string]*rate.Limiter{
+			channel.BudgetCopypasta: rate.NewLimiter(rate.Every(time.Minute), 1),
+			channel.BudgetRandom:    rate.NewLimiter(rate.Inf, 0),
+		},
+		Copypasta: rate.NewLimiter(rate.Every(time.Hour), 1),
+	}
+	tm := time.Unix(0, 0)
+	steps := []struct {
+		budget string
+		want   string
+	}{
+		{channel.BudgetCopypasta, ""},
+		{channel.BudgetCopypasta, channel.BudgetCopypasta},
+		{channel.BudgetRandom, ""},
+		{channel.BudgetRandom, channel.LimitShared},
+	}
+	for i, s := range steps {
+		got, d := ch.Reserve(tm, s.budget)
+		if got != s.want {
+			t.Errorf("wrong limit at step %d: want %q, got %q", i, s.want, got)
+		}
+		if got != "" && d <= 0 {
+			t.Errorf("no delay for rejection at step %d", i)
+		}
+	}
+	// After a minute, the copypasta budget and shared limit have room, but
+	// the global copypasta limit doesn't. The rejection must not consume
+	// the others.
+	tm = tm.Add(time.Minute)
+	if got, _ := ch.Reserve(tm, channel.BudgetCopypasta); got != channel.LimitGlobalCopypasta {
+		t.Errorf("wrong limit for global copypasta: want %q, got %q", channel.LimitGlobalCopypasta, got)
+	}
+	if got, _ := ch.Reserve(tm, channel.BudgetRandom); got != "" {
+		t.Errorf("rejected copypasta consumed shared limit: got %q", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"gitlab.com/zephyrtronium/pick"
 	"golang.org/x/time/rate"
@@ -17,6 +18,47 @@ type Channel struct {
 	Message func(ctx context.Context, reply, text string)
 	// Learn and Send are the channel tags.
 	Learn, Send string
+	// Retain is the duration for which messages learned from the channel are
+	// kept. Zero means forever.
+	Retain time.Duration
+	// Rate is the rate limiter for messages shared by all actions.
+	// Attempts to speak in excess of the rate limit are dropped.
+	// Reloading adjusts its limit in place.
+	Rate *rate.Limiter
+	// Budgets is the rate limiter for each kind of action, keyed by budget
+	// name, in addition to Rate. Reloading adjusts their limits in place.
+	Budgets map[string]*rate.Limiter
+	// Copypasta is the rate limiter for copypasta, in addition to Rate.
+	// It may be shared among channels.
+	Copypasta *rate.Limiter
+	// Users limits individual users' commands and tracks users who are
+	// temporarily ignored for spamming them. It is nil if there are no
+	// per-user limits.
+	Users *UserLimits
+	// History is a list of recent messages seen in the channel.
+	// Messages forgotten due to moderation are removed from it.
+	History *History
+	// Pregen is the queue of messages generated in advance for the channel.
+	// It is nil if pre-generation is disabled.
+	Pregen *Pregen
+	// Memery is the meme detector for the channel.
+	Memery *MemeDetector
+	// Velocity tracks the rate of messages in the channel.
+	Velocity *Velocity
+	// Extra is extra channel data that may be added by commands.
+	Extra sync.Map // map[any]any; key is a type
+	// Enabled indicates whether the channel's stream is online, which allows
+	// learning unless the settings allow learning offline.
+	Enabled atomic.Bool
+	// Settings is the channel's current reloadable configuration.
+	// Reloading replaces it entirely, so each event should load it once
+	// to see a consistent view.
+	Settings atomic.Pointer[Settings]
+}
+
+// Settings is the part of a channel's configuration which can change while
+// the channel is in use.
+type Settings struct {
 	// Block is a regex that matches messages which should not be used for
 	// learning or copypasta.
 	Block *regexp.Regexp
@@ -25,25 +67,29 @@ type Channel struct {
 	// Responses is the probability that a received message will trigger a
 	// random response.
 	Responses float64
-	// Rate is the rate limiter for messages. Attempts to speak in excess of
-	// the rate limit are dropped.
-	Rate *rate.Limiter
+	// Adaptive, if not nil, replaces Responses with a probability computed
+	// from the channel's message rate.
+	Adaptive *Adaptive
+	// Budget is the longest time to spend generating a message.
+	// Generation which exceeds it is truncated. Zero means no limit.
+	Budget time.Duration
 	// Ignore is the set of ignored user IDs.
 	Ignore map[string]bool
 	// Mod is the set of designated moderators' user IDs.
 	Mod map[string]bool
-	// History is a list of recent messages seen in the channel.
-	// Note that messages which are forgotten due to moderation are not removed
-	// from this list in general.
-	History *History
-	// Memery is the meme detector for the channel.
-	Memery *MemeDetector
 	// Emotes is the distribution of emotes.
 	Emotes *pick.Dist[string]
 	// Effects is the distribution of effects.
 	Effects *pick.Dist[string]
-	// Extra is extra channel data that may be added by commands.
-	Extra sync.Map // map[any]any; key is a type
-	// Enabled indicates whether a channel is allowed to learn messages.
-	Enabled atomic.Bool
+	// Schedule is the channel's schedule of windows which change its
+	// behavior. It may be nil.
+	Schedule *Schedule
+	// Offline allows learning while the stream is offline.
+	Offline bool
+	// Idle is the duration of silence in chat after which the robot speaks
+	// unprompted. Zero disables idle speaking.
+	Idle time.Duration
+	// IdleMax is the longest to wait between idle messages while chat stays
+	// silent, as the wait doubles after each one. Zero means no limit.
+	IdleMax time.Duration
 }
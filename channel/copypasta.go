@@ -3,8 +3,12 @@ package channel
 import (
 	_ "embed"
 	"errors"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // MemeDetector is literally a meme detector.
@@ -17,29 +21,87 @@ type MemeDetector struct {
 	back *node
 	// counts tracks the expiry time of each message said by each user.
 	// Detected memes are recorded with the empty string as the user.
-	counts map[string]map[string]int64 // map[message]map[user]UnixMillis
+	counts map[string]map[string]int64 // map[key]map[user]UnixMillis
+	// variants tracks the expiry time of each original text of a message said
+	// by each user, so that each user counts once toward each variant.
+	variants map[string]map[string]map[string]int64 // map[key]map[text]map[user]UnixMillis
+	// grams holds the character bigrams of each key in counts when memes can
+	// be merely similar.
+	grams map[string]map[[2]rune]int
 
 	// need is the number of messages needed to trigger memery.
 	need int
 	// within is the duration to hold messages.
 	within time.Duration
+	// norm is the normalization applied to messages to compute their keys.
+	norm Normalization
+	// similarity is the minimum similarity for messages with different keys
+	// to count as the same meme. Zero means keys must be identical.
+	similarity float64
+	// minLength and minTokens are the shortest messages in runes and words
+	// that can be memes.
+	minLength, minTokens int
+	// ignore matches messages which can never be memes.
+	ignore *regexp.Regexp
+	// fresh is the duration to wait before copying the same meme again.
+	fresh time.Duration
 }
 
 // node is a node in a doubly linked list of messages sorted by time.
 type node struct {
 	older, newer *node
 
-	msg  string
+	msg  string // key
+	text string // original text
 	user string
 	exp  int64
 }
 
-// NewMemeDetector creates.
-func NewMemeDetector(need int, within time.Duration) *MemeDetector {
+// MemeConfig is the configuration for a [MemeDetector].
+type MemeConfig struct {
+	// Need is the number of distinct users who must send a message for it to
+	// be a meme. Zero disables memes entirely.
+	Need int
+	// Within is the duration in which users must send the message.
+	Within time.Duration
+	// Normalize is the normalization applied to messages before comparing.
+	Normalize Normalization
+	// Similarity is the minimum similarity, from 0 to 1, for normalized
+	// messages which differ to count as the same meme.
+	// Zero means messages must be identical after normalization.
+	Similarity float64
+	// MinLength is the minimum number of characters in a normalized message
+	// for it to be a meme.
+	MinLength int
+	// MinTokens is the minimum number of words in a normalized message for it
+	// to be a meme.
+	MinTokens int
+	// Ignore matches messages which are never memes, e.g. commands for other
+	// bots. If nil, no messages are ignored this way.
+	Ignore *regexp.Regexp
+	// Fresh is the duration after copying a meme before it can be copied
+	// again. Zero means fifteen minutes.
+	Fresh time.Duration
+}
+
+// NewMemeDetector creates a meme detector.
+func NewMemeDetector(cfg MemeConfig) *MemeDetector {
+	fresh := cfg.Fresh
+	if fresh <= 0 {
+		fresh = 15 * time.Minute
+	}
 	return &MemeDetector{
-		counts: make(map[string]map[string]int64),
-		need:   need,
-		within: within,
+		counts:     make(map[string]map[string]int64),
+		variants:   make(map[string]map[string]map[string]int64),
+		grams:      make(map[string]map[[2]rune]int),
+		need:       cfg.Need,
+		within:     cfg.Within,
+		norm:       cfg.Normalize,
+		similarity: cfg.Similarity,
+		minLength:  cfg.MinLength,
+		minTokens:  cfg.MinTokens,
+		ignore:     cfg.Ignore,
+		fresh:      fresh,
 	}
 }
 
@@ -48,6 +110,15 @@ func (m *MemeDetector) chopLocked(now int64) {
 	// For each expired node at the back:
 	for b != nil && b.exp <= now {
 		// We will drop this node.
+		if v := m.variants[b.msg]; b.user != "" && v[b.text][b.user] <= b.exp {
+			delete(v[b.text], b.user)
+			if len(v[b.text]) == 0 {
+				delete(v, b.text)
+			}
+			if len(v) == 0 {
+				delete(m.variants, b.msg)
+			}
+		}
 		// If the most recent expiry time from this user isn't newer than b,
 		// we also need to stop tracking it in the map.
 		if m.counts[b.msg][b.user] <= b.exp {
@@ -57,6 +128,7 @@ func (m *MemeDetector) chopLocked(now int64) {
 				// stop tracking the message as well to control memory usage.
 				if len(m.counts[b.msg]) == 0 {
 					delete(m.counts, b.msg)
+					delete(m.grams, b.msg)
 				}
 			}
 		}
@@ -71,7 +143,7 @@ func (m *MemeDetector) chopLocked(now int64) {
 	}
 }
 
-func (m *MemeDetector) insertLocked(msg, user string, exp int64) {
+func (m *MemeDetector) insertLocked(msg, text, user string, exp int64) {
 	if exp <= m.counts[msg][user] {
 		// This message is (somehow) older than another from the same user.
 		// We don't care about it.
@@ -79,13 +151,28 @@ func (m *MemeDetector) insertLocked(msg, user string, exp int64) {
 	}
 	new := &node{
 		msg:  msg,
+		text: text,
 		user: user,
 		exp:  exp,
 	}
 	if m.counts[msg] == nil {
 		m.counts[msg] = make(map[string]int64)
+		if m.similarity > 0 {
+			m.grams[msg] = bigrams(msg)
+		}
 	}
 	m.counts[msg][user] = new.exp
+	if user != "" {
+		v := m.variants[msg]
+		if v == nil {
+			v = make(map[string]map[string]int64)
+			m.variants[msg] = v
+		}
+		if v[text] == nil {
+			v[text] = make(map[string]int64)
+		}
+		v[text][user] = new.exp
+	}
 
 	if m.front == nil {
 		m.front, m.back = new, new
@@ -110,54 +197,230 @@ func (m *MemeDetector) insertLocked(msg, user string, exp int64) {
 	l.older, m.back = new, new
 }
 
-// Check determines whether a message is a meme. If it is not, the returned
-// error is NotCopypasta. Times passed to Check should be monotonic, as
-// messages outside the detector's threshold are removed.
-func (m *MemeDetector) Check(t time.Time, from, msg string) error {
+// eligible determines whether a message is allowed to be a meme at all.
+func (m *MemeDetector) eligible(msg string) bool {
+	if m.ignore != nil && m.ignore.MatchString(msg) {
+		return false
+	}
+	k := m.norm.Apply(msg)
+	if utf8.RuneCountInString(k) < m.minLength {
+		return false
+	}
+	if m.minTokens > 1 && len(strings.Fields(k)) < m.minTokens {
+		return false
+	}
+	return true
+}
+
+// keyLocked computes the key under which a message is counted.
+func (m *MemeDetector) keyLocked(msg string) string {
+	k := m.norm.Apply(msg)
+	if m.similarity <= 0 {
+		return k
+	}
+	if _, ok := m.counts[k]; ok {
+		return k
+	}
+	// Find the most similar message we're already tracking, breaking ties
+	// by key so that the choice doesn't depend on map order.
+	best, bs := k, 0.0
+	x := bigrams(k)
+	for c, y := range m.grams {
+		s := dice(x, y)
+		if s < m.similarity {
+			continue
+		}
+		if best == k || s > bs || s == bs && c < best {
+			best, bs = c, s
+		}
+	}
+	return best
+}
+
+// Check determines whether a message is a meme. If it is, the result is the
+// most common variant of the meme, which is msg unless normalization or
+// similarity allow others. If it is not, the returned error is
+// NotCopypasta. Times passed to Check should be monotonic, as messages
+// outside the detector's threshold are removed.
+func (m *MemeDetector) Check(t time.Time, from, msg string) (string, error) {
 	now := t.UnixMilli()
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if m.need <= 0 {
 		// This channel is memeless.
-		return ErrNotCopypasta
+		return "", ErrNotCopypasta
 	}
 	// Remove old messages and discard old memes.
 	m.chopLocked(now)
+	if !m.eligible(msg) {
+		// Too low effort to copy. Don't even track it.
+		return "", ErrNotCopypasta
+	}
 	// Insert the new message.
-	m.insertLocked(msg, from, now+m.within.Milliseconds())
+	k := m.keyLocked(msg)
+	m.insertLocked(k, msg, from, now+m.within.Milliseconds())
 	// Get the meme metric: number of distinct users who sent this message in
 	// the time window.
-	n := len(m.counts[msg])
+	n := len(m.counts[k])
 	if n < m.need {
-		return ErrNotCopypasta
+		return "", ErrNotCopypasta
 	}
 	// Genuine meme. But is it fresh?
-	if _, ok := m.counts[msg][""]; ok {
-		return ErrNotCopypasta
+	if _, ok := m.counts[k][""]; ok {
+		return "", ErrNotCopypasta
+	}
+	// It is, but it won't be for a while.
+	m.insertLocked(k, "", "", now+m.fresh.Milliseconds())
+	// Copy whatever most people said, preferring this message on ties.
+	// Break other ties by text so that the choice doesn't depend on map order.
+	r, c := msg, len(m.variants[k][msg])
+	for v, u := range m.variants[k] {
+		n := len(u)
+		if n > c || n == c && r != msg && v < r {
+			r, c = v, n
+		}
 	}
-	// It is, but not for the following fifteen minutes.
-	m.insertLocked(msg, "", now+15*60*1000)
-	return nil
+	return r, nil
 }
 
 // Block adds a message as a meme directly, preventing its reuse
-// for fifteen minutes from t.
+// for the freshness duration from t.
 func (m *MemeDetector) Block(t time.Time, msg string) {
 	now := t.UnixMilli()
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.insertLocked(msg, "", now+15*60*1000)
+	m.insertLocked(m.keyLocked(msg), "", "", now+m.fresh.Milliseconds())
 }
 
 // Unblock removes a message as a meme, allowing its reuse immediately.
 func (m *MemeDetector) Unblock(msg string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if m.counts[msg] != nil {
-		delete(m.counts[msg], "")
+	k := m.keyLocked(msg)
+	if m.counts[k] != nil {
+		delete(m.counts[k], "")
 	}
 }
 
 // ErrNotCopypasta is a sentinel error returned by MemeDetector.Check when a
 // message is not copypasta.
 var ErrNotCopypasta = errors.New("not copypasta")
+
+// Normalization is a set of transformations applied to messages before
+// comparing them for copypasta.
+type Normalization uint8
+
+const (
+	// FoldCase compares messages case-insensitively.
+	FoldCase Normalization = 1 << iota
+	// StripInvisible removes zero-width and other formatting characters,
+	// including tag characters and the characters chat clients insert to
+	// bypass duplicate message detection.
+	StripInvisible
+	// CollapseSpace trims whitespace and reduces internal runs of whitespace
+	// to a single space.
+	CollapseSpace
+	// TrimEmote removes a trailing word which looks like an emote, i.e. a word
+	// of ASCII letters, digits, and underscores containing an uppercase
+	// letter, following at least one other word.
+	TrimEmote
+)
+
+// Apply applies the normalization to a message.
+func (n Normalization) Apply(msg string) string {
+	if n&StripInvisible != 0 {
+		msg = strings.Map(func(r rune) rune {
+			if invisible(r) {
+				return -1
+			}
+			return r
+		}, msg)
+	}
+	if n&TrimEmote != 0 {
+		msg = trimEmote(msg)
+	}
+	if n&CollapseSpace != 0 {
+		msg = strings.Join(strings.Fields(msg), " ")
+	}
+	if n&FoldCase != 0 {
+		msg = strings.ToLower(msg)
+	}
+	return msg
+}
+
+// invisible reports whether r renders as nothing.
+func invisible(r rune) bool {
+	switch {
+	case r == '\u034f': // combining grapheme joiner
+		return true
+	case 0xe0000 <= r && r <= 0xe007f: // tags, including unassigned U+E0000
+		return true
+	}
+	return unicode.Is(unicode.Cf, r)
+}
+
+// trimEmote removes a trailing emote-like word from msg, if there is one and
+// it isn't the only word.
+func trimEmote(msg string) string {
+	t := strings.TrimRightFunc(msg, unicode.IsSpace)
+	i := strings.LastIndexFunc(t, unicode.IsSpace)
+	if i < 0 {
+		return msg
+	}
+	w := t[i+1:]
+	upper := false
+	for _, c := range w {
+		switch {
+		case 'A' <= c && c <= 'Z':
+			upper = true
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '_':
+		default:
+			return msg
+		}
+	}
+	if !upper {
+		return msg
+	}
+	return strings.TrimRightFunc(t[:i], unicode.IsSpace)
+}
+
+// similarity computes the Dice coefficient of the character bigrams of two
+// strings, from 0 for nothing in common to 1 for identical.
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	return dice(bigrams(a), bigrams(b))
+}
+
+// dice computes the Dice coefficient of two sets of bigrams.
+func dice(x, y map[[2]rune]int) float64 {
+	if len(x) == 0 || len(y) == 0 {
+		return 0
+	}
+	var c int
+	for g, n := range x {
+		c += min(n, y[g])
+	}
+	return 2 * float64(c) / float64(count(x)+count(y))
+}
+
+func bigrams(s string) map[[2]rune]int {
+	r := make(map[[2]rune]int)
+	var p rune = -1
+	for _, c := range s {
+		if p >= 0 {
+			r[[2]rune{p, c}]++
+		}
+		p = c
+	}
+	return r
+}
+
+func count(m map[[2]rune]int) int {
+	var n int
+	for _, v := range m {
+		n += v
+	}
+	return n
+}
@@ -1,6 +1,7 @@
 package channel_test
 
 import (
+	"regexp"
 	"testing"
 	"time"
 
@@ -107,9 +108,9 @@ func TestMemeDetector(t *testing.T) {
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			d := channel.NewMemeDetector(c.need, time.Duration(c.within)*time.Millisecond)
+			d := channel.NewMemeDetector(channel.MemeConfig{Need: c.need, Within: time.Duration(c.within) * time.Millisecond})
 			for _, m := range c.memes {
-				err := d.Check(time.UnixMilli(m.when), m.who, m.text)
+				_, err := d.Check(time.UnixMilli(m.when), m.who, m.text)
 				if err != m.err {
 					t.Errorf("wrong error for %+v: want %v, got %v", m, m.err, err)
 				}
@@ -129,15 +130,248 @@ func TestUnblock(t *testing.T) {
 		{1, "ryo", "madoka", nil},
 		{2, "nijika", "madoka", channel.ErrNotCopypasta},
 	}
-	d := channel.NewMemeDetector(2, time.Minute)
+	d := channel.NewMemeDetector(channel.MemeConfig{Need: 2, Within: time.Minute})
 	for _, m := range memes {
-		err := d.Check(time.UnixMilli(m.when), m.who, m.text)
+		_, err := d.Check(time.UnixMilli(m.when), m.who, m.text)
 		if err != m.err {
 			t.Errorf("wrong error for %+v: want %v, got %v", m, m.err, err)
 		}
 	}
 	d.Unblock("madoka")
-	if err := d.Check(time.UnixMilli(3), "kita", "madoka"); err != nil {
+	if _, err := d.Check(time.UnixMilli(3), "kita", "madoka"); err != nil {
 		t.Errorf("wrong error on unblocked check: want %v, got %v", nil, err)
 	}
 }
+
+func TestMemeVariants(t *testing.T) {
+	type meme struct {
+		when int64
+		who  string
+		text string
+		want string
+		err  error
+	}
+	cases := []struct {
+		name  string
+		norm  channel.Normalization
+		sim   float64
+		memes []meme
+	}{
+		{
+			name: "case",
+			norm: channel.FoldCase,
+			memes: []meme{
+				{0, "bocchi", "madoka", "", channel.ErrNotCopypasta},
+				{1, "ryo", "madoka", "", channel.ErrNotCopypasta},
+				{2, "nijika", "MADOKA", "madoka", nil},
+			},
+		},
+		{
+			name: "invisible",
+			norm: channel.StripInvisible,
+			memes: []meme{
+				{0, "bocchi", "madoka", "", channel.ErrNotCopypasta},
+				{1, "ryo", "madoka", "", channel.ErrNotCopypasta},
+				{2, "nijika", "ma\u200bdoka\U000e0000", "madoka", nil},
+			},
+		},
+		{
+			name: "space",
+			norm: channel.CollapseSpace,
+			memes: []meme{
+				{0, "bocchi", "madoka homura", "", channel.ErrNotCopypasta},
+				{1, "ryo", "madoka homura", "", channel.ErrNotCopypasta},
+				{2, "nijika", "madoka\thomura ", "madoka homura", nil},
+			},
+		},
+		{
+			name: "emote",
+			norm: channel.TrimEmote,
+			memes: []meme{
+				{0, "bocchi", "madoka homura", "", channel.ErrNotCopypasta},
+				{1, "ryo", "madoka homura Kappa", "", channel.ErrNotCopypasta},
+				{2, "nijika", "madoka homura", "madoka homura", nil},
+			},
+		},
+		{
+			name: "tie",
+			norm: channel.FoldCase,
+			memes: []meme{
+				{0, "bocchi", "madoka", "", channel.ErrNotCopypasta},
+				{1, "ryo", "Madoka", "", channel.ErrNotCopypasta},
+				{2, "nijika", "MADOKA", "MADOKA", nil},
+			},
+		},
+		{
+			name: "spam",
+			norm: channel.FoldCase,
+			memes: []meme{
+				{0, "bocchi", "MADOKA", "", channel.ErrNotCopypasta},
+				{1, "bocchi", "MADOKA", "", channel.ErrNotCopypasta},
+				{2, "bocchi", "MADOKA", "", channel.ErrNotCopypasta},
+				{3, "ryo", "madoka", "", channel.ErrNotCopypasta},
+				{4, "nijika", "Madoka", "Madoka", nil},
+			},
+		},
+		{
+			name: "similar-expired",
+			sim:  0.8,
+			memes: []meme{
+				{0, "bocchi", "the quick brown fox jumps over the lazy dog", "", channel.ErrNotCopypasta},
+				{60000, "ryo", "the quick brown fox jumped over the lazy dog", "", channel.ErrNotCopypasta},
+				{60001, "nijika", "the quick brown fox jumped over the lazy dog", "", channel.ErrNotCopypasta},
+				{60002, "kita", "the quick brown fox jumps over the lazy dog", "the quick brown fox jumped over the lazy dog", nil},
+			},
+		},
+		{
+			name: "not-emote",
+			norm: channel.TrimEmote,
+			memes: []meme{
+				{0, "bocchi", "madoka", "", channel.ErrNotCopypasta},
+				{1, "ryo", "madoka homura", "", channel.ErrNotCopypasta},
+				{2, "nijika", "Kappa", "", channel.ErrNotCopypasta},
+			},
+		},
+		{
+			name: "none",
+			memes: []meme{
+				{0, "bocchi", "madoka", "", channel.ErrNotCopypasta},
+				{1, "ryo", "Madoka", "", channel.ErrNotCopypasta},
+				{2, "nijika", "madoka ", "", channel.ErrNotCopypasta},
+			},
+		},
+		{
+			name: "similar",
+			sim:  0.8,
+			memes: []meme{
+				{0, "bocchi", "the quick brown fox jumps over the lazy dog", "", channel.ErrNotCopypasta},
+				{1, "ryo", "the quick brown fox jumps over the lazy dog", "", channel.ErrNotCopypasta},
+				{2, "nijika", "the quick brown fox jumped over the lazy dog!", "the quick brown fox jumps over the lazy dog", nil},
+			},
+		},
+		{
+			name: "dissimilar",
+			sim:  0.8,
+			memes: []meme{
+				{0, "bocchi", "the quick brown fox jumps over the lazy dog", "", channel.ErrNotCopypasta},
+				{1, "ryo", "pack my box with five dozen liquor jugs", "", channel.ErrNotCopypasta},
+				{2, "nijika", "sphinx of black quartz, judge my vow", "", channel.ErrNotCopypasta},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d := channel.NewMemeDetector(channel.MemeConfig{Need: 3, Within: time.Minute, Normalize: c.norm, Similarity: c.sim})
+			for _, m := range c.memes {
+				got, err := d.Check(time.UnixMilli(m.when), m.who, m.text)
+				if err != m.err {
+					t.Errorf("wrong error for %+v: want %v, got %v", m, m.err, err)
+				}
+				if got != m.want {
+					t.Errorf("wrong variant for %+v: want %q, got %q", m, m.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	cases := []struct {
+		norm channel.Normalization
+		in   string
+		want string
+	}{
+		{0, " Madoka  Kappa ", " Madoka  Kappa "},
+		{channel.FoldCase, "MaDoKa", "madoka"},
+		{channel.StripInvisible, "ma\u200bdo\u034fka\U000e0000\ufeff", "madoka"},
+		{channel.CollapseSpace, "  madoka \t homura\n", "madoka homura"},
+		{channel.TrimEmote, "madoka Kappa ", "madoka"},
+		{channel.TrimEmote, "madoka kappa", "madoka kappa"},
+		{channel.TrimEmote, "madoka :)", "madoka :)"},
+		{channel.TrimEmote, "Kappa", "Kappa"},
+		{channel.FoldCase | channel.StripInvisible | channel.CollapseSpace | channel.TrimEmote, " MADOKA  homura LUL \U000e0000", "madoka homura"},
+	}
+	for _, c := range cases {
+		if got := c.norm.Apply(c.in); got != c.want {
+			t.Errorf("%b.Apply(%q): want %q, got %q", c.norm, c.in, c.want, got)
+		}
+	}
+}
+
+func TestMemeRules(t *testing.T) {
+	type meme struct {
+		when int64
+		who  string
+		text string
+		err  error
+	}
+	cases := []struct {
+		name  string
+		cfg   channel.MemeConfig
+		memes []meme
+	}{
+		{
+			name: "length",
+			cfg:  channel.MemeConfig{MinLength: 3},
+			memes: []meme{
+				{0, "bocchi", "W", channel.ErrNotCopypasta},
+				{1, "ryo", "W", channel.ErrNotCopypasta},
+				{2, "bocchi", "www", channel.ErrNotCopypasta},
+				{3, "ryo", "www", nil},
+			},
+		},
+		{
+			name: "length-normalized",
+			cfg:  channel.MemeConfig{MinLength: 3, Normalize: channel.StripInvisible},
+			memes: []meme{
+				{0, "bocchi", "W\u200b\u200b", channel.ErrNotCopypasta},
+				{1, "ryo", "W\u200b\u200b", channel.ErrNotCopypasta},
+			},
+		},
+		{
+			name: "tokens",
+			cfg:  channel.MemeConfig{MinTokens: 2},
+			memes: []meme{
+				{0, "bocchi", "madoka", channel.ErrNotCopypasta},
+				{1, "ryo", "madoka", channel.ErrNotCopypasta},
+				{2, "bocchi", "madoka homura", channel.ErrNotCopypasta},
+				{3, "ryo", "madoka homura", nil},
+			},
+		},
+		{
+			name: "ignore",
+			cfg:  channel.MemeConfig{Ignore: regexp.MustCompile(`^[!$]`)},
+			memes: []meme{
+				{0, "bocchi", "!play", channel.ErrNotCopypasta},
+				{1, "ryo", "!play", channel.ErrNotCopypasta},
+				{2, "nijika", "$points", channel.ErrNotCopypasta},
+				{3, "kita", "$points", channel.ErrNotCopypasta},
+				{4, "bocchi", "play!", channel.ErrNotCopypasta},
+				{5, "ryo", "play!", nil},
+			},
+		},
+		{
+			name: "fresh",
+			cfg:  channel.MemeConfig{Fresh: 10 * time.Millisecond},
+			memes: []meme{
+				{0, "bocchi", "madoka", channel.ErrNotCopypasta},
+				{1, "ryo", "madoka", nil},
+				{2, "nijika", "madoka", channel.ErrNotCopypasta},
+				{11, "kita", "madoka", nil},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.cfg.Need = 2
+			c.cfg.Within = time.Minute
+			d := channel.NewMemeDetector(c.cfg)
+			for _, m := range c.memes {
+				_, err := d.Check(time.UnixMilli(m.when), m.who, m.text)
+				if err != m.err {
+					t.Errorf("wrong error for %+v: want %v, got %v", m, m.err, err)
+				}
+			}
+		})
+	}
+}
@@ -7,12 +7,39 @@ import (
 )
 
 // History is a message history.
+// The zero value keeps messages for fifteen minutes with no limit on size.
 type History struct {
 	oldest, newest atomic.Pointer[histnode]
+	// count is the approximate number of messages in the list which have not
+	// been removed.
+	count atomic.Int64
+
+	window time.Duration
+	size   int64
+}
+
+// NewHistory creates a history which keeps messages for the given window,
+// up to size messages. A window of zero means fifteen minutes, and a size of
+// zero means no limit.
+func NewHistory(window time.Duration, size int) *History {
+	return &History{window: window, size: int64(size)}
+}
+
+// Window returns the duration for which messages are kept.
+func (h *History) Window() time.Duration {
+	if h.window <= 0 {
+		return 15 * time.Minute
+	}
+	return h.window
 }
 
 type histnode struct {
 	newer atomic.Pointer[histnode]
+	// deleted marks a removed message. Unlinking nodes from the middle of the
+	// list would require coordinating with concurrent appends and iterators,
+	// so removed messages instead remain in place, hidden, until they reach
+	// the oldest end of the list. Whoever sets deleted uncounts the node.
+	deleted atomic.Bool
 
 	id   string
 	who  string
@@ -23,14 +50,18 @@ type histnode struct {
 // sentinel is a special node indicating that the next link is being modified.
 var sentinel = new(histnode)
 
+// Add adds a message sent at now to the history, first dropping messages
+// which have expired or which are the oldest in excess of the size limit.
+// Under concurrent use, the size limit may be exceeded briefly.
 func (h *History) Add(now time.Time, id, who, text string) {
 	h.dropOld(now.UnixNano())
 	l := &histnode{
 		id:   id,
 		who:  who,
 		text: text,
-		exp:  now.Add(15 * time.Minute).UnixNano(),
+		exp:  now.Add(h.Window()).UnixNano(),
 	}
+	h.count.Add(1)
 	for {
 		if h.oldest.CompareAndSwap(nil, sentinel) {
 			// List was empty.
@@ -66,11 +97,15 @@ func (h *History) dropOld(exp int64) {
 			h.oldest.Store(nil)
 			return
 		}
-		if cur.exp > exp {
-			// The rest are current.
+		if !cur.deleted.Load() && cur.exp > exp && (h.size <= 0 || h.count.Load() < h.size) {
+			// The rest are current, and there is room for one more.
 			h.oldest.Store(cur)
 			return
 		}
+		// Removed messages were uncounted when they were removed.
+		if !cur.deleted.Swap(true) {
+			h.count.Add(-1)
+		}
 		// Store the next element back into the oldest position and load once
 		// more in the next iteration. This way, if another goroutine is trying
 		// to iterate, it gets to make progress.
@@ -82,15 +117,32 @@ func (h *History) dropOld(exp int64) {
 // HistoryMessage is the minimized representation of a message recorded in a
 // channel's history.
 type HistoryMessage struct {
-	ID     string
+	ID string
+	// Sender is a key identifying the sender, rather than their user ID, so
+	// that histories can be persisted.
 	Sender string
 	Text   string
 }
 
 // All yields the messages in the history, approximately in order from
-// oldest to newest.
+// oldest to newest. Removed messages are not yielded.
 func (h *History) All() iter.Seq[HistoryMessage] {
 	return func(yield func(HistoryMessage) bool) {
+		for cur := range h.nodes() {
+			if cur.deleted.Load() {
+				continue
+			}
+			v := HistoryMessage{ID: cur.id, Sender: cur.who, Text: cur.text}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// nodes yields the nodes in the list, including removed ones.
+func (h *History) nodes() iter.Seq[*histnode] {
+	return func(yield func(*histnode) bool) {
 		p := &h.oldest
 		for {
 			cur := p.Load()
@@ -100,11 +152,56 @@ func (h *History) All() iter.Seq[HistoryMessage] {
 			if cur == nil {
 				return
 			}
-			v := HistoryMessage{ID: cur.id, Sender: cur.who, Text: cur.text}
-			if !yield(v) {
+			if !yield(cur) {
 				return
 			}
 			p = &cur.newer
 		}
 	}
 }
+
+// Remove removes the message with the given ID.
+// The result is whether such a message was in the history.
+// Removed messages no longer count toward the history's size.
+func (h *History) Remove(id string) bool {
+	for cur := range h.nodes() {
+		if cur.id == id {
+			return h.remove(cur)
+		}
+	}
+	return false
+}
+
+// remove marks a node as removed and uncounts it.
+// The result is whether the node was not already removed.
+func (h *History) remove(cur *histnode) bool {
+	if cur.deleted.Swap(true) {
+		return false
+	}
+	h.count.Add(-1)
+	return true
+}
+
+// RemoveSender removes all messages from the given sender.
+// The result is the number of messages removed.
+func (h *History) RemoveSender(who string) int {
+	n := 0
+	for cur := range h.nodes() {
+		if cur.who == who && h.remove(cur) {
+			n++
+		}
+	}
+	return n
+}
+
+// Clear removes all messages.
+// The result is the number of messages removed.
+func (h *History) Clear() int {
+	n := 0
+	for cur := range h.nodes() {
+		if h.remove(cur) {
+			n++
+		}
+	}
+	return n
+}
@@ -69,6 +69,28 @@ func TestHistoryAdd(t *testing.T) {
 			t.Errorf("wrong iters: want %v, got %v", want, got)
 		}
 	})
+	t.Run("window", func(t *testing.T) {
+		h := channel.NewHistory(time.Hour, 0)
+		h.Add(time.Unix(1, 0), "1", "1", "1")
+		h.Add(time.Unix(1800, 0), "2", "2", "2")
+		h.Add(time.Unix(3602, 0), "3", "3", "3")
+		got := slices.Collect(h.All())
+		want := []channel.HistoryMessage{{ID: "2", Sender: "2", Text: "2"}, {ID: "3", Sender: "3", Text: "3"}}
+		if !slices.Equal(got, want) {
+			t.Errorf("wrong iters: want %v, got %v", want, got)
+		}
+	})
+	t.Run("size", func(t *testing.T) {
+		h := channel.NewHistory(0, 2)
+		h.Add(time.Unix(1, 0), "1", "1", "1")
+		h.Add(time.Unix(2, 0), "2", "2", "2")
+		h.Add(time.Unix(3, 0), "3", "3", "3")
+		got := slices.Collect(h.All())
+		want := []channel.HistoryMessage{{ID: "2", Sender: "2", Text: "2"}, {ID: "3", Sender: "3", Text: "3"}}
+		if !slices.Equal(got, want) {
+			t.Errorf("wrong iters: want %v, got %v", want, got)
+		}
+	})
 }
 
 func TestHistoryAllAtOnce(t *testing.T) {
@@ -94,3 +116,104 @@ func TestHistoryAllAtOnce(t *testing.T) {
 		}
 	}
 }
+
+func TestHistoryRemove(t *testing.T) {
+	h := channel.NewHistory(time.Hour, 0)
+	h.Add(time.Unix(1, 0), "1", "bocchi", "ryo")
+	h.Add(time.Unix(2, 0), "2", "nijika", "kita")
+	h.Add(time.Unix(3, 0), "3", "bocchi", "nijika")
+	h.Add(time.Unix(4, 0), "4", "ryo", "bocchi")
+	if !h.Remove("2") {
+		t.Errorf("couldn't remove 2")
+	}
+	if h.Remove("2") {
+		t.Errorf("removed 2 twice")
+	}
+	if h.Remove("5") {
+		t.Errorf("removed nonexistent message")
+	}
+	if n := h.RemoveSender("bocchi"); n != 2 {
+		t.Errorf("wrong number removed from sender: want 2, got %d", n)
+	}
+	got := slices.Collect(h.All())
+	want := []channel.HistoryMessage{{ID: "4", Sender: "ryo", Text: "bocchi"}}
+	if !slices.Equal(got, want) {
+		t.Errorf("wrong iters: want %v, got %v", want, got)
+	}
+	h.Add(time.Unix(5, 0), "5", "bocchi", "kita")
+	if n := h.Clear(); n != 2 {
+		t.Errorf("wrong number cleared: want 2, got %d", n)
+	}
+	if got := slices.Collect(h.All()); len(got) != 0 {
+		t.Errorf("messages after clear: %v", got)
+	}
+}
+
+func TestHistoryRemoveSize(t *testing.T) {
+	// Removed messages shouldn't take room from live ones.
+	h := channel.NewHistory(time.Hour, 3)
+	h.Add(time.Unix(1, 0), "1", "nijika", "kita")
+	h.Add(time.Unix(2, 0), "2", "spam", "spam")
+	h.Add(time.Unix(3, 0), "3", "spam", "spam")
+	if n := h.RemoveSender("spam"); n != 2 {
+		t.Errorf("wrong number removed from sender: want 2, got %d", n)
+	}
+	h.Add(time.Unix(4, 0), "4", "ryo", "bocchi")
+	h.Add(time.Unix(5, 0), "5", "bocchi", "ryo")
+	got := slices.Collect(h.All())
+	want := []channel.HistoryMessage{
+		{ID: "1", Sender: "nijika", Text: "kita"},
+		{ID: "4", Sender: "ryo", Text: "bocchi"},
+		{ID: "5", Sender: "bocchi", Text: "ryo"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("wrong iters after removing: want %v, got %v", want, got)
+	}
+	// Now the history is full, so the oldest live message goes.
+	h.Add(time.Unix(6, 0), "6", "kita", "nijika")
+	got = slices.Collect(h.All())
+	want = append(want[1:], channel.HistoryMessage{ID: "6", Sender: "kita", Text: "nijika"})
+	if !slices.Equal(got, want) {
+		t.Errorf("wrong iters when full: want %v, got %v", want, got)
+	}
+}
+
+func TestHistoryRemoveConcurrent(t *testing.T) {
+	h := channel.NewHistory(time.Hour, 0)
+	var wg sync.WaitGroup
+	const N = 100
+	wg.Add(N)
+	for i := range N {
+		go func() {
+			defer wg.Done()
+			s := strconv.Itoa(i)
+			who := strconv.Itoa(i % 4)
+			h.Add(time.Unix(int64(i), 0), s, who, s)
+			switch i % 4 {
+			case 0:
+				h.Remove(s)
+			case 1:
+				h.RemoveSender(who)
+			}
+			for m := range h.All() {
+				if m.ID == s && i%4 < 2 {
+					t.Errorf("saw removed message %q", m)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for m := range h.All() {
+		if m.Sender == "1" {
+			t.Errorf("saw message from removed sender: %q", m)
+		}
+		n, _ := strconv.Atoi(m.ID)
+		if n%4 == 0 {
+			t.Errorf("saw removed message: %q", m)
+		}
+	}
+	got := slices.Collect(h.All())
+	if len(got) != N/2 {
+		t.Errorf("wrong number of messages remaining: want %d, got %d", N/2, len(got))
+	}
+}
@@ -0,0 +1,192 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zephyrtronium/robot/brain"
+)
+
+// Candidate is a message generated in advance of being needed.
+type Candidate struct {
+	// Text is the generated message.
+	Text string
+	// Trace is the trace of message IDs used to generate the message.
+	Trace []string
+	// Spans are the provenance of the message's terms.
+	Spans []brain.Span
+	// Cost is the time spent generating the message.
+	Cost time.Duration
+	// Seed is the seed which reproduces the message from the same knowledge
+	// using [brain.WithSeed].
+	Seed uint64
+}
+
+// Pregen is a queue of messages generated in advance for a channel.
+// Methods on a nil Pregen act as though the queue is always empty.
+type Pregen struct {
+	mu    sync.Mutex
+	queue []Candidate
+	size  int
+	want  chan struct{}
+	// epoch counts invalidations so that messages generated from forgotten
+	// knowledge are not added after the fact.
+	epoch uint64
+}
+
+// NewPregen creates a queue holding up to size messages.
+func NewPregen(size int) *Pregen {
+	return &Pregen{
+		queue: make([]Candidate, 0, size),
+		size:  size,
+		want:  make(chan struct{}, 1),
+	}
+}
+
+// signal wakes the filler if it is waiting.
+func (p *Pregen) signal() {
+	select {
+	case p.want <- struct{}{}:
+	default:
+	}
+}
+
+// Take removes the oldest message from the queue.
+// If the queue is empty, the result is false.
+func (p *Pregen) Take() (Candidate, bool) {
+	if p == nil {
+		return Candidate{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) == 0 {
+		return Candidate{}, false
+	}
+	c := p.queue[0]
+	p.queue = slices.Delete(p.queue, 0, 1)
+	p.signal()
+	return c, true
+}
+
+// Epoch returns a value which changes whenever messages are invalidated.
+// Generating a message to add to the queue should begin with a call to Epoch.
+func (p *Pregen) Epoch() uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.epoch
+}
+
+// Put adds a message to the queue. epoch is the result of [Pregen.Epoch]
+// from before the message was generated.
+// If the queue is full or messages have been invalidated since epoch, the
+// message is dropped and the result is false.
+func (p *Pregen) Put(c Candidate, epoch uint64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.queue) >= p.size || p.epoch != epoch {
+		return false
+	}
+	p.queue = append(p.queue, c)
+	return true
+}
+
+// Need returns the number of messages needed to fill the queue.
+func (p *Pregen) Need() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size - len(p.queue)
+}
+
+// Wait returns a channel which receives a value when the queue may need more
+// messages.
+func (p *Pregen) Wait() <-chan struct{} {
+	return p.want
+}
+
+// Forget removes all messages whose traces include any of ids.
+// The result is the number of messages removed.
+func (p *Pregen) Forget(ids ...string) int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.epoch++
+	n := len(p.queue)
+	p.queue = slices.DeleteFunc(p.queue, func(c Candidate) bool {
+		return slices.ContainsFunc(ids, func(id string) bool {
+			_, ok := slices.BinarySearch(c.Trace, id)
+			return ok
+		})
+	})
+	n -= len(p.queue)
+	if n != 0 {
+		p.signal()
+	}
+	return n
+}
+
+// Clear removes all messages.
+func (p *Pregen) Clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.epoch++
+	clear(p.queue)
+	p.queue = p.queue[:0]
+	p.signal()
+}
+
+// Invalidate removes pre-generated messages using any of ids from every
+// channel in chans which speaks with tag.
+func Invalidate(chans iter.Seq2[string, *Channel], tag string, ids ...string) {
+	for _, ch := range chans {
+		if ch.Send == tag {
+			ch.Pregen.Forget(ids...)
+		}
+	}
+}
+
+// Generate generates a message for the channel from its send tag within its
+// budget, observing the time spent in latency. The seed for the message is
+// drawn from rng. If generation is truncated, the partial message is returned
+// with no error. If the message is blocked, the error is [brain.ErrRejected].
+func (ch *Channel) Generate(ctx context.Context, log *slog.Logger, br brain.Speaker, prompt string, rng *rand.Rand, latency prometheus.Observer) (Candidate, error) {
+	start := time.Now()
+	set := ch.Settings.Load()
+	seed := rng.Uint64()
+	gctx := brain.WithSeed(ctx, seed)
+	if set.Budget > 0 {
+		var cancel context.CancelFunc
+		gctx, cancel = context.WithTimeout(gctx, set.Budget)
+		defer cancel()
+	}
+	// Stop generating as soon as the message is blocked, rather than
+	// finishing a message we won't use.
+	ok := func(m string) bool { return !set.Block.MatchString(m) }
+	m, trace, spans, err := brain.SpeakCheck(gctx, br, ch.Send, prompt, ok)
+	cost := time.Since(start)
+	latency.Observe(cost.Seconds())
+	if errors.Is(err, brain.ErrTruncated) {
+		log.WarnContext(ctx, "generation truncated",
+			slog.String("in", ch.Name),
+			slog.String("text", m),
+			slog.Any("err", err),
+		)
+		err = nil
+	}
+	return Candidate{Text: m, Trace: trace, Spans: spans, Cost: cost, Seed: seed}, err
+}
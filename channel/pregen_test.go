@@ -0,0 +1,67 @@
+package channel_test
+
+import (
+	"testing"
+
+	"github.com/zephyrtronium/robot/channel"
+)
+
+func TestPregen(t *testing.T) {
+	p := channel.NewPregen(2)
+	if n := p.Need(); n != 2 {
+		t.Errorf("wrong need when empty: want 2, got %d", n)
+	}
+	if _, ok := p.Take(); ok {
+		t.Errorf("took from empty queue")
+	}
+	e := p.Epoch()
+	if !p.Put(channel.Candidate{Text: "bocchi", Trace: []string{"1", "2"}}, e) {
+		t.Errorf("couldn't put first")
+	}
+	if !p.Put(channel.Candidate{Text: "ryo", Trace: []string{"3"}}, e) {
+		t.Errorf("couldn't put second")
+	}
+	if p.Put(channel.Candidate{Text: "nijika", Trace: []string{"4"}}, e) {
+		t.Errorf("put into full queue")
+	}
+	if n := p.Forget("2"); n != 1 {
+		t.Errorf("wrong number forgotten: want 1, got %d", n)
+	}
+	select {
+	case <-p.Wait():
+	default:
+		t.Errorf("no signal after forgetting")
+	}
+	// A message generated before the forget must not be added.
+	if p.Put(channel.Candidate{Text: "kita", Trace: []string{"5"}}, e) {
+		t.Errorf("put message from before invalidation")
+	}
+	c, ok := p.Take()
+	if !ok || c.Text != "ryo" {
+		t.Errorf("wrong take: want ryo, got %q %t", c.Text, ok)
+	}
+	if _, ok := p.Take(); ok {
+		t.Errorf("took from emptied queue")
+	}
+	if !p.Put(channel.Candidate{Text: "kita", Trace: []string{"5"}}, p.Epoch()) {
+		t.Errorf("couldn't put after invalidation")
+	}
+	p.Clear()
+	if n := p.Need(); n != 2 {
+		t.Errorf("wrong need after clear: want 2, got %d", n)
+	}
+}
+
+func TestPregenNil(t *testing.T) {
+	var p *channel.Pregen
+	if _, ok := p.Take(); ok {
+		t.Errorf("took from nil queue")
+	}
+	if n := p.Need(); n != 0 {
+		t.Errorf("nil queue needs %d", n)
+	}
+	if n := p.Forget("1"); n != 0 {
+		t.Errorf("forgot %d from nil queue", n)
+	}
+	p.Clear()
+}
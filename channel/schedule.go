@@ -0,0 +1,89 @@
+package channel
+
+import (
+	"slices"
+	"time"
+)
+
+// Schedule is a set of recurring windows of time during which a channel
+// behaves differently.
+type Schedule struct {
+	// Loc is the time zone in which windows are evaluated.
+	// If nil, windows are in UTC.
+	Loc *time.Location
+	// Windows is the list of windows. When windows overlap, the earliest in
+	// the list applies.
+	Windows []Window
+}
+
+// Window is a recurring period of time in a [Schedule].
+type Window struct {
+	// Days is the days of the week on which the window starts.
+	// Empty means every day.
+	Days []time.Weekday
+	// Start and End are the times of day at which the window starts and ends,
+	// as durations since midnight. If End is not after Start, the window ends
+	// on the following day.
+	Start, End time.Duration
+	// Responses is the probability of random responses during the window.
+	Responses float64
+	// Quiet disables speaking during the window.
+	// Learning continues as normal.
+	Quiet bool
+}
+
+// Active returns the window in effect at t, or nil if there is none.
+func (s *Schedule) Active(t time.Time) *Window {
+	if s == nil || len(s.Windows) == 0 {
+		return nil
+	}
+	loc := s.Loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	h, m, sec := t.Clock()
+	tod := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		if w.Start < w.End {
+			if w.on(today) && w.Start <= tod && tod < w.End {
+				return w
+			}
+			continue
+		}
+		// The window crosses midnight.
+		if w.on(today) && tod >= w.Start || w.on(yesterday) && tod < w.End {
+			return w
+		}
+	}
+	return nil
+}
+
+// on reports whether the window starts on the given day.
+func (w *Window) on(d time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, d)
+}
+
+// ResponsesAt returns the probability of random responses at t when chat
+// is moving at rate messages per minute.
+func (s *Settings) ResponsesAt(t time.Time, rate float64) float64 {
+	if w := s.Schedule.Active(t); w != nil {
+		if w.Quiet {
+			return 0
+		}
+		return w.Responses
+	}
+	if s.Adaptive != nil {
+		return s.Adaptive.Probability(rate)
+	}
+	return s.Responses
+}
+
+// QuietAt reports whether the channel should be silent at t.
+func (s *Settings) QuietAt(t time.Time) bool {
+	w := s.Schedule.Active(t)
+	return w != nil && w.Quiet
+}
@@ -0,0 +1,79 @@
+package channel_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zephyrtronium/robot/channel"
+)
+
+func TestScheduleActive(t *testing.T) {
+	// Fixed zone so the test doesn't depend on tzdata.
+	loc := time.FixedZone("test", -5*60*60)
+	s := &channel.Schedule{
+		Loc: loc,
+		Windows: []channel.Window{
+			{Days: []time.Weekday{time.Saturday}, Start: 20 * time.Hour, End: 2 * time.Hour, Quiet: true},
+			{Start: 12 * time.Hour, End: 13 * time.Hour, Responses: 0.5},
+			{Start: 12 * time.Hour, End: 14 * time.Hour, Responses: 0.25},
+		},
+	}
+	cases := []struct {
+		name string
+		t    time.Time
+		want int // index into s.Windows, or -1 for none
+	}{
+		{"none", time.Date(2026, 10, 17, 8, 0, 0, 0, loc), -1},
+		{"saturday-night", time.Date(2026, 10, 17, 21, 0, 0, 0, loc), 0},
+		{"sunday-morning", time.Date(2026, 10, 18, 1, 59, 59, 0, loc), 0},
+		{"sunday-end", time.Date(2026, 10, 18, 2, 0, 0, 0, loc), -1},
+		{"friday-night", time.Date(2026, 10, 16, 21, 0, 0, 0, loc), -1},
+		{"other-zone", time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC), 0},
+		{"first", time.Date(2026, 10, 14, 12, 30, 0, 0, loc), 1},
+		{"second", time.Date(2026, 10, 14, 13, 30, 0, 0, loc), 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := s.Active(c.t)
+			var want *channel.Window
+			if c.want >= 0 {
+				want = &s.Windows[c.want]
+			}
+			if got != want {
+				t.Errorf("wrong window: want %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestSettingsAt(t *testing.T) {
+	s := channel.Settings{
+		Responses: 0.1,
+		Schedule: &channel.Schedule{
+			Windows: []channel.Window{
+				{Start: 1 * time.Hour, End: 2 * time.Hour, Responses: 0.5},
+				{Start: 2 * time.Hour, End: 3 * time.Hour, Responses: 0.5, Quiet: true},
+			},
+		},
+	}
+	cases := []struct {
+		name      string
+		t         time.Time
+		responses float64
+		quiet     bool
+	}{
+		{"default", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), 0.1, false},
+		{"window", time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC), 0.5, false},
+		{"quiet", time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC), 0, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := s.ResponsesAt(c.t, 0); got != c.responses {
+				t.Errorf("wrong responses: want %v, got %v", c.responses, got)
+			}
+			if got := s.QuietAt(c.t); got != c.quiet {
+				t.Errorf("wrong quiet: want %v, got %v", c.quiet, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package channel
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// UserLimits rate limits individual users' commands and temporarily ignores
+// users who repeatedly exceed their limits.
+// Users are tracked in a bounded LRU, so the least recently active users are
+// forgotten, including their violations, when the bound is reached.
+//
+// The methods of a nil *UserLimits allow everything.
+type UserLimits struct {
+	mu sync.Mutex
+	// lru is the list of tracked users, most recently active first.
+	lru list.List // list of *userLimit
+	// users maps user IDs to their elements in lru.
+	users map[string]*list.Element
+
+	cfg UserLimitConfig
+}
+
+// UserLimitConfig is the configuration for [UserLimits].
+type UserLimitConfig struct {
+	// Every and Burst are the rate limit parameters for each user.
+	Every time.Duration
+	Burst int
+	// Size is the maximum number of users to track.
+	// If it is not positive, it is 1000.
+	Size int
+	// Strikes is the number of rate limit violations after which a user is
+	// ignored. Zero means users are never ignored.
+	Strikes int
+	// Timeout is the duration for which to ignore users.
+	Timeout time.Duration
+}
+
+type userLimit struct {
+	id      string
+	lim     *rate.Limiter
+	strikes int
+	until   time.Time
+}
+
+// Verdict is the result of checking a user's rate limit.
+type Verdict int
+
+const (
+	// Allowed means the user is within their limit.
+	Allowed Verdict = iota
+	// Limited means the user has exceeded their limit.
+	Limited
+	// Ignoring means the user has exceeded their limit too many times and is
+	// now ignored.
+	Ignoring
+)
+
+// NewUserLimits creates a per-user rate limiter.
+func NewUserLimits(cfg UserLimitConfig) *UserLimits {
+	if cfg.Size <= 0 {
+		cfg.Size = 1000
+	}
+	return &UserLimits{
+		users: make(map[string]*list.Element),
+		cfg:   cfg,
+	}
+}
+
+// getLocked returns the entry for a user, creating it if needed and marking
+// it most recently used.
+func (u *UserLimits) getLocked(id string) *userLimit {
+	if e := u.users[id]; e != nil {
+		u.lru.MoveToFront(e)
+		return e.Value.(*userLimit)
+	}
+	if u.lru.Len() >= u.cfg.Size {
+		b := u.lru.Back()
+		u.lru.Remove(b)
+		delete(u.users, b.Value.(*userLimit).id)
+	}
+	l := &userLimit{id: id, lim: rate.NewLimiter(rate.Every(u.cfg.Every), u.cfg.Burst)}
+	u.users[id] = u.lru.PushFront(l)
+	return l
+}
+
+// Allow checks whether a user may use a command at t.
+func (u *UserLimits) Allow(t time.Time, id string) Verdict {
+	if u == nil {
+		return Allowed
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	l := u.getLocked(id)
+	if t.Before(l.until) {
+		return Limited
+	}
+	if l.lim.AllowN(t, 1) {
+		return Allowed
+	}
+	l.strikes++
+	if u.cfg.Strikes > 0 && l.strikes >= u.cfg.Strikes {
+		l.strikes = 0
+		l.until = t.Add(u.cfg.Timeout)
+		return Ignoring
+	}
+	return Limited
+}
+
+// Ignored reports whether a user is temporarily ignored at t.
+// Checking counts as activity, so ignored users who keep talking stay
+// tracked until their timeouts end.
+func (u *UserLimits) Ignored(t time.Time, id string) bool {
+	if u == nil {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	e := u.users[id]
+	if e == nil {
+		return false
+	}
+	u.lru.MoveToFront(e)
+	return t.Before(e.Value.(*userLimit).until)
+}
@@ -0,0 +1,98 @@
+package channel_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zephyrtronium/robot/channel"
+)
+
+func TestUserLimits(t *testing.T) {
+	u := channel.NewUserLimits(channel.UserLimitConfig{
+		Every:   time.Minute,
+		Burst:   1,
+		Strikes: 2,
+		Timeout: time.Hour,
+	})
+	start := time.Unix(0, 0)
+	steps := []struct {
+		t       time.Duration
+		who     string
+		want    channel.Verdict
+		ignored bool
+	}{
+		{0, "bocchi", channel.Allowed, false},
+		{0, "ryo", channel.Allowed, false},
+		{time.Second, "bocchi", channel.Limited, false},
+		{2 * time.Second, "bocchi", channel.Ignoring, true},
+		{2 * time.Second, "ryo", channel.Limited, false},
+		{time.Minute + 2*time.Second, "bocchi", channel.Limited, true},
+		{time.Hour + 2*time.Second, "bocchi", channel.Allowed, false},
+	}
+	for _, s := range steps {
+		tm := start.Add(s.t)
+		if got := u.Allow(tm, s.who); got != s.want {
+			t.Errorf("wrong verdict for %s at %v: want %v, got %v", s.who, s.t, s.want, got)
+		}
+		if got := u.Ignored(tm, s.who); got != s.ignored {
+			t.Errorf("wrong ignored for %s at %v: want %v, got %v", s.who, s.t, s.ignored, got)
+		}
+	}
+}
+
+func TestUserLimitsEvict(t *testing.T) {
+	u := channel.NewUserLimits(channel.UserLimitConfig{
+		Every:   time.Hour,
+		Burst:   1,
+		Size:    2,
+		Strikes: 1,
+		Timeout: time.Hour,
+	})
+	tm := time.Unix(0, 0)
+	u.Allow(tm, "bocchi")
+	if got := u.Allow(tm, "bocchi"); got != channel.Ignoring {
+		t.Fatalf("wrong verdict: want %v, got %v", channel.Ignoring, got)
+	}
+	for i := range 2 {
+		u.Allow(tm, fmt.Sprint(i))
+	}
+	if u.Ignored(tm, "bocchi") {
+		t.Errorf("least recently used user wasn't evicted")
+	}
+	if got := u.Allow(tm, "bocchi"); got != channel.Allowed {
+		t.Errorf("wrong verdict after eviction: want %v, got %v", channel.Allowed, got)
+	}
+}
+
+func TestUserLimitsIgnoredKeepsUser(t *testing.T) {
+	u := channel.NewUserLimits(channel.UserLimitConfig{
+		Every:   time.Hour,
+		Burst:   1,
+		Size:    2,
+		Strikes: 1,
+		Timeout: time.Hour,
+	})
+	tm := time.Unix(0, 0)
+	u.Allow(tm, "bocchi")
+	if got := u.Allow(tm, "bocchi"); got != channel.Ignoring {
+		t.Fatalf("wrong verdict: want %v, got %v", channel.Ignoring, got)
+	}
+	for i := range 2 {
+		u.Allow(tm, fmt.Sprint(i))
+		// Bocchi keeps talking while ignored.
+		if !u.Ignored(tm, "bocchi") {
+			t.Fatalf("ignored user was evicted after %d others", i+1)
+		}
+	}
+}
+
+func TestNilUserLimits(t *testing.T) {
+	var u *channel.UserLimits
+	if got := u.Allow(time.Unix(0, 0), "bocchi"); got != channel.Allowed {
+		t.Errorf("nil limits didn't allow: got %v", got)
+	}
+	if u.Ignored(time.Unix(0, 0), "bocchi") {
+		t.Errorf("nil limits ignored")
+	}
+}
@@ -0,0 +1,86 @@
+package channel
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Velocity tracks the rate of messages in a channel as an exponentially
+// weighted moving average.
+type Velocity struct {
+	mu sync.Mutex
+	// count is the decayed number of messages as of last.
+	count float64
+	// last is the time of the most recent message.
+	last time.Time
+	// tau is the time constant of the average.
+	tau time.Duration
+}
+
+// NewVelocity creates a velocity tracker which weights messages over roughly
+// the given window. If window is not positive, it is one minute.
+func NewVelocity(window time.Duration) *Velocity {
+	if window <= 0 {
+		window = time.Minute
+	}
+	return &Velocity{tau: window}
+}
+
+// decay returns the factor by which counts decay from v.last to t.
+// Times before v.last don't decay.
+func (v *Velocity) decay(t time.Time) float64 {
+	dt := t.Sub(v.last)
+	if dt <= 0 {
+		return 1
+	}
+	return math.Exp(-dt.Seconds() / v.tau.Seconds())
+}
+
+// Observe records a message at t.
+func (v *Velocity) Observe(t time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.count = v.count*v.decay(t) + 1
+	if t.After(v.last) {
+		v.last = t
+	}
+}
+
+// Last returns the time of the most recent message,
+// or the zero time if there have been none.
+func (v *Velocity) Last() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.last
+}
+
+// Rate returns the message rate at t in messages per minute.
+func (v *Velocity) Rate(t time.Time) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.count * v.decay(t) * time.Minute.Seconds() / v.tau.Seconds()
+}
+
+// Adaptive is the configuration for computing response probability from chat
+// velocity instead of using a fixed probability.
+type Adaptive struct {
+	// Target is the number of random responses per minute to aim for.
+	Target float64
+	// Min and Max clamp the resulting probability.
+	Min, Max float64
+}
+
+// Probability computes the per-message response probability needed to send
+// the target number of responses at the given message rate in messages per
+// minute.
+func (a *Adaptive) Probability(rate float64) float64 {
+	hi := a.Max
+	if hi <= 0 {
+		hi = 1
+	}
+	if rate <= 0 {
+		return hi
+	}
+	return min(max(a.Target/rate, a.Min), hi)
+}
@@ -0,0 +1,65 @@
+package channel_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/zephyrtronium/robot/channel"
+)
+
+func TestVelocity(t *testing.T) {
+	v := channel.NewVelocity(time.Minute)
+	start := time.Unix(0, 0)
+	if r := v.Rate(start); r != 0 {
+		t.Errorf("nonzero rate with no messages: %v", r)
+	}
+	// Ten messages per minute for a long time converges to ten per minute.
+	var now time.Time
+	for i := range 600 {
+		now = start.Add(time.Duration(i) * 6 * time.Second)
+		v.Observe(now)
+	}
+	if r := v.Rate(now); math.Abs(r-10) > 1 {
+		t.Errorf("wrong steady rate: want about 10, got %v", r)
+	}
+	// A long silence decays to nearly nothing.
+	if r := v.Rate(now.Add(time.Hour)); r > 0.01 {
+		t.Errorf("rate didn't decay: got %v", r)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	cases := []struct {
+		name string
+		a    channel.Adaptive
+		rate float64
+		want float64
+	}{
+		{"exact", channel.Adaptive{Target: 1}, 10, 0.1},
+		{"silent", channel.Adaptive{Target: 1, Max: 0.5}, 0, 0.5},
+		{"silent-default", channel.Adaptive{Target: 1}, 0, 1},
+		{"slow", channel.Adaptive{Target: 1, Max: 0.5}, 1, 0.5},
+		{"fast", channel.Adaptive{Target: 1, Min: 0.01}, 1000, 0.01},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.a.Probability(c.rate); got != c.want {
+				t.Errorf("wrong probability: want %v, got %v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestVelocityLast(t *testing.T) {
+	v := channel.NewVelocity(time.Minute)
+	if !v.Last().IsZero() {
+		t.Errorf("nonzero last with no messages: %v", v.Last())
+	}
+	a, b := time.Unix(2, 0), time.Unix(1, 0)
+	v.Observe(a)
+	v.Observe(b)
+	if got := v.Last(); !got.Equal(a) {
+		t.Errorf("wrong last: want %v, got %v", a, got)
+	}
+}
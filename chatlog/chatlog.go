@@ -0,0 +1,185 @@
+// Package chatlog persists recent channel messages so that channel histories
+// survive restarts.
+package chatlog
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/migrate"
+)
+
+// Log is a persistent log of messages received in channels.
+type Log struct {
+	db *sqlitex.Pool
+}
+
+// Message is a logged message.
+type Message struct {
+	// Time is the time at which the message was sent.
+	Time time.Time
+	// ID is the message ID.
+	ID string
+	// Sender is a key identifying the sender, such as a keyed hash of their
+	// user ID. It is stored as given, so it should never be the user ID itself.
+	Sender string
+	// Text is the message text.
+	Text string
+}
+
+// Open opens an existing chat log in a DB.
+func Open(ctx context.Context, db *sqlitex.Pool) (*Log, error) {
+	conn, err := db.Take(ctx)
+	defer db.Put(conn)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get connection from pool: %w", err)
+	}
+	if _, err := migrate.Apply(conn, &Schema); err != nil {
+		return nil, fmt.Errorf("couldn't initialize chat log schema: %w", err)
+	}
+	return &Log{db}, nil
+}
+
+//go:embed schema.sql
+var schemaSQL string
+
+//go:embed sender.sql
+var senderSQL string
+
+// Schema is the list of migrations for chat log databases.
+var Schema = migrate.Schema{
+	Name: "chatlog",
+	Migrations: []migrate.Migration{
+		{Name: "initial schema", SQL: schemaSQL},
+		{Name: "hash senders", SQL: senderSQL},
+	},
+}
+
+// Add logs a message. The caller is responsible for ensuring that the sender
+// allows their messages to be stored.
+func (l *Log) Add(ctx context.Context, channel string, msg *Message) error {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't get connection to log message: %w", err)
+	}
+	const insert = `INSERT INTO chatlog (channel, id, sender, text, time) VALUES (?, ?, ?, ?, ?)`
+	opts := sqlitex.ExecOptions{Args: []any{channel, msg.ID, []byte(msg.Sender), msg.Text, msg.Time.UnixNano()}}
+	if err := sqlitex.Execute(conn, insert, &opts); err != nil {
+		return fmt.Errorf("couldn't log message: %w", err)
+	}
+	return nil
+}
+
+// Since returns the messages logged in a channel at or after the given time,
+// oldest first.
+func (l *Log) Since(ctx context.Context, channel string, tm time.Time) ([]Message, error) {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get connection to load messages: %w", err)
+	}
+	const sel = `SELECT id, sender, text, time FROM chatlog WHERE channel = ? AND time >= ? ORDER BY time`
+	var r []Message
+	opts := sqlitex.ExecOptions{
+		Args: []any{channel, tm.UnixNano()},
+		ResultFunc: func(st *sqlite.Stmt) error {
+			sender := make([]byte, st.ColumnLen(1))
+			st.ColumnBytes(1, sender)
+			r = append(r, Message{
+				ID:     st.ColumnText(0),
+				Sender: string(sender),
+				Text:   st.ColumnText(2),
+				Time:   time.Unix(0, st.ColumnInt64(3)),
+			})
+			return nil
+		},
+	}
+	if err := sqlitex.Execute(conn, sel, &opts); err != nil {
+		return nil, fmt.Errorf("couldn't load messages: %w", err)
+	}
+	return r, nil
+}
+
+// Expire deletes messages logged in a channel before the given time.
+// The result is the number of messages deleted.
+func (l *Log) Expire(ctx context.Context, channel string, before time.Time) (int, error) {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get connection to expire messages: %w", err)
+	}
+	const del = `DELETE FROM chatlog WHERE channel = ? AND time < ?`
+	opts := sqlitex.ExecOptions{Args: []any{channel, before.UnixNano()}}
+	if err := sqlitex.Execute(conn, del, &opts); err != nil {
+		return 0, fmt.Errorf("couldn't expire messages: %w", err)
+	}
+	return conn.Changes(), nil
+}
+
+// ExpireAll deletes messages logged in any channel before the given time,
+// including channels which are no longer in use.
+// The result is the number of messages deleted.
+func (l *Log) ExpireAll(ctx context.Context, before time.Time) (int, error) {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get connection to expire messages: %w", err)
+	}
+	const del = `DELETE FROM chatlog WHERE time < ?`
+	opts := sqlitex.ExecOptions{Args: []any{before.UnixNano()}}
+	if err := sqlitex.Execute(conn, del, &opts); err != nil {
+		return 0, fmt.Errorf("couldn't expire messages: %w", err)
+	}
+	return conn.Changes(), nil
+}
+
+// Forget deletes a logged message by ID.
+func (l *Log) Forget(ctx context.Context, channel, id string) error {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't get connection to forget message: %w", err)
+	}
+	const del = `DELETE FROM chatlog WHERE channel = ? AND id = ?`
+	opts := sqlitex.ExecOptions{Args: []any{channel, id}}
+	if err := sqlitex.Execute(conn, del, &opts); err != nil {
+		return fmt.Errorf("couldn't forget message: %w", err)
+	}
+	return nil
+}
+
+// ForgetSender deletes all messages logged in a channel from a sender.
+func (l *Log) ForgetSender(ctx context.Context, channel, sender string) error {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't get connection to forget sender: %w", err)
+	}
+	const del = `DELETE FROM chatlog WHERE channel = ? AND sender = ?`
+	opts := sqlitex.ExecOptions{Args: []any{channel, []byte(sender)}}
+	if err := sqlitex.Execute(conn, del, &opts); err != nil {
+		return fmt.Errorf("couldn't forget sender: %w", err)
+	}
+	return nil
+}
+
+// ForgetUser deletes all messages logged in every channel from a sender.
+func (l *Log) ForgetUser(ctx context.Context, sender string) error {
+	conn, err := l.db.Take(ctx)
+	defer l.db.Put(conn)
+	if err != nil {
+		return fmt.Errorf("couldn't get connection to forget user: %w", err)
+	}
+	const del = `DELETE FROM chatlog WHERE sender = ?`
+	opts := sqlitex.ExecOptions{Args: []any{[]byte(sender)}}
+	if err := sqlitex.Execute(conn, del, &opts); err != nil {
+		return fmt.Errorf("couldn't forget user: %w", err)
+	}
+	return nil
+}
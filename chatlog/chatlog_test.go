@@ -0,0 +1,173 @@
+package chatlog_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/chatlog"
+)
+
+var dbCount atomic.Int64
+
+func testDB() *sqlitex.Pool {
+	k := dbCount.Add(1)
+	pool, err := sqlitex.NewPool(fmt.Sprintf("file:test-chatlog-%d.db?mode=memory&cache=shared", k), sqlitex.PoolOptions{Flags: sqlite.OpenReadWrite | sqlite.OpenCreate | sqlite.OpenMemory | sqlite.OpenSharedCache | sqlite.OpenURI})
+	if err != nil {
+		panic(err)
+	}
+	return pool
+}
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	l, err := chatlog.Open(ctx, testDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []struct {
+		ch  string
+		msg chatlog.Message
+	}{
+		{"#bocchi", chatlog.Message{Time: time.Unix(1, 0), ID: "1", Sender: "bocchi", Text: "ryo"}},
+		{"#bocchi", chatlog.Message{Time: time.Unix(3, 0), ID: "3", Sender: "nijika", Text: "kita"}},
+		{"#bocchi", chatlog.Message{Time: time.Unix(2, 0), ID: "2", Sender: "ryo", Text: "bocchi"}},
+		{"#kessoku", chatlog.Message{Time: time.Unix(2, 0), ID: "4", Sender: "kita", Text: "nijika"}},
+	}
+	for _, m := range msgs {
+		if err := l.Add(ctx, m.ch, &m.msg); err != nil {
+			t.Fatalf("couldn't add %v: %v", m, err)
+		}
+	}
+	got, err := l.Since(ctx, "#bocchi", time.Unix(2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []chatlog.Message{msgs[2].msg, msgs[1].msg}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong messages (+got/-want):\n%s", diff)
+	}
+	n, err := l.Expire(ctx, "#bocchi", time.Unix(3, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("wrong number expired: want 2, got %d", n)
+	}
+	got, err = l.Since(ctx, "#bocchi", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want[1:], got); diff != "" {
+		t.Errorf("wrong messages after expiring (+got/-want):\n%s", diff)
+	}
+	got, err = l.Since(ctx, "#kessoku", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]chatlog.Message{msgs[3].msg}, got); diff != "" {
+		t.Errorf("wrong messages in other channel (+got/-want):\n%s", diff)
+	}
+	n, err = l.ExpireAll(ctx, time.Unix(3, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("wrong number expired from all channels: want 1, got %d", n)
+	}
+	got, err = l.Since(ctx, "#kessoku", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("messages remain after expiring all channels: %v", got)
+	}
+}
+
+func TestForget(t *testing.T) {
+	ctx := context.Background()
+	l, err := chatlog.Open(ctx, testDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []chatlog.Message{
+		{Time: time.Unix(1, 0), ID: "1", Sender: "bocchi", Text: "ryo"},
+		{Time: time.Unix(2, 0), ID: "2", Sender: "ryo", Text: "bocchi"},
+		{Time: time.Unix(3, 0), ID: "3", Sender: "nijika", Text: "kita"},
+		{Time: time.Unix(4, 0), ID: "4", Sender: "bocchi", Text: "nijika"},
+	}
+	for _, m := range msgs {
+		if err := l.Add(ctx, "#bocchi", &m); err != nil {
+			t.Fatalf("couldn't add %v: %v", m, err)
+		}
+	}
+	// Same sender in another channel must be unaffected.
+	other := chatlog.Message{Time: time.Unix(5, 0), ID: "5", Sender: "bocchi", Text: "kita"}
+	if err := l.Add(ctx, "#kessoku", &other); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Forget(ctx, "#bocchi", "2"); err != nil {
+		t.Errorf("couldn't forget message: %v", err)
+	}
+	if err := l.ForgetSender(ctx, "#bocchi", "bocchi"); err != nil {
+		t.Errorf("couldn't forget sender: %v", err)
+	}
+	got, err := l.Since(ctx, "#bocchi", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(msgs[2:3], got); diff != "" {
+		t.Errorf("wrong messages after forgetting (+got/-want):\n%s", diff)
+	}
+	got, err = l.Since(ctx, "#kessoku", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]chatlog.Message{other}, got); diff != "" {
+		t.Errorf("wrong messages in other channel (+got/-want):\n%s", diff)
+	}
+}
+
+func TestForgetUser(t *testing.T) {
+	ctx := context.Background()
+	l, err := chatlog.Open(ctx, testDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := []struct {
+		ch  string
+		msg chatlog.Message
+	}{
+		{"#bocchi", chatlog.Message{Time: time.Unix(1, 0), ID: "1", Sender: "bocchi", Text: "ryo"}},
+		{"#bocchi", chatlog.Message{Time: time.Unix(2, 0), ID: "2", Sender: "ryo", Text: "bocchi"}},
+		{"#kessoku", chatlog.Message{Time: time.Unix(3, 0), ID: "3", Sender: "bocchi", Text: "kita"}},
+	}
+	for _, m := range msgs {
+		if err := l.Add(ctx, m.ch, &m.msg); err != nil {
+			t.Fatalf("couldn't add %v: %v", m, err)
+		}
+	}
+	if err := l.ForgetUser(ctx, "bocchi"); err != nil {
+		t.Errorf("couldn't forget user: %v", err)
+	}
+	got, err := l.Since(ctx, "#bocchi", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]chatlog.Message{msgs[1].msg}, got); diff != "" {
+		t.Errorf("wrong messages after forgetting (+got/-want):\n%s", diff)
+	}
+	got, err = l.Since(ctx, "#kessoku", time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("messages remain in other channel: %v", got)
+	}
+}
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS chatlog (
+	-- Channel where the message was sent.
+	channel TEXT NOT NULL,
+	-- Message ID.
+	id TEXT NOT NULL,
+	-- Sender's user ID.
+	sender TEXT NOT NULL,
+	-- Message text.
+	text TEXT NOT NULL,
+	-- Message timestamp as nanoseconds from the UNIX epoch.
+	time INTEGER NOT NULL
+) STRICT;
+
+-- Index for loading and expiring by time.
+CREATE INDEX IF NOT EXISTS chatlog_time ON chatlog (channel, time);
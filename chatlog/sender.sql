@@ -0,0 +1,23 @@
+-- Senders were user IDs. Rather than keep them, drop the log; it only ever
+-- holds one history window of messages.
+DROP TABLE chatlog;
+
+CREATE TABLE chatlog (
+	-- Channel where the message was sent.
+	channel TEXT NOT NULL,
+	-- Message ID.
+	id TEXT NOT NULL,
+	-- Keyed hash of the sender's user ID.
+	sender BLOB NOT NULL,
+	-- Message text.
+	text TEXT NOT NULL,
+	-- Message timestamp as nanoseconds from the UNIX epoch.
+	time INTEGER NOT NULL
+) STRICT;
+
+-- Index for loading and expiring by time.
+CREATE INDEX chatlog_time ON chatlog (channel, time);
+-- Index for forgetting by sender.
+CREATE INDEX chatlog_sender ON chatlog (sender, channel);
+-- Index for expiring across channels.
+CREATE INDEX chatlog_expire ON chatlog (time);
@@ -3,9 +3,13 @@ package command
 import (
 	"context"
 	"log/slog"
+	"math/rand/v2"
+
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/zephyrtronium/robot/brain"
 	"github.com/zephyrtronium/robot/channel"
+	"github.com/zephyrtronium/robot/chatlog"
 	"github.com/zephyrtronium/robot/message"
 	"github.com/zephyrtronium/robot/privacy"
 	"github.com/zephyrtronium/robot/spoken"
@@ -19,8 +23,21 @@ type Robot struct {
 	Brain    brain.Brain
 	Privacy  *privacy.List
 	Spoken   *spoken.History
-	Owner    string
-	Contact  string
+	// ChatLog is the persistent log of channel histories.
+	// It is nil if histories are not persisted.
+	ChatLog *chatlog.Log
+	// Sender gets the key which identifies a user ID as a sender in channel
+	// histories and the chat log.
+	Sender       func(id string) string
+	Owner        string
+	Contact      string
+	SpeakLatency prometheus.ObserverVec
+	// RateLimited counts actions rejected by rate limits, by channel and
+	// the name of the limit.
+	RateLimited *prometheus.CounterVec
+	// Rand is the source of random choices for the invocation, including
+	// seeds for generated messages.
+	Rand *rand.Rand
 }
 
 // Invocation is a command invocation. An Invocation and its fields must not
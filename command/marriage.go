@@ -2,10 +2,10 @@ package command
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"math"
-	"math/rand/v2"
 	"strings"
 	"time"
 
@@ -13,6 +13,8 @@ import (
 	"gitlab.com/zephyrtronium/pick"
 )
 
+// score computes a user's affection score from a channel's history.
+// user is the user's sender key, as given by [Robot.Sender].
 func score(log *slog.Logger, h *channel.History, user string) (x float64, c, f, l, n int) {
 	mine := make(map[string]map[string]struct{})
 	for m := range h.All() {
@@ -23,7 +25,7 @@ func score(log *slog.Logger, h *channel.History, user string) (x float64, c, f,
 		m, ok := mine[text]
 		if who != user {
 			if ok {
-				log.Debug("scoring meme", slog.String("user", user), slog.String("memer", who), slog.String("msg", text))
+				log.Debug("scoring meme", slog.String("user", hex.EncodeToString([]byte(user))), slog.String("memer", hex.EncodeToString([]byte(who))), slog.String("msg", text))
 				m[who] = struct{}{}
 				c += len(m) // n.b. quadratic growth for this component
 			}
@@ -31,7 +33,7 @@ func score(log *slog.Logger, h *channel.History, user string) (x float64, c, f,
 		}
 		// Count the messages you sent.
 		if !ok {
-			log.Debug("scoring first", slog.String("user", user), slog.String("msg", text))
+			log.Debug("scoring first", slog.String("user", hex.EncodeToString([]byte(user))), slog.String("msg", text))
 			m = map[string]struct{}{user: {}}
 			mine[text] = m
 			f++
@@ -49,7 +51,7 @@ func score(log *slog.Logger, h *channel.History, user string) (x float64, c, f,
 	// a metric like tf-idf. However, this can't be too expensive to compute.
 	x = float64(c*c)/float64(f+1) + float64(c*f+f) + math.Sqrt(float64(l))
 	log.Info("user score",
-		slog.String("user", user),
+		slog.String("user", hex.EncodeToString([]byte(user))),
 		slog.Int("msgs", n),
 		slog.Int("freq", f),
 		slog.Int("meme", c),
@@ -76,9 +78,9 @@ var affections = pick.New([]pick.Case[string]{
 // Affection describes the caller's affection MMR.
 // No arguments.
 func Affection(ctx context.Context, robo *Robot, call *Invocation) {
-	x, c, f, l, n := score(robo.Log, call.Channel.History, call.Message.Sender)
+	x, c, f, l, n := score(robo.Log, call.Channel.History, robo.Sender(call.Message.Sender))
 	// Anything we do will require an emote.
-	e := call.Channel.Emotes.Pick(rand.Uint32())
+	e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 	if x == 0 {
 		// Check for the broadcaster. They get special treatment.
 		if strings.EqualFold(call.Message.Name, strings.TrimPrefix(call.Channel.Name, "#")) {
@@ -94,13 +96,14 @@ func Affection(ctx context.Context, robo *Robot, call *Invocation) {
 		call.Channel.Message(ctx, call.Message.ID, "literally zero "+e)
 		return
 	}
-	s := affections.Pick(rand.Uint32())
+	s := affections.Pick(robo.Rand.Uint32())
 	call.Channel.Message(ctx, call.Message.ID, fmt.Sprintf(s, x, e, c, f, l, n))
 }
 
 type partnerKey struct{}
 
 type partner struct {
+	// who is the partner's sender key.
 	who   string
 	until time.Time
 }
@@ -108,14 +111,15 @@ type partner struct {
 // Marry proposes to the robo.
 //   - partnership: Type of partnership requested, e.g. "wife", "waifu", "daddy". Optional.
 func Marry(ctx context.Context, robo *Robot, call *Invocation) {
-	x, _, _, _, _ := score(robo.Log, call.Channel.History, call.Message.Sender)
-	e := call.Channel.Emotes.Pick(rand.Uint32())
+	who := robo.Sender(call.Message.Sender)
+	x, _, _, _, _ := score(robo.Log, call.Channel.History, who)
+	e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 	broadcaster := strings.EqualFold(call.Message.Name, strings.TrimPrefix(call.Channel.Name, "#")) && x == 0
 	if x < 10 && !broadcaster {
 		call.Channel.Message(ctx, call.Message.ID, "no "+e)
 		return
 	}
-	me := &partner{who: call.Message.Sender, until: call.Message.Time().Add(time.Hour)}
+	me := &partner{who: who, until: call.Message.Time().Add(time.Hour)}
 	for {
 		l, ok := call.Channel.Extra.LoadOrStore(partnerKey{}, me)
 		if !ok {
@@ -125,7 +129,7 @@ func Marry(ctx context.Context, robo *Robot, call *Invocation) {
 		}
 		cur := l.(*partner)
 		if cur.who == me.who {
-			if rand.Uint32() <= 0xffffffff*3/5 {
+			if robo.Rand.Uint32() <= 0xffffffff*3/5 {
 				if !call.Channel.Extra.CompareAndDelete(partnerKey{}, cur) {
 					// Partner changed concurrently.
 					// Really we are guaranteed to fail on time now,
@@ -4,7 +4,13 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/channel"
 )
 
 func Forget(ctx context.Context, robo *Robot, call *Invocation) {
@@ -20,6 +26,7 @@ func Forget(ctx context.Context, robo *Robot, call *Invocation) {
 			slog.String("tag", call.Channel.Learn),
 			slog.String("id", m.ID),
 		)
+		channel.Invalidate(robo.Channels.All(), call.Channel.Learn, m.ID)
 		err := robo.Brain.ForgetMessage(ctx, call.Channel.Learn, m.ID)
 		if err != nil {
 			robo.Log.ErrorContext(ctx, "failed to forget",
@@ -28,6 +35,17 @@ func Forget(ctx context.Context, robo *Robot, call *Invocation) {
 				slog.String("id", m.ID),
 			)
 		}
+		// Forgotten messages shouldn't count for affection or be found by
+		// later forgets.
+		call.Channel.History.Remove(m.ID)
+		if robo.ChatLog != nil {
+			if err := robo.ChatLog.Forget(ctx, call.Channel.Name, m.ID); err != nil {
+				robo.Log.ErrorContext(ctx, "failed to forget from chat log",
+					slog.Any("err", err),
+					slog.String("id", m.ID),
+				)
+			}
+		}
 	}
 	switch n {
 	case 0:
@@ -38,3 +56,107 @@ func Forget(ctx context.Context, robo *Robot, call *Invocation) {
 		call.Channel.Message(ctx, call.Message.ID, fmt.Sprintf("Forgot %d messages.", n))
 	}
 }
+
+// ForgetSource forgets only the messages which contributed a phrase to the
+// most recent generated message containing it.
+func ForgetSource(ctx context.Context, robo *Robot, call *Invocation) {
+	phrase := call.Args["phrase"]
+	// We use the send tag because we are forgetting something we sent.
+	tag := call.Channel.Send
+	var ids []string
+	for p, err := range robo.Spoken.Recent(ctx, tag, call.Message.Time().Add(-time.Hour)) {
+		if err != nil {
+			robo.Log.ErrorContext(ctx, "couldn't get recent messages",
+				slog.Any("err", err),
+				slog.String("tag", tag),
+			)
+			call.Channel.Message(ctx, call.Message.ID, "Something went wrong while remembering what I said.")
+			return
+		}
+		start, end := indexFold(p.Orig, phrase)
+		if start < 0 {
+			continue
+		}
+		for _, sp := range p.Spans {
+			if sp.Start < end && sp.End > start && !slices.Contains(ids, sp.ID) {
+				robo.Log.InfoContext(ctx, "forget source",
+					slog.String("tag", tag),
+					slog.String("id", sp.ID),
+					slog.String("term", p.Orig[sp.Start:sp.End]),
+					slog.Int("context", sp.Context),
+				)
+				ids = append(ids, sp.ID)
+			}
+		}
+		break
+	}
+	if len(ids) == 0 {
+		call.Channel.Message(ctx, call.Message.ID, fmt.Sprintf("I haven't said %q recently.", phrase))
+		return
+	}
+	channel.Invalidate(robo.Channels.All(), tag, ids...)
+	for _, id := range ids {
+		err := robo.Brain.ForgetMessage(ctx, tag, id)
+		if err != nil {
+			robo.Log.ErrorContext(ctx, "failed to forget",
+				slog.Any("err", err),
+				slog.String("tag", tag),
+				slog.String("id", id),
+			)
+		}
+	}
+	if len(ids) == 1 {
+		call.Channel.Message(ctx, call.Message.ID, "Forgot the 1 message that taught me that.")
+		return
+	}
+	call.Channel.Message(ctx, call.Message.ID, fmt.Sprintf("Forgot the %d messages that taught me that.", len(ids)))
+}
+
+// indexFold returns the byte offsets of the start and end of the first
+// case-insensitive instance of substr in s, or -1, -1 if there is none.
+// Case folding can change the length of a character, as with K and the Kelvin
+// sign, so the instance can be longer or shorter than substr.
+func indexFold(s, substr string) (start, end int) {
+	for i := range s {
+		j, t := i, substr
+		for t != "" && j < len(s) {
+			_, n := utf8.DecodeRuneInString(s[j:])
+			_, m := utf8.DecodeRuneInString(t)
+			if !strings.EqualFold(s[j:j+n], t[:m]) {
+				break
+			}
+			j, t = j+n, t[m:]
+		}
+		if t == "" {
+			return i, j
+		}
+	}
+	return -1, -1
+}
+
+// Stats reports how much the brain knows about the channel's learn tag.
+func Stats(ctx context.Context, robo *Robot, call *Invocation) {
+	st, ok := robo.Brain.(brain.Statter)
+	if !ok {
+		call.Channel.Message(ctx, call.Message.ID, "I can't count what I know.")
+		return
+	}
+	s, err := st.Stats(ctx, call.Channel.Learn)
+	if err != nil {
+		robo.Log.ErrorContext(ctx, "couldn't get stats",
+			slog.Any("err", err),
+			slog.String("tag", call.Channel.Learn),
+		)
+		call.Channel.Message(ctx, call.Message.ID, "Something went wrong while counting what I know.")
+		return
+	}
+	if s.Messages == 0 {
+		call.Channel.Message(ctx, call.Message.ID, "I don't know anything here yet.")
+		return
+	}
+	msg := fmt.Sprintf("I know %d messages with %d tuples over %d prefixes.", s.Messages, s.Tuples, s.Prefixes)
+	if !s.Oldest.IsZero() {
+		msg += fmt.Sprintf(" The oldest is from %s.", s.Oldest.UTC().Format("2006-01-02"))
+	}
+	call.Channel.Message(ctx, call.Message.ID, msg)
+}
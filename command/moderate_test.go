@@ -0,0 +1,139 @@
+package command_test
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/brain/membrain"
+	"github.com/zephyrtronium/robot/channel"
+	"github.com/zephyrtronium/robot/command"
+	"github.com/zephyrtronium/robot/message"
+	"github.com/zephyrtronium/robot/spoken"
+	"github.com/zephyrtronium/robot/syncmap"
+	"github.com/zephyrtronium/robot/userhash"
+)
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	br := membrain.New()
+	var got []string
+	ch := &channel.Channel{
+		Name:  "#kessoku",
+		Learn: "kessoku",
+		Message: func(ctx context.Context, reply, text string) {
+			got = append(got, text)
+		},
+	}
+	robo := &command.Robot{Brain: br}
+	call := &command.Invocation{Channel: ch, Message: &message.Received{}}
+	command.Stats(ctx, robo, call)
+	msgs := [][]string{{"bocchi ", "the ", "rock "}, {"kita "}}
+	for i, toks := range msgs {
+		if err := brain.Learn(ctx, br, "kessoku", string(rune('1'+i)), userhash.Hash{}, time.Date(2022, 10, 9+i, 0, 0, 0, 0, time.UTC), toks); err != nil {
+			t.Fatalf("couldn't learn: %v", err)
+		}
+	}
+	command.Stats(ctx, robo, call)
+	want := []string{
+		"I don't know anything here yet.",
+		"I know 2 messages with 6 tuples over 5 prefixes. The oldest is from 2022-10-09.",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of messages: want %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("wrong message %d: want %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+// forgetRecorder is a brain which records the messages it is told to forget.
+type forgetRecorder struct {
+	brain.Brain
+	ids []string
+}
+
+func (f *forgetRecorder) ForgetMessage(ctx context.Context, tag, id string) error {
+	f.ids = append(f.ids, tag+"/"+id)
+	return nil
+}
+
+var spokenCount atomic.Int64
+
+func TestForgetSource(t *testing.T) {
+	// The Kelvin sign folds to k but is three bytes long.
+	const orig = "\u212Aita ikuyo bocchi"
+	spans := []brain.Span{
+		{ID: "1", Start: 0, End: len("\u212Aita ")},
+		{ID: "2", Start: len("\u212Aita "), End: len("\u212Aita ikuyo ")},
+		{ID: "3", Start: len("\u212Aita ikuyo "), End: len(orig)},
+	}
+	cases := []struct {
+		name   string
+		phrase string
+		ids    []string
+		reply  string
+	}{
+		{"one", "kita", []string{"kessoku/1"}, "Forgot the 1 message that taught me that."},
+		{"folded", "KITA IKUYO", []string{"kessoku/1", "kessoku/2"}, "Forgot the 2 messages that taught me that."},
+		{"kelvin", "\u212Aita", []string{"kessoku/1"}, "Forgot the 1 message that taught me that."},
+		{"end", "yo bocchi", []string{"kessoku/2", "kessoku/3"}, "Forgot the 2 messages that taught me that."},
+		{"none", "ryo", nil, `I haven't said "ryo" recently.`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := context.Background()
+			k := spokenCount.Add(1)
+			pool, err := sqlitex.NewPool(fmt.Sprintf("file:test-forget-source-%d.db?mode=memory&cache=shared", k), sqlitex.PoolOptions{Flags: sqlite.OpenReadWrite | sqlite.OpenCreate | sqlite.OpenMemory | sqlite.OpenSharedCache | sqlite.OpenURI})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pool.Close()
+			h, err := spoken.Open(ctx, pool)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Unix(1700000000, 0)
+			if err := h.Record(ctx, "kessoku", orig, []string{"1", "2", "3"}, spans, now.Add(-time.Minute), 0, 0, orig, "", ""); err != nil {
+				t.Fatal(err)
+			}
+			br := &forgetRecorder{Brain: membrain.New()}
+			var got []string
+			ch := &channel.Channel{
+				Name: "#kessoku",
+				Send: "kessoku",
+				Message: func(ctx context.Context, reply, text string) {
+					got = append(got, text)
+				},
+			}
+			robo := &command.Robot{
+				Log:      slog.Default(),
+				Channels: syncmap.New[string, *channel.Channel](),
+				Brain:    br,
+				Spoken:   h,
+			}
+			call := &command.Invocation{
+				Channel: ch,
+				Message: &message.Received{Timestamp: now.UnixMilli()},
+				Args:    map[string]string{"phrase": c.phrase},
+			}
+			command.ForgetSource(ctx, robo, call)
+			if !slices.Equal(br.ids, c.ids) {
+				t.Errorf("wrong messages forgotten: want %q, got %q", c.ids, br.ids)
+			}
+			if len(got) != 1 || got[0] != c.reply {
+				t.Errorf("wrong reply: want %q, got %q", c.reply, got)
+			}
+		})
+	}
+}
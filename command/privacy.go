@@ -3,7 +3,6 @@ package command
 import (
 	"context"
 	"log/slog"
-	"math/rand/v2"
 )
 
 func Private(ctx context.Context, robo *Robot, call *Invocation) {
@@ -13,7 +12,13 @@ func Private(ctx context.Context, robo *Robot, call *Invocation) {
 		call.Channel.Message(ctx, call.Message.ID, "Something went wrong while trying to add you to the privacy list. Try again. Sorry!")
 		return
 	}
-	e := call.Channel.Emotes.Pick(rand.Uint32())
+	// The chat log keeps only messages from users who allow learning.
+	if robo.ChatLog != nil {
+		if err := robo.ChatLog.ForgetUser(ctx, robo.Sender(call.Message.Sender)); err != nil {
+			robo.Log.ErrorContext(ctx, "couldn't forget user from chat log", slog.Any("err", err), slog.String("channel", call.Channel.Name))
+		}
+	}
+	e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 	call.Channel.Message(ctx, call.Message.ID, `Sure, I won't learn from your messages. Most of my functionality will still work for you. If you'd like to have me learn from you again, just tell me, "learn from me again." `+e)
 }
 
@@ -24,7 +29,7 @@ func Unprivate(ctx context.Context, robo *Robot, call *Invocation) {
 		call.Channel.Message(ctx, call.Message.ID, "Something went wrong while trying to add you to the privacy list. Try again. Sorry!")
 		return
 	}
-	e := call.Channel.Emotes.Pick(rand.Uint32())
+	e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 	call.Channel.Message(ctx, call.Message.ID, `Sure, I'll learn from you again! `+e)
 }
 
@@ -2,13 +2,14 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"math/rand/v2"
 	"regexp"
 	"time"
 
 	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/channel"
 )
 
 func speakCmd(ctx context.Context, robo *Robot, call *Invocation, effect string) string {
@@ -20,27 +21,43 @@ func speakCmd(ctx context.Context, robo *Robot, call *Invocation, effect string)
 			slog.String("from", call.Message.Name),
 			slog.String("prompt", call.Args["prompt"]),
 		)
-		e := call.Channel.Emotes.Pick(rand.Uint32())
+		e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 		return "no " + e
 	}
-	start := time.Now()
-	m, trace, err := brain.Speak(ctx, robo.Brain, call.Channel.Send, call.Args["prompt"])
-	cost := time.Since(start)
-	if err != nil {
-		robo.Log.ErrorContext(ctx, "couldn't speak", "err", err.Error())
-		return ""
+	var c channel.Candidate
+	ok := false
+	if call.Args["prompt"] == "" {
+		// Prompted messages have to be generated live, but unprompted ones
+		// might be waiting for us already.
+		c, ok = call.Channel.Pregen.Take()
+	}
+	if !ok {
+		var err error
+		c, err = call.Channel.Generate(ctx, robo.Log, robo.Brain, call.Args["prompt"], robo.Rand, robo.SpeakLatency.WithLabelValues(call.Channel.Send))
+		if errors.Is(err, brain.ErrRejected) {
+			robo.Log.WarnContext(ctx, "generated blocked message",
+				slog.String("in", call.Channel.Name),
+				slog.String("text", c.Text),
+			)
+			return ""
+		}
+		if err != nil {
+			robo.Log.ErrorContext(ctx, "couldn't speak", "err", err.Error())
+			return ""
+		}
 	}
+	m, trace, spans, cost := c.Text, c.Trace, c.Spans, c.Cost
 	if m == "" {
 		robo.Log.InfoContext(ctx, "spoke nothing", slog.String("tag", call.Channel.Send), slog.String("prompt", call.Args["prompt"]))
 		return ""
 	}
-	e := call.Channel.Emotes.Pick(rand.Uint32())
+	e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 	s := m + " " + e
-	if err := robo.Spoken.Record(ctx, call.Channel.Send, s, trace, call.Message.Time(), cost, m, e, effect); err != nil {
+	if err := robo.Spoken.Record(ctx, call.Channel.Send, s, trace, spans, call.Message.Time(), cost, c.Seed, m, e, effect); err != nil {
 		robo.Log.ErrorContext(ctx, "couldn't record trace", slog.Any("err", err))
 		return ""
 	}
-	if call.Channel.Block.MatchString(s) {
+	if call.Channel.Settings.Load().Block.MatchString(s) {
 		robo.Log.WarnContext(ctx, "generated blocked message",
 			slog.String("in", call.Channel.Name),
 			slog.String("text", m),
@@ -48,15 +65,14 @@ func speakCmd(ctx context.Context, robo *Robot, call *Invocation, effect string)
 		)
 		return ""
 	}
-	t := time.Now()
-	r := call.Channel.Rate.ReserveN(t, 1)
-	if d := r.DelayFrom(t); d > 0 {
+	if lim, d := call.Channel.Reserve(time.Now(), channel.BudgetCommand); lim != "" {
+		robo.RateLimited.WithLabelValues(call.Channel.Name, lim).Inc()
 		robo.Log.InfoContext(ctx, "won't speak; rate limited",
 			slog.String("action", "command"),
 			slog.String("in", call.Channel.Name),
+			slog.String("budget", lim),
 			slog.String("delay", d.String()),
 		)
-		r.CancelAt(t)
 		return ""
 	}
 	// block the generated message from being later recognized as a meme.
@@ -106,19 +122,18 @@ func AAAAA(ctx context.Context, robo *Robot, call *Invocation) {
 
 // Rawr says rawr.
 func Rawr(ctx context.Context, robo *Robot, call *Invocation) {
-	e := call.Channel.Emotes.Pick(rand.Uint32())
+	e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 	if e == "" {
 		e = ":3"
 	}
-	t := time.Now()
-	r := call.Channel.Rate.ReserveN(t, 1)
-	if d := r.DelayFrom(t); d > 0 {
+	if lim, d := call.Channel.Reserve(time.Now(), channel.BudgetSystem); lim != "" {
+		robo.RateLimited.WithLabelValues(call.Channel.Name, lim).Inc()
 		robo.Log.InfoContext(ctx, "won't rawr; rate limited",
 			slog.String("action", "rawr"),
 			slog.String("in", call.Channel.Name),
+			slog.String("budget", lim),
 			slog.String("delay", d.String()),
 		)
-		r.CancelAt(t)
 		return
 	}
 	call.Channel.Message(ctx, call.Message.ID, "rawr "+e)
@@ -135,13 +150,13 @@ func Source(ctx context.Context, robo *Robot, call *Invocation) {
 // Who describes Robot.
 func Who(ctx context.Context, robo *Robot, call *Invocation) {
 	const whoMessage = `I'm a Markov chain bot! I learn from things people say in chat, then spew vaguely intelligible memes back. More info at: https://github.com/zephyrtronium/robot#how-robot-works`
-	e := call.Channel.Emotes.Pick(rand.Uint32())
+	e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 	call.Channel.Message(ctx, call.Message.ID, whoMessage+" "+e)
 }
 
 // Contact gives information on how to contact the bot owner.
 func Contact(ctx context.Context, robo *Robot, call *Invocation) {
 	s := fmt.Sprintf("My operator is %[1]s. %[2]s is the best way to contact %[1]s.", robo.Owner, robo.Contact)
-	e := call.Channel.Emotes.Pick(rand.Uint32())
+	e := call.Channel.Settings.Load().Emotes.Pick(robo.Rand.Uint32())
 	call.Channel.Message(ctx, call.Message.ID, s+" "+e)
 }
@@ -1,15 +1,18 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -27,9 +30,13 @@ import (
 	"zombiezen.com/go/sqlite/sqlitex"
 
 	"github.com/zephyrtronium/robot/auth"
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/brain/httpbrain"
 	"github.com/zephyrtronium/robot/brain/kvbrain"
+	"github.com/zephyrtronium/robot/brain/membrain"
 	"github.com/zephyrtronium/robot/brain/sqlbrain"
 	"github.com/zephyrtronium/robot/channel"
+	"github.com/zephyrtronium/robot/chatlog"
 	"github.com/zephyrtronium/robot/message"
 	"github.com/zephyrtronium/robot/privacy"
 	"github.com/zephyrtronium/robot/spoken"
@@ -47,6 +54,20 @@ func Load(ctx context.Context, r io.Reader) (*Config, *toml.MetaData, error) {
 	return &cfg, &md, nil
 }
 
+// loadFile loads Robot from a TOML configuration file.
+func loadFile(ctx context.Context, name string) (*Config, *toml.MetaData, error) {
+	r, err := os.Open(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't open config file: %w", err)
+	}
+	defer r.Close()
+	cfg, md, err := Load(ctx, r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't load config: %w", err)
+	}
+	return cfg, md, nil
+}
+
 // SetOwner sets owner metadata used in self-description commands.
 func (robo *Robot) SetOwner(ownerName, ownerContact string) {
 	robo.owner = ownerName
@@ -68,22 +89,13 @@ func loadSecrets(file string) (*keys, error) {
 	return r, nil
 }
 
-// SetSources opens the brain and privacy list wrappers around the respective
-// databases. Use [loadDBs] to open the databases themselves from DSNs.
-// Panics if both kv and sql are nil.
-func (robo *Robot) SetSources(ctx context.Context, kv *badger.DB, sql, priv, spoke *sqlitex.Pool) error {
+// SetSources sets the brain and opens the privacy list, spoken history, and
+// chat log wrappers around the respective databases. Use [loadDBs] to open the
+// databases themselves from DSNs and [loadBrain] to open the brain.
+// If hist is nil, channel histories are not persisted.
+func (robo *Robot) SetSources(ctx context.Context, br brain.Brain, priv, spoke, hist *sqlitex.Pool) error {
 	var err error
-	if sql == nil {
-		if kv == nil {
-			panic("robot: no brain")
-		}
-		robo.brain = kvbrain.New(kv)
-	} else {
-		robo.brain, err = sqlbrain.Open(ctx, sql)
-	}
-	if err != nil {
-		return fmt.Errorf("couldn't open brain: %w", err)
-	}
+	robo.brain = br
 	robo.privacy, err = privacy.Open(ctx, priv)
 	if err != nil {
 		return fmt.Errorf("couldn't open privacy list: %w", err)
@@ -92,9 +104,110 @@ func (robo *Robot) SetSources(ctx context.Context, kv *badger.DB, sql, priv, spo
 	if err != nil {
 		return fmt.Errorf("couldn't open spoken history: %w", err)
 	}
+	if hist != nil {
+		robo.chatlog, err = chatlog.Open(ctx, hist)
+		if err != nil {
+			return fmt.Errorf("couldn't open chat log: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadBrain opens the brain selected by cfg around the databases opened by
+// [loadDBs]. If cfg selects membrain, its snapshot is loaded if it exists.
+// Panics if no brain is selected.
+func loadBrain(ctx context.Context, cfg DBCfg, kv *badger.DB, sql *sqlitex.Pool) (brain.Brain, error) {
+	switch {
+	case sql != nil:
+		br, err := sqlbrain.Open(ctx, sql)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open brain: %w", err)
+		}
+		return br, nil
+	case kv != nil:
+		return kvbrain.New(kv), nil
+	case cfg.MemBrain != "":
+		br := membrain.New()
+		f, err := os.Open(cfg.MemBrain)
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.InfoContext(ctx, "no membrain snapshot", slog.String("path", cfg.MemBrain))
+			return br, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open membrain snapshot: %w", err)
+		}
+		defer f.Close()
+		if err := br.Load(ctx, bufio.NewReader(f)); err != nil {
+			return nil, err
+		}
+		return br, nil
+	case cfg.Remote != "":
+		tok, err := os.ReadFile(cfg.RemoteToken)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read brain server token: %w", err)
+		}
+		slog.DebugContext(ctx, "using brain server", slog.String("url", cfg.Remote))
+		// Deadlines come from request contexts. A client timeout would also
+		// cut off streams, so only limit how long the server takes to answer.
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.ResponseHeaderTimeout = httpbrain.RequestTimeout
+		client := &http.Client{Transport: tr}
+		return httpbrain.New(cfg.Remote, string(bytes.TrimSpace(tok)), client), nil
+	default:
+		panic("robot: no brain")
+	}
+}
+
+// saveBrain writes a snapshot of br if cfg selects membrain.
+// It does nothing for other brains.
+func saveBrain(ctx context.Context, cfg DBCfg, br brain.Brain) error {
+	mem, ok := br.(*membrain.Brain)
+	if !ok || cfg.MemBrain == "" {
+		return nil
+	}
+	// Write to a temporary file first so that a failure doesn't destroy the
+	// previous snapshot.
+	tmp := cfg.MemBrain + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("couldn't create membrain snapshot: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	if err := mem.Save(ctx, w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("couldn't write membrain snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("couldn't write membrain snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("couldn't write membrain snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, cfg.MemBrain); err != nil {
+		return fmt.Errorf("couldn't replace membrain snapshot: %w", err)
+	}
+	slog.InfoContext(ctx, "saved membrain snapshot", slog.String("path", cfg.MemBrain))
 	return nil
 }
 
+// SetSnapshot arranges for Run to periodically save the brain if cfg selects
+// membrain, so that a crash loses only what was learned since the last save.
+func (robo *Robot) SetSnapshot(cfg DBCfg) {
+	if _, ok := robo.brain.(*membrain.Brain); !ok || cfg.MemBrain == "" {
+		return
+	}
+	robo.snapshotEvery = time.Duration(cfg.Snapshot * float64(time.Minute))
+	if robo.snapshotEvery <= 0 {
+		robo.snapshotEvery = 10 * time.Minute
+	}
+	robo.snapshot = func(ctx context.Context) error { return saveBrain(ctx, cfg, robo.brain) }
+}
+
 // InitTwitch initializes the Twitch and TMI clients and channel configuration.
 func (robo *Robot) InitTwitch(ctx context.Context, cfg ClientCfg, secrets *keys, clientSecret string) error {
 	cfg.secret = clientSecret
@@ -280,16 +393,54 @@ func mergere(global, ch string) (*regexp.Regexp, error) {
 // SetTwitchChannels initializes Twitch channel configuration.
 // It must be called after SetTMI.
 func (robo *Robot) SetTwitchChannels(ctx context.Context, global Global, channels map[string]*ChannelCfg) error {
+	chans, err := robo.twitchChannels(global, channels)
+	if err != nil {
+		return err
+	}
+	setBudget(robo.copypasta, global.Copypasta)
+	for _, v := range chans {
+		robo.channels.Store(v.Name, v)
+	}
+	robo.twitchCfg = channelCfgs(channels)
+	return nil
+}
+
+// channelCfgs maps each channel name to its configuration.
+func channelCfgs(channels map[string]*ChannelCfg) map[string]*ChannelCfg {
+	r := make(map[string]*ChannelCfg)
+	for _, ch := range channels {
+		for _, p := range ch.Channels {
+			r[p] = ch
+		}
+	}
+	return r
+}
+
+// twitchChannels creates Twitch channels from their configuration without
+// adding them to the robot.
+func (robo *Robot) twitchChannels(global Global, channels map[string]*ChannelCfg) ([]*channel.Channel, error) {
 	// TODO(zeph): we can convert this to a SetChannels, where it just adds the
 	// channels for any given service
+	var r []*channel.Channel
 	for nm, ch := range channels {
 		blk, err := mergere(global.Block, ch.Block)
 		if err != nil {
-			return fmt.Errorf("bad global or channel block expression for twitch.%s: %w", nm, err)
+			return nil, fmt.Errorf("bad global or channel block expression for twitch.%s: %w", nm, err)
 		}
 		meme, err := mergere(global.Meme, ch.Meme)
 		if err != nil {
-			return fmt.Errorf("bad global or channel meme expression for twitch.%s: %w", nm, err)
+			return nil, fmt.Errorf("bad global or channel meme expression for twitch.%s: %w", nm, err)
+		}
+		norm, err := normalization(ch.Copypasta.Normalize)
+		if err != nil {
+			return nil, fmt.Errorf("bad copypasta normalization for twitch.%s: %w", nm, err)
+		}
+		var pastaIgn *regexp.Regexp
+		if ch.Copypasta.Ignore != "" {
+			pastaIgn, err = regexp.Compile(ch.Copypasta.Ignore)
+			if err != nil {
+				return nil, fmt.Errorf("bad copypasta ignore expression for twitch.%s: %w", nm, err)
+			}
 		}
 		emotes := pick.New(pick.FromMap(mergemaps(global.Emotes, ch.Emotes)))
 		effects := pick.New(pick.FromMap(mergemaps(global.Effects, ch.Effects)))
@@ -310,38 +461,213 @@ func (robo *Robot) SetTwitchChannels(ctx context.Context, global Global, channel
 				mod[p.ID] = true
 			}
 		}
+		for k := range ch.Budgets {
+			if !slices.Contains(channel.BudgetNames, k) {
+				return nil, fmt.Errorf("unknown budget %q for twitch.%s", k, nm)
+			}
+		}
+		sched, err := schedule(ch.Timezone, ch.Schedule, ch.Responses)
+		if err != nil {
+			return nil, fmt.Errorf("bad schedule for twitch.%s: %w", nm, err)
+		}
+		set := &channel.Settings{
+			Block:     blk,
+			Meme:      meme,
+			Responses: ch.Responses,
+			Budget:    time.Duration(ch.Budget) * time.Millisecond,
+			Ignore:    ign,
+			Mod:       mod,
+			Emotes:    emotes,
+			Effects:   effects,
+			Schedule:  sched,
+			Offline:   ch.Offline,
+			Idle:      fseconds(ch.Idle.After * 60),
+			IdleMax:   fseconds(ch.Idle.Max * 60),
+		}
+		if ch.Adaptive.Target > 0 {
+			set.Adaptive = &channel.Adaptive{
+				Target: ch.Adaptive.Target,
+				Min:    ch.Adaptive.Min,
+				Max:    ch.Adaptive.Max,
+			}
+		}
 		for _, p := range ch.Channels {
 			v := &channel.Channel{
 				Name:      p,
 				Learn:     ch.Learn,
 				Send:      ch.Send,
-				Block:     blk,
-				Meme:      meme,
-				Responses: ch.Responses,
+				Retain:    fseconds(ch.Retain * 24 * 60 * 60),
 				Rate:      rate.NewLimiter(rate.Every(fseconds(ch.Rate.Every)), ch.Rate.Num),
-				Ignore:    ign,
-				Mod:       mod,
-				History:   new(channel.History),
-				Memery:    channel.NewMemeDetector(ch.Copypasta.Need, fseconds(ch.Copypasta.Within)),
-				Emotes:    emotes,
-				Effects:   effects,
+				Budgets:   budgets(ch.Budgets),
+				History:   channel.NewHistory(fseconds(ch.History.Window), ch.History.Size),
+				Copypasta: robo.copypasta,
+				Memery: channel.NewMemeDetector(channel.MemeConfig{
+					Need:       ch.Copypasta.Need,
+					Within:     fseconds(ch.Copypasta.Within),
+					Normalize:  norm,
+					Similarity: ch.Copypasta.Similarity,
+					MinLength:  ch.Copypasta.MinLength,
+					MinTokens:  ch.Copypasta.MinTokens,
+					Ignore:     pastaIgn,
+					Fresh:      fseconds(ch.Copypasta.Fresh),
+				}),
+				Users:    users(ch.Users),
+				Velocity: channel.NewVelocity(fseconds(ch.Adaptive.Window)),
+				Pregen:   pregen(ch.Pregen),
 			}
+			v.Settings.Store(set)
 			v.Message = func(ctx context.Context, reply, text string) {
 				msg := message.Format(reply, v.Name, "%s", text)
 				robo.sendTMI(ctx, robo.tmi.send, msg)
 			}
-			robo.channels.Store(p, v)
+			r = append(r, v)
 		}
 	}
-	return nil
+	return r, nil
 }
 
-func loadDBs(ctx context.Context, cfg DBCfg) (kv *badger.DB, sql, priv, spoke *sqlitex.Pool, err error) {
-	if cfg.KVBrain != "" && cfg.SQLBrain != "" {
-		return nil, nil, nil, nil, fmt.Errorf("multiple brain backends requested; use exactly one")
+// schedule creates a channel schedule from its configuration.
+// It returns nil if there are no windows.
+func schedule(tz string, windows []WindowCfg, responses float64) (*channel.Schedule, error) {
+	if len(windows) == 0 {
+		return nil, nil
 	}
-	if cfg.KVBrain == "" && cfg.SQLBrain == "" {
-		return nil, nil, nil, nil, fmt.Errorf("no brain backends requested; use exactly one")
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load time zone: %w", err)
+	}
+	r := &channel.Schedule{Loc: loc, Windows: make([]channel.Window, 0, len(windows))}
+	for i, w := range windows {
+		start, err := timeOfDay(w.Start)
+		if err != nil {
+			return nil, fmt.Errorf("bad start time for window %d: %w", i, err)
+		}
+		end, err := timeOfDay(w.End)
+		if err != nil {
+			return nil, fmt.Errorf("bad end time for window %d: %w", i, err)
+		}
+		days := make([]time.Weekday, 0, len(w.Days))
+		for _, d := range w.Days {
+			wd, ok := weekdays[strings.ToLower(d)]
+			if !ok {
+				return nil, fmt.Errorf("unknown day %q in window %d", d, i)
+			}
+			days = append(days, wd)
+		}
+		p := responses
+		if w.Responses != nil {
+			p = *w.Responses
+		}
+		r.Windows = append(r.Windows, channel.Window{
+			Days:      days,
+			Start:     start,
+			End:       end,
+			Responses: p,
+			Quiet:     w.Quiet,
+		})
+	}
+	return r, nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// timeOfDay parses a time of day like "15:04" as a duration since midnight.
+func timeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// setBudget sets a limiter to allow n events per hour,
+// or unlimited if n is not positive.
+func setBudget(l *rate.Limiter, n int) {
+	if n <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	l.SetLimit(rate.Every(time.Hour / time.Duration(n)))
+	l.SetBurst(n)
+}
+
+// budgets creates a limiter for every budget name.
+// Budgets without configuration are unlimited.
+func budgets(cfg map[string]Rate) map[string]*rate.Limiter {
+	r := make(map[string]*rate.Limiter, len(channel.BudgetNames))
+	for _, k := range channel.BudgetNames {
+		c, ok := cfg[k]
+		if !ok {
+			r[k] = rate.NewLimiter(rate.Inf, 0)
+			continue
+		}
+		r[k] = rate.NewLimiter(rate.Every(fseconds(c.Every)), c.Num)
+	}
+	return r
+}
+
+// users creates per-user rate limits, or nil if they are disabled.
+func users(cfg UsersCfg) *channel.UserLimits {
+	if cfg.Every <= 0 {
+		return nil
+	}
+	return channel.NewUserLimits(channel.UserLimitConfig{
+		Every:   fseconds(cfg.Every),
+		Burst:   cfg.Num,
+		Size:    cfg.Size,
+		Strikes: cfg.Strikes,
+		Timeout: fseconds(cfg.Timeout),
+	})
+}
+
+// pregen creates a pre-generation queue of the given size,
+// or nil if the size is not positive.
+func pregen(size int) *channel.Pregen {
+	if size <= 0 {
+		return nil
+	}
+	return channel.NewPregen(size)
+}
+
+func normalization(names []string) (channel.Normalization, error) {
+	var n channel.Normalization
+	for _, s := range names {
+		switch strings.ToLower(s) {
+		case "case":
+			n |= channel.FoldCase
+		case "invisible":
+			n |= channel.StripInvisible
+		case "space":
+			n |= channel.CollapseSpace
+		case "emote":
+			n |= channel.TrimEmote
+		default:
+			return 0, fmt.Errorf("unknown normalization %q", s)
+		}
+	}
+	return n, nil
+}
+
+func loadDBs(ctx context.Context, cfg DBCfg) (kv *badger.DB, sql, priv, spoke, hist *sqlitex.Pool, err error) {
+	var backends int
+	for _, v := range []string{cfg.SQLBrain, cfg.KVBrain, cfg.MemBrain, cfg.Remote} {
+		if v != "" {
+			backends++
+		}
+	}
+	if backends > 1 {
+		return nil, nil, nil, nil, nil, fmt.Errorf("multiple brain backends requested; use exactly one")
+	}
+	if backends == 0 {
+		return nil, nil, nil, nil, nil, fmt.Errorf("no brain backends requested; use exactly one")
 	}
 
 	if cfg.KVBrain != "" {
@@ -353,14 +679,14 @@ func loadDBs(ctx context.Context, cfg DBCfg) (kv *badger.DB, sql, priv, spoke *s
 		opts = opts.WithBloomFalsePositive(0)
 		kv, err = badger.Open(opts.FromSuperFlag(cfg.KVFlag))
 		if err != nil {
-			return nil, nil, nil, nil, fmt.Errorf("couldn't open kvbrain db: %w", err)
+			return nil, nil, nil, nil, nil, fmt.Errorf("couldn't open kvbrain db: %w", err)
 		}
 	}
 	if cfg.SQLBrain != "" {
 		slog.DebugContext(ctx, "using sqlbrain", slog.String("path", cfg.SQLBrain))
 		sql, err = sqlitex.NewPool(cfg.SQLBrain, sqlitex.PoolOptions{PrepareConn: sqlbrain.RecommendedPrep})
 		if err != nil {
-			return nil, nil, nil, nil, fmt.Errorf("couldn't open sqlbrain db: %w", err)
+			return nil, nil, nil, nil, nil, fmt.Errorf("couldn't open sqlbrain db: %w", err)
 		}
 	}
 
@@ -372,7 +698,7 @@ func loadDBs(ctx context.Context, cfg DBCfg) (kv *badger.DB, sql, priv, spoke *s
 		slog.DebugContext(ctx, "privacy db", slog.String("path", cfg.Privacy))
 		priv, err = sqlitex.NewPool(cfg.Privacy, sqlitex.PoolOptions{})
 		if err != nil {
-			return nil, nil, nil, nil, fmt.Errorf("couldn't open privacy db: %w", err)
+			return nil, nil, nil, nil, nil, fmt.Errorf("couldn't open privacy db: %w", err)
 		}
 	}
 
@@ -387,11 +713,43 @@ func loadDBs(ctx context.Context, cfg DBCfg) (kv *badger.DB, sql, priv, spoke *s
 		slog.DebugContext(ctx, "spoken history db", slog.String("path", cfg.Spoken))
 		spoke, err = sqlitex.NewPool(cfg.Spoken, sqlitex.PoolOptions{})
 		if err != nil {
-			return nil, nil, nil, nil, fmt.Errorf("couldn't open spoken history db: %w", err)
+			return nil, nil, nil, nil, nil, fmt.Errorf("couldn't open spoken history db: %w", err)
+		}
+	}
+
+	switch cfg.History {
+	case "":
+		slog.DebugContext(ctx, "no chat log db; channel histories are not persisted")
+	case cfg.SQLBrain:
+		slog.DebugContext(ctx, "chat log db shared with sqlbrain")
+		hist = sql
+	case cfg.Privacy:
+		slog.DebugContext(ctx, "chat log db shared with privacy db")
+		hist = priv
+	case cfg.Spoken:
+		slog.DebugContext(ctx, "chat log db shared with spoken history db")
+		hist = spoke
+	default:
+		slog.DebugContext(ctx, "chat log db", slog.String("path", cfg.History))
+		hist, err = sqlitex.NewPool(cfg.History, sqlitex.PoolOptions{})
+		if err != nil {
+			return nil, nil, nil, nil, nil, fmt.Errorf("couldn't open chat log db: %w", err)
 		}
 	}
 
-	return kv, sql, priv, spoke, nil
+	return kv, sql, priv, spoke, hist, nil
+}
+
+// learnTags returns the distinct learn tags of all channels in cfg.
+func learnTags(cfg *Config) []string {
+	var tags []string
+	for _, ch := range cfg.Twitch {
+		if ch.Learn != "" && !slices.Contains(tags, ch.Learn) {
+			tags = append(tags, ch.Learn)
+		}
+	}
+	slices.Sort(tags)
+	return tags
 }
 
 func mergemaps(ms ...map[string]int) map[string]int {
@@ -476,6 +834,8 @@ type Config struct {
 	DB DBCfg `toml:"db"`
 	// HTTP is the table of HTTP API settings.
 	HTTP APICfg `toml:"http"`
+	// Serve is the table of brain server settings.
+	Serve ServeCfg `toml:"serve"`
 	// Global is the table of global settings.
 	Global Global `toml:"global"`
 	// TMI is the configuration for connecting to Twitch chat.
@@ -493,15 +853,36 @@ type ChannelCfg struct {
 	Learn string `toml:"learn"`
 	// Send is the tag used for generating messages for these channels.
 	Send string `toml:"send"`
+	// Retain is the number of days to keep messages learned from these
+	// channels. Zero means forever.
+	Retain float64 `toml:"retain"`
 	// Block is a regular expression of messages to ignore.
 	Block string `toml:"block"`
 	// Responses is the probability of generating a random message when
 	// a non-command message is received.
 	Responses float64 `toml:"responses"`
-	// Rate is the rate limit for interactions.
+	// Adaptive is the configuration for computing the response probability
+	// from chat velocity. If its target is positive, it replaces Responses.
+	Adaptive AdaptiveCfg `toml:"adaptive"`
+	// Budget is the number of milliseconds allowed for generating a message.
+	// Zero means no limit.
+	Budget int64 `toml:"budget"`
+	// Pregen is the number of messages to generate in advance for random
+	// responses and unprompted speaking. Zero disables pre-generation.
+	Pregen int `toml:"pregen"`
+	// Rate is the rate limit for interactions, shared by all budgets.
 	Rate Rate `toml:"rate"`
+	// Budgets is the rate limit for each kind of action, keyed by budget
+	// name: "copypasta", "random", "command", or "system".
+	// Omitted budgets are limited only by Rate.
+	Budgets map[string]Rate `toml:"budgets"`
+	// Users is the per-user rate limit for commands other than privacy
+	// commands.
+	Users UsersCfg `toml:"users"`
 	// Copypasta is the configuration for copypasta.
 	Copypasta Copypasta `toml:"copypasta"`
+	// History is the configuration for the history of recent messages.
+	History HistoryCfg `toml:"history"`
 	// Meme is a regular expression of messages to allow to be copypasta even
 	// if matched by this channel's or the global Block.
 	Meme string `toml:"meme"`
@@ -511,6 +892,72 @@ type ChannelCfg struct {
 	Effects map[string]int `toml:"effects"`
 	// Privileges is the user access controls for the channel.
 	Privileges []Privilege `toml:"privileges"`
+	// Timezone is the name of the time zone for the schedule, e.g.
+	// "America/New_York". Empty means UTC.
+	Timezone string `toml:"timezone"`
+	// Schedule is the list of windows of time which change the channel's
+	// behavior.
+	Schedule []WindowCfg `toml:"schedule"`
+	// Offline allows learning while the stream is offline.
+	// It should be set only for channels whose broadcasters consent to it.
+	Offline bool `toml:"offline"`
+	// Idle is the configuration for speaking when chat is silent.
+	Idle IdleCfg `toml:"idle"`
+}
+
+// UsersCfg is the configuration for per-user rate limits.
+type UsersCfg struct {
+	// Every and Num are the rate limit parameters for each user.
+	// Zero Every disables per-user limits.
+	Every float64 `toml:"every"`
+	Num   int     `toml:"num"`
+	// Size is the maximum number of users to track. Zero means 1000.
+	Size int `toml:"size"`
+	// Strikes is the number of violations after which a user is ignored.
+	// Zero means users are never ignored.
+	Strikes int `toml:"strikes"`
+	// Timeout is the number of seconds for which to ignore users.
+	Timeout float64 `toml:"timeout"`
+}
+
+// IdleCfg is the configuration for speaking when chat is silent.
+type IdleCfg struct {
+	// After is the number of minutes of silence after which to speak.
+	// Zero disables idle speaking.
+	After float64 `toml:"after"`
+	// Max is the longest number of minutes to wait between idle messages as
+	// the wait doubles while chat stays silent. Zero means no limit.
+	Max float64 `toml:"max"`
+}
+
+// AdaptiveCfg is the configuration for adaptive response probability.
+type AdaptiveCfg struct {
+	// Target is the number of random responses per minute to aim for.
+	// Zero disables adaptive responses.
+	Target float64 `toml:"target"`
+	// Min and Max clamp the response probability. Zero Max means 1.
+	Min float64 `toml:"min"`
+	Max float64 `toml:"max"`
+	// Window is the number of seconds over which to average chat velocity.
+	// Zero means one minute.
+	Window float64 `toml:"window"`
+}
+
+// WindowCfg is the configuration for a window in a channel's schedule.
+type WindowCfg struct {
+	// Days is the list of days of the week on which the window starts,
+	// e.g. "sat". Empty means every day.
+	Days []string `toml:"days"`
+	// Start and End are the times of day, as "15:04", at which the window
+	// starts and ends. If End is not after Start, the window ends the
+	// following day.
+	Start string `toml:"start"`
+	End   string `toml:"end"`
+	// Responses is the probability of random responses during the window.
+	// If omitted, the channel's usual probability applies.
+	Responses *float64 `toml:"responses"`
+	// Quiet disables speaking during the window.
+	Quiet bool `toml:"quiet"`
 }
 
 // Global is the configuration for globally applied options.
@@ -526,6 +973,9 @@ type Global struct {
 	Effects map[string]int `toml:"effects"`
 	// Privileges is the user access controls across entire services.
 	Privileges GlobalPrivs `toml:"privileges"`
+	// Copypasta is the maximum number of copypastas per hour across all
+	// channels. Zero means no limit beyond each channel's rate limit.
+	Copypasta int `toml:"copypasta"`
 }
 
 // GlobalPrivs is the configuration for privileges across entire services.
@@ -583,14 +1033,46 @@ type DBCfg struct {
 	SQLBrain string `toml:"sqlbrain"`
 	KVBrain  string `toml:"kvbrain"`
 	KVFlag   string `toml:"kvflag"`
-	Privacy  string `toml:"privacy"`
-	Spoken   string `toml:"spoken"`
+	MemBrain string `toml:"membrain"`
+	// Snapshot is the interval in minutes between membrain snapshots while
+	// running. If it is not positive, snapshots are saved every ten minutes.
+	Snapshot float64 `toml:"snapshot"`
+	// Remote is the base URL of a brain server to use as the brain.
+	Remote string `toml:"remote"`
+	// RemoteToken is the path to a file containing the access token for the
+	// brain server.
+	RemoteToken string `toml:"remotetoken"`
+	Privacy     string `toml:"privacy"`
+	Spoken      string `toml:"spoken"`
+	// History is the database in which channel histories are persisted.
+	// If it is empty, histories are kept only in memory.
+	History string `toml:"history"`
 }
 
 // APICfg is the configuration of the HTTP API.
 type APICfg struct {
 	// Listen is the address and port on which to listen.
 	Listen string `toml:"listen"`
+	// AdminFile is the path to a file containing the bearer token for admin
+	// endpoints. If it is empty, admin endpoints are disabled.
+	AdminFile string `toml:"admin"`
+}
+
+// ServeCfg is the configuration of the brain server.
+type ServeCfg struct {
+	// Listen is the address and port on which to listen.
+	Listen string `toml:"listen"`
+	// Tokens is the set of access tokens. Each key names a token for logs.
+	Tokens map[string]*TokenCfg `toml:"tokens"`
+}
+
+// TokenCfg is the configuration of a brain server access token.
+type TokenCfg struct {
+	// SecretFile is the path to a file containing the token.
+	SecretFile string `toml:"secret"`
+	// Tags is the list of tags the token can access. The tag "*" allows
+	// access to all tags.
+	Tags []string `toml:"tags"`
 }
 
 // Rate is a rate limit configuration.
@@ -603,6 +1085,30 @@ type Rate struct {
 type Copypasta struct {
 	Need   int     `toml:"need"`
 	Within float64 `toml:"within"`
+	// Normalize is the list of normalizations to apply when comparing
+	// messages: "case", "invisible", "space", or "emote".
+	Normalize []string `toml:"normalize"`
+	// Similarity is the minimum similarity between normalized messages for
+	// them to count as the same meme. Zero requires exact matches.
+	Similarity float64 `toml:"similarity"`
+	// MinLength is the minimum number of characters in a meme.
+	MinLength int `toml:"min-length"`
+	// MinTokens is the minimum number of words in a meme.
+	MinTokens int `toml:"min-tokens"`
+	// Ignore is a regular expression of messages which are never memes.
+	Ignore string `toml:"ignore"`
+	// Fresh is the number of seconds to wait before copying the same meme
+	// again. Zero means fifteen minutes.
+	Fresh float64 `toml:"fresh"`
+}
+
+// HistoryCfg is the configuration for a channel's history of recent messages.
+type HistoryCfg struct {
+	// Window is the number of seconds to keep messages.
+	// Zero means fifteen minutes.
+	Window float64 `toml:"window"`
+	// Size is the maximum number of messages to keep. Zero means no limit.
+	Size int `toml:"size"`
 }
 
 func expandcfg(cfg *Config, expand func(s string) string) {
@@ -613,9 +1119,15 @@ func expandcfg(cfg *Config, expand func(s string) string) {
 		&cfg.DB.SQLBrain,
 		&cfg.DB.KVBrain,
 		&cfg.DB.KVFlag,
+		&cfg.DB.MemBrain,
+		&cfg.DB.Remote,
+		&cfg.DB.RemoteToken,
 		&cfg.DB.Privacy,
 		&cfg.DB.Spoken,
+		&cfg.DB.History,
 		&cfg.HTTP.Listen,
+		&cfg.HTTP.AdminFile,
+		&cfg.Serve.Listen,
 		&cfg.TMI.CID,
 		&cfg.TMI.SecretFile,
 		&cfg.TMI.TokenFile,
@@ -625,6 +1137,12 @@ func expandcfg(cfg *Config, expand func(s string) string) {
 	for _, f := range fields {
 		*f = os.Expand(*f, expand)
 	}
+	for _, v := range cfg.Serve.Tokens {
+		v.SecretFile = os.Expand(v.SecretFile, expand)
+		for i, s := range v.Tags {
+			v.Tags[i] = os.Expand(s, expand)
+		}
+	}
 	for _, v := range cfg.Twitch {
 		for i, s := range v.Channels {
 			v.Channels[i] = os.Expand(s, expand)
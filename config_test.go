@@ -29,8 +29,15 @@ func TestExampleConfig(t *testing.T) {
 	eqcase(t, "Owner.Contact", cfg.Owner.Contact, `/w zephyrtronium`)
 	eqcase(t, "DB.KVBrain", cfg.DB.KVBrain, "")
 	eqcase(t, "DB.KVFlag", cfg.DB.KVFlag, "")
+	eqcase(t, "DB.MemBrain", cfg.DB.MemBrain, "")
+	eqcase(t, "DB.Snapshot", cfg.DB.Snapshot, 0.0)
+	eqcase(t, "DB.Remote", cfg.DB.Remote, "")
 	eqcase(t, "HTTP.Listen", cfg.HTTP.Listen, ":4959")
+	eqcase(t, "Serve.Listen", cfg.Serve.Listen, ":4960")
+	eqcase(t, "len(Serve.Tokens[`robot`].Tags)", len(cfg.Serve.Tokens["robot"].Tags), 2)
+	eqcase(t, "Serve.Tokens[`robot`].Tags[1]", cfg.Serve.Tokens["robot"].Tags[1], "kessoku")
 	eqcase(t, "Global.Block", cfg.Global.Block, `(?i)bad\s+stuff[^$x]`)
+	eqcase(t, "Global.Copypasta", cfg.Global.Copypasta, 20)
 	eqcase(t, "Global.Emotes[``]", cfg.Global.Emotes[``], 4)
 	eqcase(t, "Global.Emotes[`;)`]", cfg.Global.Emotes[`;)`], 1)
 	eqcase(t, "Global.Effects[``]", cfg.Global.Effects[``], 18)
@@ -49,12 +56,44 @@ func TestExampleConfig(t *testing.T) {
 	eqcase(t, "Twitch[`bocchi`].Channels[0]", cfg.Twitch[`bocchi`].Channels[0], `#bocchi`)
 	eqcase(t, "Twitch[`bocchi`].Learn", cfg.Twitch[`bocchi`].Learn, `bocchi`)
 	eqcase(t, "Twitch[`bocchi`].Send", cfg.Twitch[`bocchi`].Send, `bocchi`)
+	eqcase(t, "Twitch[`bocchi`].Retain", cfg.Twitch[`bocchi`].Retain, 365)
 	eqcase(t, "Twitch[`bocchi`].Block", cfg.Twitch[`bocchi`].Block, `(?i)cucumber[^$x]`)
 	eqcase(t, "Twitch[`bocchi`].Responses", cfg.Twitch[`bocchi`].Responses, 0.02)
+	eqcase(t, "Twitch[`bocchi`].Budget", cfg.Twitch[`bocchi`].Budget, 500)
+	eqcase(t, "Twitch[`bocchi`].Pregen", cfg.Twitch[`bocchi`].Pregen, 4)
 	eqcase(t, "Twitch[`bocchi`].Rate.Every", cfg.Twitch[`bocchi`].Rate.Every, 10.1)
 	eqcase(t, "Twitch[`bocchi`].Rate.Num", cfg.Twitch[`bocchi`].Rate.Num, 2)
 	eqcase(t, "Twitch[`bocchi`].Copypasta.Need", cfg.Twitch[`bocchi`].Copypasta.Need, 2)
 	eqcase(t, "Twitch[`bocchi`].Copypasta.Within", cfg.Twitch[`bocchi`].Copypasta.Within, 30)
+	eqcase(t, "Twitch[`bocchi`].Copypasta.Normalize[3]", cfg.Twitch[`bocchi`].Copypasta.Normalize[3], "emote")
+	eqcase(t, "Twitch[`bocchi`].Copypasta.Similarity", cfg.Twitch[`bocchi`].Copypasta.Similarity, 0.9)
+	eqcase(t, "Twitch[`bocchi`].Copypasta.MinLength", cfg.Twitch[`bocchi`].Copypasta.MinLength, 4)
+	eqcase(t, "Twitch[`bocchi`].Copypasta.MinTokens", cfg.Twitch[`bocchi`].Copypasta.MinTokens, 1)
+	eqcase(t, "Twitch[`bocchi`].Copypasta.Ignore", cfg.Twitch[`bocchi`].Copypasta.Ignore, "^[!$?]")
+	eqcase(t, "Twitch[`bocchi`].Copypasta.Fresh", cfg.Twitch[`bocchi`].Copypasta.Fresh, 900)
+	eqcase(t, "len(Twitch[`bocchi`].Budgets)", len(cfg.Twitch[`bocchi`].Budgets), 4)
+	eqcase(t, "Twitch[`bocchi`].Budgets[`copypasta`].Every", cfg.Twitch[`bocchi`].Budgets[`copypasta`].Every, 60)
+	eqcase(t, "Twitch[`bocchi`].Budgets[`random`].Num", cfg.Twitch[`bocchi`].Budgets[`random`].Num, 1)
+	eqcase(t, "Twitch[`bocchi`].Users.Every", cfg.Twitch[`bocchi`].Users.Every, 30)
+	eqcase(t, "Twitch[`bocchi`].Users.Num", cfg.Twitch[`bocchi`].Users.Num, 2)
+	eqcase(t, "Twitch[`bocchi`].Users.Strikes", cfg.Twitch[`bocchi`].Users.Strikes, 3)
+	eqcase(t, "Twitch[`bocchi`].Users.Timeout", cfg.Twitch[`bocchi`].Users.Timeout, 600)
+	eqcase(t, "Twitch[`bocchi`].Adaptive.Target", cfg.Twitch[`bocchi`].Adaptive.Target, 0.5)
+	eqcase(t, "Twitch[`bocchi`].Adaptive.Min", cfg.Twitch[`bocchi`].Adaptive.Min, 0.005)
+	eqcase(t, "Twitch[`bocchi`].Adaptive.Max", cfg.Twitch[`bocchi`].Adaptive.Max, 0.2)
+	eqcase(t, "Twitch[`bocchi`].Adaptive.Window", cfg.Twitch[`bocchi`].Adaptive.Window, 120)
+	eqcase(t, "Twitch[`bocchi`].Timezone", cfg.Twitch[`bocchi`].Timezone, "America/New_York")
+	eqcase(t, "Twitch[`bocchi`].Offline", cfg.Twitch[`bocchi`].Offline, false)
+	eqcase(t, "Twitch[`bocchi`].Idle.After", cfg.Twitch[`bocchi`].Idle.After, 10)
+	eqcase(t, "Twitch[`bocchi`].Idle.Max", cfg.Twitch[`bocchi`].Idle.Max, 120)
+	eqcase(t, "len(Twitch[`bocchi`].Schedule)", len(cfg.Twitch[`bocchi`].Schedule), 2)
+	eqcase(t, "Twitch[`bocchi`].Schedule[0].Days[0]", cfg.Twitch[`bocchi`].Schedule[0].Days[0], "sat")
+	eqcase(t, "Twitch[`bocchi`].Schedule[0].Start", cfg.Twitch[`bocchi`].Schedule[0].Start, "20:00")
+	eqcase(t, "Twitch[`bocchi`].Schedule[0].Quiet", cfg.Twitch[`bocchi`].Schedule[0].Quiet, true)
+	eqcase(t, "Twitch[`bocchi`].Schedule[1].End", cfg.Twitch[`bocchi`].Schedule[1].End, "02:00")
+	eqcase(t, "*Twitch[`bocchi`].Schedule[1].Responses", *cfg.Twitch[`bocchi`].Schedule[1].Responses, 0.005)
+	eqcase(t, "Twitch[`bocchi`].History.Window", cfg.Twitch[`bocchi`].History.Window, 900)
+	eqcase(t, "Twitch[`bocchi`].History.Size", cfg.Twitch[`bocchi`].History.Size, 1000)
 	eqcase(t, "Twitch[`bocchi`].Meme", cfg.Twitch[`bocchi`].Meme, `^\S*$`)
 	eqcase(t, "Twitch[`bocchi`].Privileges[0].Name", cfg.Twitch[`bocchi`].Privileges[0].Name, `zephyrtronium`)
 	eqcase(t, "Twitch[`bocchi`].Privileges[0].Level", cfg.Twitch[`bocchi`].Privileges[0].Level, `moderator`)
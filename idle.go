@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zephyrtronium/robot/channel"
+)
+
+// idleState is the idle speaking state of one channel.
+type idleState struct {
+	// last is the time of the most recent chat message seen.
+	last time.Time
+	// next is the time at which to speak if chat stays silent.
+	next time.Time
+	// wait is the current wait between idle messages.
+	wait time.Duration
+}
+
+// due updates the state given the time of the most recent chat message and
+// reports whether the channel should speak at now.
+// A zero last means no messages have been seen, so silence counts from the
+// first call.
+func (s *idleState) due(now, last time.Time, idle, most time.Duration) bool {
+	if s.wait == 0 || !last.Equal(s.last) {
+		// Chat has spoken, or we've just started watching. Start over.
+		base := last
+		if base.IsZero() {
+			base = now
+		}
+		s.last, s.wait, s.next = last, idle, base.Add(idle)
+	}
+	if now.Before(s.next) {
+		return false
+	}
+	// Back off while chat stays silent.
+	s.wait *= 2
+	if most > 0 && s.wait > most {
+		s.wait = most
+	}
+	s.next = now.Add(s.wait)
+	return true
+}
+
+// idleLoop speaks in online channels whose chats have gone silent.
+func (robo *Robot) idleLoop(ctx context.Context, group *errgroup.Group) error {
+	states := make(map[*channel.Channel]*idleState)
+	tick := time.NewTicker(10 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C: // continue below
+		}
+		now := time.Now()
+		cur := make(map[*channel.Channel]bool, len(states))
+		for _, ch := range robo.channels.All() {
+			set := ch.Settings.Load()
+			if set.Idle <= 0 || ch.Send == "" || !ch.Enabled.Load() {
+				continue
+			}
+			cur[ch] = true
+			if set.QuietAt(now) {
+				continue
+			}
+			st := states[ch]
+			if st == nil {
+				st = new(idleState)
+				states[ch] = st
+			}
+			if !st.due(now, ch.Velocity.Last(), set.Idle, set.IdleMax) {
+				continue
+			}
+			log := slog.With(slog.String("in", ch.Name), slog.String("action", "idle"))
+			log.InfoContext(ctx, "chat is idle", slog.Time("last", st.last), slog.Duration("next", st.wait))
+			work := func(ctx context.Context) {
+				robo.speak(ctx, log, ch, set, robo.tmi.send, newRand())
+			}
+			robo.enqueue(ctx, group, work)
+		}
+		// Forget channels which were removed, went offline, or stopped idle
+		// speaking, so that they start fresh if they come back.
+		for ch := range states {
+			if !cur[ch] {
+				delete(states, ch)
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleDue(t *testing.T) {
+	start := time.Unix(0, 0)
+	at := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	steps := []struct {
+		now  int
+		last int // -1 for no messages
+		want bool
+	}{
+		{0, -1, false},
+		{9, -1, false},
+		{10, -1, true},
+		// Backoff to 20 minutes.
+		{29, -1, false},
+		{30, -1, true},
+		// Capped at 30 minutes.
+		{59, -1, false},
+		{60, -1, true},
+		{90, -1, true},
+		// Chat speaks; start over from the message.
+		{95, 92, false},
+		{102, 92, true},
+		{121, 92, false},
+		{122, 92, true},
+	}
+	var s idleState
+	for i, c := range steps {
+		var last time.Time
+		if c.last >= 0 {
+			last = at(c.last)
+		}
+		if got := s.due(at(c.now), last, 10*time.Minute, 30*time.Minute); got != c.want {
+			t.Errorf("wrong result at step %d (minute %d): want %t, got %t", i, c.now, c.want, got)
+		}
+	}
+}
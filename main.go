@@ -1,10 +1,14 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,13 +16,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/urfave/cli/v3"
 	"golang.org/x/sync/errgroup"
 	"zombiezen.com/go/sqlite/sqlitex"
 
 	"github.com/zephyrtronium/robot/brain"
-	"github.com/zephyrtronium/robot/brain/kvbrain"
+	"github.com/zephyrtronium/robot/brain/httpbrain"
 	"github.com/zephyrtronium/robot/brain/sqlbrain"
+	"github.com/zephyrtronium/robot/chatlog"
+	"github.com/zephyrtronium/robot/migrate"
+	"github.com/zephyrtronium/robot/privacy"
+	"github.com/zephyrtronium/robot/spoken"
 	"github.com/zephyrtronium/robot/userhash"
 )
 
@@ -53,11 +62,133 @@ var app = cli.Command{
 				},
 				&cli.BoolFlag{
 					Name:  "trace",
-					Usage: "Print ID traces with messages",
+					Usage: "Print ID traces and seeds with messages",
+				},
+				&cli.UintFlag{
+					Name:  "seed",
+					Usage: "Seed for the first message, incremented for each subsequent one (default random)",
 				},
 			},
 			Action: cliSpeak,
 		},
+		{
+			Name:  "schema",
+			Usage: "Show pending database schema migrations",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "apply",
+					Usage: "Apply pending migrations instead of only showing them",
+				},
+			},
+			Action: cliSchema,
+		},
+		{
+			Name:  "export",
+			Usage: "Write learned messages to a JSON Lines file",
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:     "tag",
+					Usage:    "Tag to export; may be given multiple times",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:  "out",
+					Usage: "File to write instead of standard output",
+				},
+				&cli.BoolFlag{
+					Name:  "deleted",
+					Usage: "Include deleted messages",
+				},
+			},
+			Action: cliExport,
+		},
+		{
+			Name:  "import",
+			Usage: "Learn messages from a file written by export",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "in",
+					Usage: "File to read instead of standard input",
+				},
+			},
+			Action: cliImport,
+		},
+		{
+			Name:  "migrate",
+			Usage: "Copy learned messages from one brain backend to another",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:     "from",
+					Usage:    "Source brain, as sqlbrain:<connection string> or kvbrain:<directory>",
+					Required: true,
+				},
+				&cli.StringFlag{
+					Name:     "to",
+					Usage:    "Destination brain, as sqlbrain:<connection string> or kvbrain:<directory>",
+					Required: true,
+				},
+				&cli.StringSliceFlag{
+					Name:  "tag",
+					Usage: "Tag to migrate; may be given multiple times (default: learn tags in config)",
+				},
+				&cli.StringFlag{
+					Name:  "checkpoint",
+					Usage: "File recording progress so that an interrupted migration can resume",
+				},
+			},
+			Action: cliMigrate,
+		},
+		{
+			Name:  "stats",
+			Usage: "Show how much the brain knows",
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "tag",
+					Usage: "Tag to summarize; may be given multiple times (default: learn tags in config)",
+				},
+			},
+			Action: cliStats,
+		},
+		{
+			Name:  "brain-serve",
+			Usage: "Serve the brain over HTTP for other processes to share",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "listen",
+					Usage: "Address on which to listen (default: serve.listen in config)",
+				},
+			},
+			Action: cliBrainServe,
+		},
+		{
+			Name:  "tags",
+			Usage: "Administer the tags in the brain",
+			Commands: []*cli.Command{
+				{
+					Name:   "list",
+					Usage:  "List known tags",
+					Action: cliTags(tagsList),
+				},
+				{
+					Name:      "copy",
+					Usage:     "Copy everything known in one tag into another",
+					ArgsUsage: "<from> <to>",
+					Action:    cliTags(tagsCopy),
+				},
+				{
+					Name:      "rename",
+					Usage:     "Move everything known in one tag into another",
+					ArgsUsage: "<from> <to>",
+					Action:    cliTags(tagsRename),
+				},
+				{
+					Name:      "drop",
+					Usage:     "Permanently delete everything known in a tag",
+					ArgsUsage: "<tag>",
+					Action:    cliTags(tagsDrop),
+				},
+			},
+		},
 		{
 			Name:  "ancient",
 			Usage: "Import messages from a v0.1.0 Robot database",
@@ -93,15 +224,10 @@ func main() {
 
 func cliRun(ctx context.Context, cmd *cli.Command) error {
 	slog.SetDefault(loggerFromFlags(cmd))
-	r, err := os.Open(cmd.String("config"))
+	cfg, md, err := loadFile(ctx, cmd.String("config"))
 	if err != nil {
-		return fmt.Errorf("couldn't open config file: %w", err)
-	}
-	cfg, md, err := Load(ctx, r)
-	if err != nil {
-		return fmt.Errorf("couldn't load config: %w", err)
+		return err
 	}
-	r.Close()
 
 	secrets, err := loadSecrets(cfg.SecretFile)
 	if err != nil {
@@ -109,13 +235,24 @@ func cliRun(ctx context.Context, cmd *cli.Command) error {
 	}
 	robo := New(secrets.userhash, runtime.GOMAXPROCS(0))
 	robo.SetOwner(cfg.Owner.Name, cfg.Owner.Contact)
-	kv, sql, priv, spoke, err := loadDBs(ctx, cfg.DB)
+	kv, sql, priv, spoke, hist, err := loadDBs(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	if kv != nil {
+		defer kv.Close()
+	}
+	if sql != nil {
+		defer sql.Close()
+	}
+	br, err := loadBrain(ctx, cfg.DB, kv, sql)
 	if err != nil {
 		return err
 	}
-	if err := robo.SetSources(ctx, kv, sql, priv, spoke); err != nil {
+	if err := robo.SetSources(ctx, br, priv, spoke, hist); err != nil {
 		return err
 	}
+	robo.SetSnapshot(cfg.DB)
 
 	if md.IsDefined("tmi") {
 		secret, err := loadClientSecret(cfg.TMI.SecretFile)
@@ -133,12 +270,43 @@ func cliRun(ctx context.Context, cmd *cli.Command) error {
 		}
 	}
 
+	reload := func(ctx context.Context) error {
+		cfg, _, err := loadFile(ctx, cmd.String("config"))
+		if err != nil {
+			return err
+		}
+		return robo.Reload(ctx, cfg)
+	}
+	go reloadOnSignal(ctx, reload)
+
 	if cfg.HTTP.Listen != "" {
 		// TODO(zeph): this should be in the errgroup inside Run
-		go api(ctx, cfg.HTTP.Listen, new(http.ServeMux))
+		var extra []prometheus.Collector
+		if st, ok := br.(brain.Statter); ok {
+			extra = append(extra, newBrainCollector(st, robo.learnTags))
+		}
+		mux := new(http.ServeMux)
+		if cfg.HTTP.AdminFile != "" {
+			tok, err := os.ReadFile(cfg.HTTP.AdminFile)
+			if err != nil {
+				return fmt.Errorf("couldn't read admin token: %w", err)
+			}
+			tok = bytes.TrimSpace(tok)
+			if len(tok) == 0 {
+				return errors.New("admin token is empty")
+			}
+			mux.Handle("POST /admin/reload", reloadHandler(tok, reload))
+		}
+		go api(ctx, cfg.HTTP.Listen, mux, extra...)
 	}
 
-	return robo.Run(ctx)
+	err = robo.Run(ctx)
+	// The context is usually canceled by now, but the snapshot still needs
+	// to be written.
+	if err := saveBrain(context.WithoutCancel(ctx), cfg.DB, br); err != nil {
+		slog.ErrorContext(ctx, "couldn't save brain", slog.Any("err", err))
+	}
+	return err
 }
 
 func cliSpeak(ctx context.Context, cmd *cli.Command) error {
@@ -152,38 +320,38 @@ func cliSpeak(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("couldn't load config: %w", err)
 	}
 	r.Close()
-	kv, sql, _, _, err := loadDBs(ctx, cfg.DB)
+	kv, sql, _, _, _, err := loadDBs(ctx, cfg.DB)
 	if err != nil {
 		return err
 	}
-	var br brain.Brain
-	if sql == nil {
-		if kv == nil {
-			panic("robot: no brain")
-		}
-		br = kvbrain.New(kv)
+	if kv != nil {
 		defer kv.Close()
-	} else {
-		br, err = sqlbrain.Open(ctx, sql)
+	}
+	if sql != nil {
 		defer sql.Close()
 	}
+	br, err := loadBrain(ctx, cfg.DB, kv, sql)
 	if err != nil {
-		return fmt.Errorf("couldn't open brain: %w", err)
+		return err
 	}
 	group, ctx := errgroup.WithContext(ctx)
 	group.SetLimit(runtime.GOMAXPROCS(0))
 	tag := cmd.String("tag")
 	trace := cmd.Bool("trace")
 	prompt := cmd.String("prompt")
-	for range cmd.Int("n") {
+	seed := cmd.Uint("seed")
+	if !cmd.IsSet("seed") {
+		seed = rand.Uint64()
+	}
+	for i := range uint64(cmd.Int("n")) {
 		group.Go(func() error {
-			m, tr, err := brain.Speak(ctx, br, tag, prompt)
+			m, tr, err := brain.Speak(brain.WithSeed(ctx, seed+i), br, tag, prompt)
 			if err != nil {
 				return err
 			}
 			a := []any{m}
 			if trace {
-				a = append(a, tr)
+				a = append(a, tr, seed+i)
 			}
 			fmt.Println(a...)
 			return nil
@@ -192,7 +360,70 @@ func cliSpeak(ctx context.Context, cmd *cli.Command) error {
 	return group.Wait()
 }
 
-func cliAncient(ctx context.Context, cmd *cli.Command) error {
+func cliSchema(ctx context.Context, cmd *cli.Command) error {
+	slog.SetDefault(loggerFromFlags(cmd))
+	r, err := os.Open(cmd.String("config"))
+	if err != nil {
+		return fmt.Errorf("couldn't open config file: %w", err)
+	}
+	cfg, _, err := Load(ctx, r)
+	if err != nil {
+		return fmt.Errorf("couldn't load config: %w", err)
+	}
+	r.Close()
+	kv, sql, priv, spoke, hist, err := loadDBs(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	if kv != nil {
+		defer kv.Close()
+	}
+	stores := []struct {
+		db     *sqlitex.Pool
+		schema *migrate.Schema
+	}{
+		{sql, &sqlbrain.Schema},
+		{priv, &privacy.Schema},
+		{spoke, &spoken.Schema},
+		{hist, &chatlog.Schema},
+	}
+	apply := cmd.Bool("apply")
+	for _, s := range stores {
+		if s.db == nil {
+			continue
+		}
+		conn, err := s.db.Take(ctx)
+		if err != nil {
+			return fmt.Errorf("couldn't get connection for %s: %w", s.schema.Name, err)
+		}
+		v, err := migrate.Version(conn, s.schema)
+		if err != nil {
+			s.db.Put(conn)
+			return fmt.Errorf("couldn't get %s schema version: %w", s.schema.Name, err)
+		}
+		p, err := migrate.Pending(conn, s.schema)
+		if err != nil {
+			s.db.Put(conn)
+			return err
+		}
+		fmt.Printf("%s: version %d of %d\n", s.schema.Name, v, len(s.schema.Migrations))
+		for i, m := range p {
+			fmt.Printf("\tpending %d: %s\n", v+i+1, m.Name)
+		}
+		if apply && len(p) > 0 {
+			n, err := migrate.Apply(conn, s.schema)
+			if err != nil {
+				s.db.Put(conn)
+				return fmt.Errorf("couldn't migrate %s: %w", s.schema.Name, err)
+			}
+			fmt.Printf("\tapplied %d migrations\n", n)
+		}
+		s.db.Put(conn)
+	}
+	return nil
+}
+
+func cliExport(ctx context.Context, cmd *cli.Command) error {
 	slog.SetDefault(loggerFromFlags(cmd))
 	r, err := os.Open(cmd.String("config"))
 	if err != nil {
@@ -203,26 +434,394 @@ func cliAncient(ctx context.Context, cmd *cli.Command) error {
 		return fmt.Errorf("couldn't load config: %w", err)
 	}
 	r.Close()
-	kv, sql, _, _, err := loadDBs(ctx, cfg.DB)
+	kv, sql, _, _, _, err := loadDBs(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	if kv != nil {
+		defer kv.Close()
+	}
+	if sql != nil {
+		defer sql.Close()
+	}
+	br, err := loadBrain(ctx, cfg.DB, kv, sql)
 	if err != nil {
 		return err
 	}
-	var br brain.Brain
-	if sql == nil {
-		if kv == nil {
-			panic("robot: no brain")
+	w := os.Stdout
+	if out := cmd.String("out"); out != "" {
+		w, err = os.Create(out)
+		if err != nil {
+			return fmt.Errorf("couldn't create export file: %w", err)
 		}
-		br = kvbrain.New(kv)
+		defer w.Close()
+	}
+	ex, ok := br.(brain.Exporter)
+	if !ok {
+		return errors.New("brain can't export messages")
+	}
+	bw := bufio.NewWriter(w)
+	n, err := brain.Export(ctx, bw, ex, cmd.StringSlice("tag"), cmd.Bool("deleted"))
+	if err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("couldn't write export: %w", err)
+	}
+	slog.InfoContext(ctx, "exported", slog.Int64("n", n))
+	return nil
+}
+
+func cliImport(ctx context.Context, cmd *cli.Command) error {
+	slog.SetDefault(loggerFromFlags(cmd))
+	r, err := os.Open(cmd.String("config"))
+	if err != nil {
+		return fmt.Errorf("couldn't open config file: %w", err)
+	}
+	cfg, _, err := Load(ctx, r)
+	if err != nil {
+		return fmt.Errorf("couldn't load config: %w", err)
+	}
+	r.Close()
+	kv, sql, _, _, _, err := loadDBs(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	if kv != nil {
 		defer kv.Close()
-	} else {
+	}
+	if sql != nil {
+		defer sql.Close()
+	}
+	br, err := loadBrain(ctx, cfg.DB, kv, sql)
+	if err != nil {
+		return err
+	}
+	in := os.Stdin
+	if file := cmd.String("in"); file != "" {
+		in, err = os.Open(file)
+		if err != nil {
+			return fmt.Errorf("couldn't open import file: %w", err)
+		}
+		defer in.Close()
+	}
+	n, err := brain.Import(ctx, br, bufio.NewReader(in))
+	slog.InfoContext(ctx, "imported", slog.Int64("n", n))
+	return errors.Join(err, saveBrain(context.WithoutCancel(ctx), cfg.DB, br))
+}
+
+func cliMigrate(ctx context.Context, cmd *cli.Command) error {
+	slog.SetDefault(loggerFromFlags(cmd))
+	tags := cmd.StringSlice("tag")
+	if len(tags) == 0 {
+		r, err := os.Open(cmd.String("config"))
+		if err != nil {
+			return fmt.Errorf("couldn't open config file: %w", err)
+		}
+		cfg, _, err := Load(ctx, r)
+		if err != nil {
+			return fmt.Errorf("couldn't load config: %w", err)
+		}
+		r.Close()
+		tags = learnTags(cfg)
+		if len(tags) == 0 {
+			return errors.New("no tags to migrate")
+		}
+	}
+	src, closeSrc, err := openBrain(ctx, cmd.String("from"), true)
+	if err != nil {
+		return err
+	}
+	defer closeSrc()
+	ex, ok := src.(brain.Exporter)
+	if !ok {
+		return errors.New("source brain can't export messages")
+	}
+	dst, closeDst, err := openBrain(ctx, cmd.String("to"), false)
+	if err != nil {
+		return err
+	}
+	defer closeDst()
+	p, err := loadProgress(cmd.String("checkpoint"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			slog.ErrorContext(ctx, "couldn't save checkpoint", slog.Any("err", err))
+		}
+	}()
+	for _, tag := range tags {
+		n, err := migrateTag(ctx, dst, ex, tag, p)
+		if err != nil {
+			return err
+		}
+		slog.InfoContext(ctx, "migrated tag", slog.String("tag", tag), slog.Int64("n", n))
+	}
+	// Verify by comparing tuple counts.
+	imp, ok := dst.(brain.Exporter)
+	if !ok {
+		slog.WarnContext(ctx, "destination brain can't export messages; not verifying")
+		return nil
+	}
+	var bad []string
+	for _, tag := range tags {
+		want, err := countTuples(ctx, ex, tag)
+		if err != nil {
+			return err
+		}
+		got, err := countTuples(ctx, imp, tag)
+		if err != nil {
+			return err
+		}
+		if want != got {
+			slog.ErrorContext(ctx, "tuple counts differ", slog.String("tag", tag), slog.Int64("from", want), slog.Int64("to", got))
+			bad = append(bad, tag)
+			continue
+		}
+		slog.InfoContext(ctx, "verified", slog.String("tag", tag), slog.Int64("tuples", got))
+	}
+	if len(bad) != 0 {
+		return fmt.Errorf("tuple counts differ in tags %s", strings.Join(bad, ", "))
+	}
+	return nil
+}
+
+func cliStats(ctx context.Context, cmd *cli.Command) error {
+	slog.SetDefault(loggerFromFlags(cmd))
+	r, err := os.Open(cmd.String("config"))
+	if err != nil {
+		return fmt.Errorf("couldn't open config file: %w", err)
+	}
+	cfg, _, err := Load(ctx, r)
+	if err != nil {
+		return fmt.Errorf("couldn't load config: %w", err)
+	}
+	r.Close()
+	tags := cmd.StringSlice("tag")
+	if len(tags) == 0 {
+		tags = learnTags(cfg)
+	}
+	kv, sql, _, _, _, err := loadDBs(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	if kv != nil {
+		defer kv.Close()
+	}
+	if sql != nil {
+		defer sql.Close()
+	}
+	br, err := loadBrain(ctx, cfg.DB, kv, sql)
+	if err != nil {
+		return err
+	}
+	st, ok := br.(brain.Statter)
+	if !ok {
+		return errors.New("brain can't compute statistics")
+	}
+	for _, tag := range tags {
+		s, err := st.Stats(ctx, tag)
+		if err != nil {
+			return fmt.Errorf("couldn't get stats for %s: %w", tag, err)
+		}
+		fmt.Printf("%s:\n", tag)
+		fmt.Printf("\tmessages: %d (%d deleted)\n", s.Messages, s.Deleted)
+		fmt.Printf("\ttuples: %d\n", s.Tuples)
+		fmt.Printf("\tprefixes: %d\n", s.Prefixes)
+		fmt.Printf("\tstarts: %d\n", s.Starts)
+		if !s.Oldest.IsZero() {
+			fmt.Printf("\toldest: %s\n", s.Oldest.Format(time.RFC3339))
+			fmt.Printf("\tnewest: %s\n", s.Newest.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func cliBrainServe(ctx context.Context, cmd *cli.Command) error {
+	slog.SetDefault(loggerFromFlags(cmd))
+	r, err := os.Open(cmd.String("config"))
+	if err != nil {
+		return fmt.Errorf("couldn't open config file: %w", err)
+	}
+	cfg, _, err := Load(ctx, r)
+	if err != nil {
+		return fmt.Errorf("couldn't load config: %w", err)
+	}
+	r.Close()
+	if cfg.DB.Remote != "" {
+		return errors.New("brain-serve needs a local brain")
+	}
+	listen := cmd.String("listen")
+	if listen == "" {
+		listen = cfg.Serve.Listen
+	}
+	if listen == "" {
+		return errors.New("no address to listen on")
+	}
+	kv, sql, _, _, _, err := loadDBs(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	if kv != nil {
+		defer kv.Close()
+	}
+	if sql != nil {
+		defer sql.Close()
+	}
+	br, err := loadBrain(ctx, cfg.DB, kv, sql)
+	if err != nil {
+		return err
+	}
+	s := httpbrain.NewServer(br)
+	for name, tok := range cfg.Serve.Tokens {
+		secret, err := os.ReadFile(tok.SecretFile)
+		if err != nil {
+			return fmt.Errorf("couldn't read token %s: %w", name, err)
+		}
+		secret = bytes.TrimSpace(secret)
+		if len(secret) == 0 {
+			return fmt.Errorf("token %s is empty", name)
+		}
+		s.Grant(name, string(secret), tok.Tags)
+		slog.InfoContext(ctx, "granted token", slog.String("name", name), slog.Any("tags", tok.Tags))
+	}
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("couldn't start brain server: %w", err)
+	}
+	srv := http.Server{
+		Handler:     s,
+		ReadTimeout: 5 * time.Second,
+		BaseContext: func(l net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	slog.InfoContext(ctx, "brain server", slog.Any("addr", l.Addr()))
+	err = srv.Serve(l)
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	return errors.Join(err, saveBrain(context.WithoutCancel(ctx), cfg.DB, br))
+}
+
+// cliTags creates an action which runs a tag operation against the brain and
+// then saves it.
+func cliTags(op func(ctx context.Context, tg brain.Tagger, args []string) error) cli.ActionFunc {
+	return func(ctx context.Context, cmd *cli.Command) error {
+		slog.SetDefault(loggerFromFlags(cmd))
+		r, err := os.Open(cmd.String("config"))
+		if err != nil {
+			return fmt.Errorf("couldn't open config file: %w", err)
+		}
+		cfg, _, err := Load(ctx, r)
+		if err != nil {
+			return fmt.Errorf("couldn't load config: %w", err)
+		}
+		r.Close()
+		kv, sql, _, _, _, err := loadDBs(ctx, cfg.DB)
+		if err != nil {
+			return err
+		}
+		if kv != nil {
+			defer kv.Close()
+		}
+		if sql != nil {
+			defer sql.Close()
+		}
+		br, err := loadBrain(ctx, cfg.DB, kv, sql)
+		if err != nil {
+			return err
+		}
+		tg, ok := br.(brain.Tagger)
+		if !ok {
+			return errors.New("brain can't administer tags")
+		}
+		if err := op(ctx, tg, cmd.Args().Slice()); err != nil {
+			return err
+		}
+		return saveBrain(context.WithoutCancel(ctx), cfg.DB, br)
+	}
+}
+
+func tagsList(ctx context.Context, tg brain.Tagger, args []string) error {
+	if len(args) != 0 {
+		return errors.New("tags list takes no arguments")
+	}
+	tags, err := tg.Tags(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		fmt.Println(tag)
+	}
+	return nil
+}
+
+func tagsCopy(ctx context.Context, tg brain.Tagger, args []string) error {
+	if len(args) != 2 {
+		return errors.New("tags copy takes a source and destination tag")
+	}
+	if err := tg.CopyTag(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "copied tag", slog.String("from", args[0]), slog.String("to", args[1]))
+	return nil
+}
+
+func tagsRename(ctx context.Context, tg brain.Tagger, args []string) error {
+	if len(args) != 2 {
+		return errors.New("tags rename takes a source and destination tag")
+	}
+	if err := tg.RenameTag(ctx, args[0], args[1]); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "renamed tag", slog.String("from", args[0]), slog.String("to", args[1]))
+	return nil
+}
+
+func tagsDrop(ctx context.Context, tg brain.Tagger, args []string) error {
+	if len(args) != 1 {
+		return errors.New("tags drop takes one tag")
+	}
+	if err := tg.DropTag(ctx, args[0]); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "dropped tag", slog.String("tag", args[0]))
+	return nil
+}
+
+func cliAncient(ctx context.Context, cmd *cli.Command) error {
+	slog.SetDefault(loggerFromFlags(cmd))
+	r, err := os.Open(cmd.String("config"))
+	if err != nil {
+		return fmt.Errorf("couldn't open config file: %w", err)
+	}
+	cfg, _, err := Load(ctx, r)
+	if err != nil {
+		return fmt.Errorf("couldn't load config: %w", err)
+	}
+	r.Close()
+	kv, sql, _, _, _, err := loadDBs(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+	if kv != nil {
+		defer kv.Close()
+	}
+	if sql != nil {
 		conn, _ := sql.Take(ctx)
 		sqlitex.ExecuteScript(conn, `PRAGMA synchronous=OFF; PRAGMA journal_mode=OFF`, nil)
 		sql.Put(conn)
-		br, err = sqlbrain.Open(ctx, sql)
 		defer sql.Close()
 	}
+	br, err := loadBrain(ctx, cfg.DB, kv, sql)
 	if err != nil {
-		return fmt.Errorf("couldn't open brain: %w", err)
+		return err
 	}
 	file := cmd.String("db")
 	conn, order, err := ancientOpen(file)
@@ -253,7 +852,7 @@ func cliAncient(ctx context.Context, cmd *cli.Command) error {
 		default: // do nothing
 		}
 	}
-	return nil
+	return saveBrain(ctx, cfg.DB, br)
 }
 
 var (
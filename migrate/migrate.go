@@ -0,0 +1,158 @@
+// Package migrate manages schema versions of SQLite databases.
+//
+// Each store keeps its schema version in a row of the database's
+// schema_versions table, keyed by the store's name, which allows any number of
+// stores to share a single database file while still being versioned
+// independently. The version of a store is the number of its migrations which
+// have been applied.
+package migrate
+
+import (
+	"errors"
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// Schema is the ordered list of migrations for one store.
+type Schema struct {
+	// Name identifies the store in the schema_versions table and in errors.
+	// Stores which can share a database must use distinct names.
+	Name string
+	// Migrations is the list of migrations in the order they are applied.
+	// Migrations must never be removed or reordered once released.
+	Migrations []Migration
+}
+
+// Migration is a single schema change.
+type Migration struct {
+	// Name is a short description of the migration.
+	Name string
+	// SQL is the script to run for the migration.
+	// It runs inside a transaction, so it must not contain transaction
+	// control statements or pragmas which cannot be used in transactions.
+	SQL string
+	// Func, if not nil, runs after SQL in the same transaction, for changes
+	// which are impractical to express in SQL alone.
+	Func func(conn *sqlite.Conn) error
+}
+
+// ErrTooNew is an error returned when a database has a schema version newer
+// than the program knows how to use.
+var ErrTooNew = errors.New("database schema is newer than this program")
+
+// Version returns the current schema version of the store in the database.
+func Version(conn *sqlite.Conn, s *Schema) (int, error) {
+	// Reading the version must not create the table, so that checking for
+	// pending migrations never changes the database.
+	var exists bool
+	check := sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			exists = stmt.ColumnBool(0)
+			return nil
+		},
+	}
+	if err := sqlitex.ExecuteTransient(conn, `SELECT EXISTS (SELECT 1 FROM sqlite_schema WHERE type='table' AND name='schema_versions')`, &check); err != nil {
+		return 0, fmt.Errorf("couldn't check for schema versions: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	var v int
+	opts := sqlitex.ExecOptions{
+		Named: map[string]any{":name": s.Name},
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			v = stmt.ColumnInt(0)
+			return nil
+		},
+	}
+	if err := sqlitex.ExecuteTransient(conn, `SELECT version FROM schema_versions WHERE name = :name`, &opts); err != nil {
+		return 0, fmt.Errorf("couldn't get schema version: %w", err)
+	}
+	return v, nil
+}
+
+// setVersion sets the schema version of the store in the database.
+func setVersion(conn *sqlite.Conn, s *Schema, v int) error {
+	const create = `CREATE TABLE IF NOT EXISTS schema_versions (
+		name TEXT PRIMARY KEY,
+		version INTEGER NOT NULL
+	) STRICT`
+	if err := sqlitex.ExecuteTransient(conn, create, nil); err != nil {
+		return fmt.Errorf("couldn't create schema versions: %w", err)
+	}
+	opts := sqlitex.ExecOptions{
+		Named: map[string]any{":name": s.Name, ":version": v},
+	}
+	const set = `INSERT INTO schema_versions(name, version) VALUES (:name, :version)
+		ON CONFLICT(name) DO UPDATE SET version = excluded.version`
+	if err := sqlitex.ExecuteTransient(conn, set, &opts); err != nil {
+		return fmt.Errorf("couldn't set schema version: %w", err)
+	}
+	return nil
+}
+
+// Pending returns the migrations of the store which have not yet been applied
+// to the database. If the database is newer than the schema, the error is
+// ErrTooNew.
+func Pending(conn *sqlite.Conn, s *Schema) ([]Migration, error) {
+	v, err := Version(conn, s)
+	if err != nil {
+		return nil, err
+	}
+	if v > len(s.Migrations) {
+		return nil, fmt.Errorf("%s has version %d but only %d are known: %w", s.Name, v, len(s.Migrations), ErrTooNew)
+	}
+	return s.Migrations[v:], nil
+}
+
+// Apply applies all pending migrations of the store to the database, each in
+// its own transaction. It returns the number of migrations applied.
+// If the database is newer than the schema, the error is ErrTooNew and
+// nothing is changed.
+func Apply(conn *sqlite.Conn, s *Schema) (int, error) {
+	n := 0
+	for {
+		ok, err := step(conn, s)
+		if err != nil {
+			return n, err
+		}
+		if !ok {
+			return n, nil
+		}
+		n++
+	}
+}
+
+// step applies the next pending migration, if there is one.
+func step(conn *sqlite.Conn, s *Schema) (ok bool, err error) {
+	// Use an immediate transaction so that the version can't change between
+	// reading it and applying the migration.
+	end, err := sqlitex.ImmediateTransaction(conn)
+	if err != nil {
+		return false, fmt.Errorf("couldn't start migration transaction: %w", err)
+	}
+	defer end(&err)
+	p, err := Pending(conn, s)
+	if err != nil {
+		return false, err
+	}
+	if len(p) == 0 {
+		return false, nil
+	}
+	m := p[0]
+	v := len(s.Migrations) - len(p)
+	if err := sqlitex.ExecuteScript(conn, m.SQL, nil); err != nil {
+		return false, fmt.Errorf("couldn't apply %s migration %d (%s): %w", s.Name, v+1, m.Name, err)
+	}
+	if m.Func != nil {
+		if err := m.Func(conn); err != nil {
+			return false, fmt.Errorf("couldn't apply %s migration %d (%s): %w", s.Name, v+1, m.Name, err)
+		}
+	}
+	if err := setVersion(conn, s, v+1); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,225 @@
+package migrate_test
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/migrate"
+)
+
+var dbCount atomic.Int64
+
+func testDB(t *testing.T) *sqlite.Conn {
+	t.Helper()
+	k := dbCount.Add(1)
+	conn, err := sqlite.OpenConn(fmt.Sprintf("file:migrate-%d.db?mode=memory", k), sqlite.OpenReadWrite|sqlite.OpenCreate|sqlite.OpenMemory|sqlite.OpenURI)
+	if err != nil {
+		t.Fatalf("couldn't open db: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func tables(t *testing.T, conn *sqlite.Conn) []string {
+	t.Helper()
+	var r []string
+	opts := sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			r = append(r, stmt.ColumnText(0))
+			return nil
+		},
+	}
+	if err := sqlitex.Execute(conn, `SELECT name FROM sqlite_schema WHERE type='table' AND name != 'schema_versions' ORDER BY name`, &opts); err != nil {
+		t.Fatalf("couldn't list tables: %v", err)
+	}
+	return r
+}
+
+var bocchi = migrate.Schema{
+	Name: "bocchi",
+	Migrations: []migrate.Migration{
+		{Name: "hitori", SQL: `CREATE TABLE hitori (x INTEGER)`},
+		{Name: "guitar", SQL: `CREATE TABLE guitar (y INTEGER); INSERT INTO guitar VALUES (1)`},
+	},
+}
+
+var ryou = migrate.Schema{
+	Name: "ryou",
+	Migrations: []migrate.Migration{
+		{Name: "bass", SQL: `CREATE TABLE bass (z INTEGER)`},
+	},
+}
+
+func TestApply(t *testing.T) {
+	conn := testDB(t)
+	p, err := migrate.Pending(conn, &bocchi)
+	if err != nil {
+		t.Fatalf("couldn't get pending migrations: %v", err)
+	}
+	if len(p) != 2 {
+		t.Errorf("wrong number of pending migrations: want 2, got %d", len(p))
+	}
+	n, err := migrate.Apply(conn, &bocchi)
+	if err != nil {
+		t.Errorf("couldn't apply migrations: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("wrong number of applied migrations: want 2, got %d", n)
+	}
+	if got := tables(t, conn); len(got) != 2 || got[0] != "guitar" || got[1] != "hitori" {
+		t.Errorf("wrong tables after migration: %q", got)
+	}
+	n, err = migrate.Apply(conn, &bocchi)
+	if err != nil {
+		t.Errorf("couldn't apply migrations again: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("applied %d migrations a second time", n)
+	}
+	v, err := migrate.Version(conn, &bocchi)
+	if err != nil {
+		t.Errorf("couldn't get version: %v", err)
+	}
+	if v != 2 {
+		t.Errorf("wrong version: want 2, got %d", v)
+	}
+}
+
+func TestShared(t *testing.T) {
+	conn := testDB(t)
+	if _, err := migrate.Apply(conn, &ryou); err != nil {
+		t.Errorf("couldn't apply ryou: %v", err)
+	}
+	if v, err := migrate.Version(conn, &bocchi); err != nil || v != 0 {
+		t.Errorf("wrong bocchi version after ryou: want 0, got %d (%v)", v, err)
+	}
+	if _, err := migrate.Apply(conn, &bocchi); err != nil {
+		t.Errorf("couldn't apply bocchi: %v", err)
+	}
+	if v, err := migrate.Version(conn, &ryou); err != nil || v != 1 {
+		t.Errorf("wrong ryou version after bocchi: want 1, got %d (%v)", v, err)
+	}
+	if v, err := migrate.Version(conn, &bocchi); err != nil || v != 2 {
+		t.Errorf("wrong bocchi version: want 2, got %d (%v)", v, err)
+	}
+	// There is no fixed limit on the number of stores.
+	for i := range 8 {
+		s := migrate.Schema{
+			Name:       fmt.Sprintf("kessoku-%d", i),
+			Migrations: []migrate.Migration{{Name: "band", SQL: fmt.Sprintf(`CREATE TABLE band%d (x INTEGER)`, i)}},
+		}
+		if _, err := migrate.Apply(conn, &s); err != nil {
+			t.Errorf("couldn't apply %s: %v", s.Name, err)
+		}
+		if v, err := migrate.Version(conn, &s); err != nil || v != 1 {
+			t.Errorf("wrong %s version: want 1, got %d (%v)", s.Name, v, err)
+		}
+	}
+	if v, err := migrate.Version(conn, &ryou); err != nil || v != 1 {
+		t.Errorf("wrong ryou version after many stores: want 1, got %d (%v)", v, err)
+	}
+}
+
+func TestTooNew(t *testing.T) {
+	conn := testDB(t)
+	if _, err := migrate.Apply(conn, &bocchi); err != nil {
+		t.Fatalf("couldn't apply migrations: %v", err)
+	}
+	old := bocchi
+	old.Migrations = old.Migrations[:1]
+	if _, err := migrate.Pending(conn, &old); !errors.Is(err, migrate.ErrTooNew) {
+		t.Errorf("wrong error from pending: want %v, got %v", migrate.ErrTooNew, err)
+	}
+	if _, err := migrate.Apply(conn, &old); !errors.Is(err, migrate.ErrTooNew) {
+		t.Errorf("wrong error from apply: want %v, got %v", migrate.ErrTooNew, err)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	conn := testDB(t)
+	bad := migrate.Schema{
+		Name: "kita",
+		Migrations: []migrate.Migration{
+			{Name: "ok", SQL: `CREATE TABLE ikuyo (x INTEGER)`},
+			{Name: "partial", SQL: `CREATE TABLE kitan (x INTEGER); INSERT INTO nowhere VALUES (1)`},
+		},
+	}
+	n, err := migrate.Apply(conn, &bad)
+	if err == nil {
+		t.Errorf("bad migration succeeded")
+	}
+	if n != 1 {
+		t.Errorf("wrong number of applied migrations: want 1, got %d", n)
+	}
+	if got := tables(t, conn); len(got) != 1 || got[0] != "ikuyo" {
+		t.Errorf("failed migration wasn't rolled back: tables are %q", got)
+	}
+	if v, err := migrate.Version(conn, &bad); err != nil || v != 1 {
+		t.Errorf("wrong version after failure: want 1, got %d (%v)", v, err)
+	}
+}
+
+func TestFunc(t *testing.T) {
+	conn := testDB(t)
+	s := migrate.Schema{
+		Name: "nijika",
+		Migrations: []migrate.Migration{
+			{
+				Name: "drums",
+				SQL:  `CREATE TABLE drums (x INTEGER)`,
+				Func: func(conn *sqlite.Conn) error {
+					return sqlitex.Execute(conn, `INSERT INTO drums VALUES (1), (2)`, nil)
+				},
+			},
+			{
+				Name: "broken",
+				SQL:  `CREATE TABLE sticks (x INTEGER)`,
+				Func: func(conn *sqlite.Conn) error {
+					return errors.New("dropped the sticks")
+				},
+			},
+		},
+	}
+	n, err := migrate.Apply(conn, &s)
+	if err == nil {
+		t.Errorf("failing func succeeded")
+	}
+	if n != 1 {
+		t.Errorf("wrong number of applied migrations: want 1, got %d", n)
+	}
+	if got := tables(t, conn); len(got) != 1 || got[0] != "drums" {
+		t.Errorf("failed func wasn't rolled back: tables are %q", got)
+	}
+	var rows int64
+	opts := sqlitex.ExecOptions{
+		ResultFunc: func(stmt *sqlite.Stmt) error {
+			rows = stmt.ColumnInt64(0)
+			return nil
+		},
+	}
+	if err := sqlitex.Execute(conn, `SELECT COUNT(*) FROM drums`, &opts); err != nil {
+		t.Fatalf("couldn't count rows: %v", err)
+	}
+	if rows != 2 {
+		t.Errorf("wrong number of rows from func: want 2, got %d", rows)
+	}
+}
+
+func TestPendingDryRun(t *testing.T) {
+	conn := testDB(t)
+	p, err := migrate.Pending(conn, &bocchi)
+	if err != nil {
+		t.Fatalf("couldn't get pending migrations: %v", err)
+	}
+	if len(p) != 2 || p[0].Name != "hitori" || p[1].Name != "guitar" {
+		t.Errorf("wrong pending migrations: %+v", p)
+	}
+	if got := tables(t, conn); len(got) != 0 {
+		t.Errorf("pending changed the database: tables are %q", got)
+	}
+}
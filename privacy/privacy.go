@@ -6,6 +6,8 @@ import (
 	"fmt"
 
 	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/migrate"
 )
 
 // ErrPrivate is an error returned by Check when the user is in the list.
@@ -23,13 +25,23 @@ func Open(ctx context.Context, db *sqlitex.Pool) (*List, error) {
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get connection from pool: %w", err)
 	}
-	const schemaSQL = `CREATE TABLE IF NOT EXISTS privacy (user TEXT PRIMARY KEY) STRICT, WITHOUT ROWID`
-	if err := sqlitex.ExecuteTransient(conn, schemaSQL, nil); err != nil {
+	if _, err := migrate.Apply(conn, &Schema); err != nil {
 		return nil, fmt.Errorf("couldn't run migration: %w", err)
 	}
 	return &List{db: db}, nil
 }
 
+// Schema is the list of migrations for privacy list databases.
+var Schema = migrate.Schema{
+	Name: "privacy",
+	Migrations: []migrate.Migration{
+		{
+			Name: "initial schema",
+			SQL:  `CREATE TABLE IF NOT EXISTS privacy (user TEXT PRIMARY KEY) STRICT, WITHOUT ROWID`,
+		},
+	},
+}
+
 // Add adds a user to the database.
 func (l *List) Add(ctx context.Context, user string) error {
 	conn, err := l.db.Take(ctx)
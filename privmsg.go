@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"math/rand/v2"
 	"regexp"
@@ -15,6 +16,7 @@ import (
 
 	"github.com/zephyrtronium/robot/brain"
 	"github.com/zephyrtronium/robot/channel"
+	"github.com/zephyrtronium/robot/chatlog"
 	"github.com/zephyrtronium/robot/command"
 	"github.com/zephyrtronium/robot/message"
 	"github.com/zephyrtronium/robot/privacy"
@@ -32,14 +34,32 @@ func (robo *Robot) tmiMessage(ctx context.Context, group *errgroup.Group, send c
 			// channel that isn't configured. Ignore it.
 			return
 		}
+		set := ch.Settings.Load()
 		m := message.FromTMI(msg)
 		log := slog.With(slog.String("trace", m.ID), slog.String("in", ch.Name))
 		from := m.Sender
-		if ch.Ignore[from] {
+		if set.Ignore[from] {
 			log.InfoContext(ctx, "message from ignored user")
 			return
 		}
-		if ch.Block.MatchString(m.Text) && !ch.Meme.MatchString(m.Text) {
+		if ch.Users.Ignored(m.Time(), from) {
+			// Spamming commands must never keep anyone from opting out, so
+			// privacy commands still work for temporarily ignored users.
+			if cmd, ok := parseCommand(robo.tmi.name, m.Text); ok && privacyCommand(cmd) {
+				robo.command(ctx, log, ch, m, from, cmd)
+				return
+			}
+			log.DebugContext(ctx, "message from temporarily ignored user")
+			return
+		}
+		ch.Velocity.Observe(m.Time())
+		// Update the gauges before anything can return early, so that they
+		// stay current while chat is full of commands or copypasta.
+		vel := ch.Velocity.Rate(m.Time())
+		p := set.ResponsesAt(m.Time(), vel)
+		chatVelocity.WithLabelValues(ch.Name).Set(vel)
+		responseProbability.WithLabelValues(ch.Name).Set(p)
+		if set.Block.MatchString(m.Text) && !set.Meme.MatchString(m.Text) {
 			log.InfoContext(ctx, "blocked message", slog.String("text", m.Text), slog.Bool("meme", false))
 			return
 		}
@@ -47,7 +67,9 @@ func (robo *Robot) tmiMessage(ctx context.Context, group *errgroup.Group, send c
 			robo.command(ctx, log, ch, m, from, cmd)
 			return
 		}
-		ch.History.Add(m.Time(), m.ID, m.Sender, m.Text)
+		who := robo.sender(m.Sender)
+		ch.History.Add(m.Time(), m.ID, who, m.Text)
+		robo.logHistory(ctx, log, ch, m, who)
 		// If the message is a reply to e.g. Bocchi, TMI adds @Bocchi to the
 		// start of the message text.
 		// That's helpful for commands, which we've already processed, but
@@ -58,25 +80,30 @@ func (robo *Robot) tmiMessage(ctx context.Context, group *errgroup.Group, send c
 			m.Text = t
 		}
 		robo.learn(ctx, log, ch, robo.hashes(), m)
-		switch err := ch.Memery.Check(m.Time(), from, m.Text); err {
+		if set.QuietAt(m.Time()) {
+			log.DebugContext(ctx, "quiet window")
+			return
+		}
+		rng := newRand()
+		text, err := ch.Memery.Check(m.Time(), from, m.Text)
+		switch err {
 		case channel.ErrNotCopypasta: // do nothing
 		case nil:
 			// Meme detected. Copypasta.
-			t := time.Now()
-			r := ch.Rate.ReserveN(t, 1)
-			if d := r.DelayFrom(t); d > 0 {
+			if lim, d := ch.Reserve(time.Now(), channel.BudgetCopypasta); lim != "" {
 				// But we can't meme it. Restore it so we can next time.
+				rateLimitedCount.WithLabelValues(ch.Name, lim).Inc()
 				log.InfoContext(ctx, "rate limited",
 					slog.String("action", "copypasta"),
+					slog.String("budget", lim),
 					slog.String("delay", d.String()),
 				)
-				ch.Memery.Unblock(m.Text)
-				r.CancelAt(t)
+				ch.Memery.Unblock(text)
 				return
 			}
-			f := ch.Effects.Pick(rand.Uint32())
-			s := command.Effect(log, f, m.Text)
-			if ch.Block.MatchString(s) && !ch.Meme.MatchString(s) {
+			f := set.Effects.Pick(rng.Uint32())
+			s := command.Effect(log, f, text)
+			if set.Block.MatchString(s) && !set.Meme.MatchString(s) {
 				// We would copypasta something that is blocked.
 				// Note that since we reached here at all, that implies the
 				// effect made it unacceptable.
@@ -95,54 +122,69 @@ func (robo *Robot) tmiMessage(ctx context.Context, group *errgroup.Group, send c
 			log.ErrorContext(ctx, "failed copypasta check", slog.Any("err", err))
 			// Continue on.
 		}
-		if rand.Float64() > ch.Responses {
+		if rng.Float64() > p {
 			return
 		}
-		start := time.Now()
-		s, trace, err := brain.Speak(ctx, robo.brain, ch.Send, "")
-		cost := time.Since(start)
-		if err != nil {
-			log.ErrorContext(ctx, "wanted to speak but failed", slog.Any("err", err))
+		robo.speak(ctx, log, ch, set, send, rng)
+	}
+	robo.enqueue(ctx, group, work)
+}
+
+// speak sends an unprompted message to a channel, using a pre-generated
+// message if one is available.
+func (robo *Robot) speak(ctx context.Context, log *slog.Logger, ch *channel.Channel, set *channel.Settings, send chan<- *tmi.Message, rng *rand.Rand) {
+	c, ok := ch.Pregen.Take()
+	if ok {
+		log.DebugContext(ctx, "using pregenerated message", slog.String("text", c.Text))
+	} else {
+		var err error
+		c, err = ch.Generate(ctx, log, robo.brain, "", rng, speakLatency.WithLabelValues(ch.Send))
+		if errors.Is(err, brain.ErrRejected) {
+			log.WarnContext(ctx, "wanted to send blocked message", slog.String("text", c.Text))
 			return
 		}
-		if s == "" {
-			log.InfoContext(ctx, "spoke nothing", slog.String("tag", ch.Send))
+		if err != nil {
+			log.ErrorContext(ctx, "wanted to speak but failed", slog.Any("err", err))
 			return
 		}
-		x := rand.Uint64()
-		e := ch.Emotes.Pick(uint32(x))
-		f := ch.Effects.Pick(uint32(x >> 32))
-		log.InfoContext(ctx, "speak",
-			slog.String("text", s),
-			slog.String("emote", e),
-			slog.String("effect", f),
+	}
+	s, trace, spans, cost := c.Text, c.Trace, c.Spans, c.Cost
+	if s == "" {
+		log.InfoContext(ctx, "spoke nothing", slog.String("tag", ch.Send))
+		return
+	}
+	x := rng.Uint64()
+	e := set.Emotes.Pick(uint32(x))
+	f := set.Effects.Pick(uint32(x >> 32))
+	log.InfoContext(ctx, "speak",
+		slog.String("text", s),
+		slog.String("emote", e),
+		slog.String("effect", f),
+		slog.Uint64("seed", c.Seed),
+	)
+	se := strings.TrimSpace(s + " " + e)
+	sef := command.Effect(log, f, se)
+	if err := robo.spoken.Record(ctx, ch.Send, sef, trace, spans, time.Now(), cost, c.Seed, s, e, f); err != nil {
+		log.ErrorContext(ctx, "record trace failed", slog.Any("err", err))
+		return
+	}
+	if set.Block.MatchString(se) || set.Block.MatchString(sef) {
+		log.WarnContext(ctx, "wanted to send blocked message", slog.String("text", sef))
+		return
+	}
+	// Now that we've done all the work, which might take substantial time,
+	// check whether we can use it.
+	if lim, d := ch.Reserve(time.Now(), channel.BudgetRandom); lim != "" {
+		rateLimitedCount.WithLabelValues(ch.Name, lim).Inc()
+		log.InfoContext(ctx, "rate limited",
+			slog.String("action", "speak"),
+			slog.String("budget", lim),
+			slog.String("delay", d.String()),
 		)
-		se := strings.TrimSpace(s + " " + e)
-		sef := command.Effect(log, f, se)
-		if err := robo.spoken.Record(ctx, ch.Send, sef, trace, time.Now(), cost, s, e, f); err != nil {
-			log.ErrorContext(ctx, "record trace failed", slog.Any("err", err))
-			return
-		}
-		if ch.Block.MatchString(se) || ch.Block.MatchString(sef) {
-			log.WarnContext(ctx, "wanted to send blocked message", slog.String("text", sef))
-			return
-		}
-		// Now that we've done all the work, which might take substantial time,
-		// check whether we can use it.
-		t := time.Now()
-		r := ch.Rate.ReserveN(t, 1)
-		if d := r.DelayFrom(t); d > 0 {
-			log.InfoContext(ctx, "rate limited",
-				slog.String("action", "speak"),
-				slog.String("delay", d.String()),
-			)
-			r.CancelAt(t)
-			return
-		}
-		msg := message.Format("", ch.Name, "%s", sef)
-		robo.sendTMI(ctx, send, msg)
+		return
 	}
-	robo.enqueue(ctx, group, work)
+	msg := message.Format("", ch.Name, "%s", sef)
+	robo.sendTMI(ctx, send, msg)
 }
 
 func (robo *Robot) command(ctx context.Context, log *slog.Logger, ch *channel.Channel, m *message.Received, from, cmd string) {
@@ -158,7 +200,7 @@ func (robo *Robot) command(ctx context.Context, log *slog.Logger, ch *channel.Ch
 			break
 		}
 		fallthrough
-	case ch.Mod[from], m.IsModerator:
+	case ch.Settings.Load().Mod[from], m.IsModerator:
 		c, args = findTwitch(twitchMod, cmd)
 		if c != nil {
 			level = "mod"
@@ -171,19 +213,51 @@ func (robo *Robot) command(ctx context.Context, log *slog.Logger, ch *channel.Ch
 	if c == nil {
 		return
 	}
+	if level == "any" && !c.privacy && ch.Settings.Load().QuietAt(m.Time()) {
+		// Moderators can still use commands during quiet windows, but
+		// everyone else gets silence. Learning continues during quiet windows,
+		// so privacy commands still work.
+		log.InfoContext(ctx, "command during quiet window", slog.String("name", c.name))
+		return
+	}
+	if level == "any" && !c.privacy {
+		switch ch.Users.Allow(m.Time(), from) {
+		case channel.Allowed: // do nothing
+		case channel.Limited:
+			log.InfoContext(ctx, "rate limited",
+				slog.String("action", "command"),
+				slog.String("name", c.name),
+				slog.String("user", from),
+			)
+			return
+		case channel.Ignoring:
+			// Mods should know when the robot starts ignoring someone.
+			log.WarnContext(ctx, "temporarily ignoring user for command spam",
+				slog.String("name", c.name),
+				slog.String("user", from),
+				slog.String("display", m.Name),
+			)
+			return
+		}
+	}
 	log.InfoContext(ctx, "command",
 		slog.String("level", level),
 		slog.String("name", c.name),
 		slog.Any("args", args),
 	)
 	r := command.Robot{
-		Log:      log.With(slog.String("command", c.name), slog.Any("args", args)),
-		Channels: robo.channels,
-		Brain:    robo.brain,
-		Privacy:  robo.privacy,
-		Spoken:   robo.spoken,
-		Owner:    robo.owner,
-		Contact:  robo.ownerContact,
+		Log:          log.With(slog.String("command", c.name), slog.Any("args", args)),
+		Channels:     robo.channels,
+		Brain:        robo.brain,
+		Privacy:      robo.privacy,
+		Spoken:       robo.spoken,
+		ChatLog:      robo.chatlog,
+		Sender:       robo.sender,
+		Owner:        robo.owner,
+		Contact:      robo.ownerContact,
+		SpeakLatency: speakLatency,
+		RateLimited:  rateLimitedCount,
+		Rand:         newRand(),
 	}
 	inv := command.Invocation{
 		Channel: ch,
@@ -234,7 +308,7 @@ func worker(ctx context.Context, works chan chan func(context.Context), ch chan
 
 // learn learns a given message's text if it passes ch's filters.
 func (robo *Robot) learn(ctx context.Context, log *slog.Logger, ch *channel.Channel, hasher userhash.Hasher, msg *message.Received) {
-	if !ch.Enabled.Load() {
+	if !ch.Enabled.Load() && !ch.Settings.Load().Offline {
 		log.DebugContext(ctx, "not learning in disabled channel")
 		return
 	}
@@ -247,7 +321,7 @@ func (robo *Robot) learn(ctx context.Context, log *slog.Logger, ch *channel.Chan
 		log.ErrorContext(ctx, "failed to check privacy", slog.Any("err", err))
 		return
 	}
-	if ch.Block.MatchString(msg.Text) {
+	if ch.Settings.Load().Block.MatchString(msg.Text) {
 		log.InfoContext(ctx, "blocked message", slog.String("text", msg.Text), slog.Bool("meme", true))
 		return
 	}
@@ -263,6 +337,26 @@ func (robo *Robot) learn(ctx context.Context, log *slog.Logger, ch *channel.Chan
 	learnedCount.Inc()
 }
 
+// logHistory persists a message added to a channel's history under the
+// sender key who, unless the sender is private or histories are not persisted.
+func (robo *Robot) logHistory(ctx context.Context, log *slog.Logger, ch *channel.Channel, msg *message.Received, who string) {
+	if robo.chatlog == nil {
+		return
+	}
+	switch err := robo.privacy.Check(ctx, msg.Sender); err {
+	case nil: // do nothing
+	case privacy.ErrPrivate:
+		return
+	default:
+		log.ErrorContext(ctx, "failed to check privacy", slog.Any("err", err))
+		return
+	}
+	m := chatlog.Message{Time: msg.Time(), ID: msg.ID, Sender: who, Text: msg.Text}
+	if err := robo.chatlog.Add(ctx, ch.Name, &m); err != nil {
+		log.ErrorContext(ctx, "failed to log history", slog.Any("err", err))
+	}
+}
+
 // sendTMI sends a message to TMI after waiting for the global rate limit.
 // The caller should verify that it is safe to send the message.
 func (robo *Robot) sendTMI(ctx context.Context, send chan<- *tmi.Message, msg message.Sent) {
@@ -323,6 +417,15 @@ type twitchCommand struct {
 	parse *regexp.Regexp
 	fn    command.Func
 	name  string
+	// privacy marks commands which manage a user's privacy.
+	// They are exempt from per-user limits and quiet windows.
+	privacy bool
+}
+
+// privacyCommand reports whether cmd invokes a privacy command.
+func privacyCommand(cmd string) bool {
+	c, _ := findTwitch(twitchAny, cmd)
+	return c != nil && c.privacy
 }
 
 func findTwitch(cmds []twitchCommand, text string) (*twitchCommand, map[string]string) {
@@ -365,28 +468,41 @@ var twitchMod = []twitchCommand{
 		fn:    command.DescribeMarriage,
 		name:  "describe-marriage",
 	},
+	{
+		parse: regexp.MustCompile(`(?i)^forget\s+where\s+you\s+(?:learned|got)\s+(?<phrase>.+)`),
+		fn:    command.ForgetSource,
+		name:  "forget-source",
+	},
 	{
 		parse: regexp.MustCompile(`(?i)^forgr?[eo]?r?t\s+(?:everything$|(?<term>.+))`),
 		fn:    command.Forget,
 		name:  "forget",
 	},
+	{
+		parse: regexp.MustCompile(`(?i)^how\s+much\s+do\s+you\s+know`),
+		fn:    command.Stats,
+		name:  "stats",
+	},
 }
 
 var twitchAny = []twitchCommand{
 	{
-		parse: regexp.MustCompile(`^(?i:give\s+me\s+privacy|ignore\s+me)`),
-		fn:    command.Private,
-		name:  "private",
+		parse:   regexp.MustCompile(`^(?i:give\s+me\s+privacy|ignore\s+me)`),
+		fn:      command.Private,
+		name:    "private",
+		privacy: true,
 	},
 	{
-		parse: regexp.MustCompile(`(?i)^(?:you\s+(?:can|may)\s+)?learn\s+from\s+me(?:\s+again)?|invade\s+my\s+privacy`),
-		fn:    command.Unprivate,
-		name:  "unprivate",
+		parse:   regexp.MustCompile(`(?i)^(?:you\s+(?:can|may)\s+)?learn\s+from\s+me(?:\s+again)?|invade\s+my\s+privacy`),
+		fn:      command.Unprivate,
+		name:    "unprivate",
+		privacy: true,
 	},
 	{
-		parse: regexp.MustCompile(`(?i)^what\s+(?:info(?:rmation)?\s+)do\s+you\s+(?:collect|store)`),
-		fn:    command.DescribePrivacy,
-		name:  "describe-privacy",
+		parse:   regexp.MustCompile(`(?i)^what\s+(?:info(?:rmation)?\s+)do\s+you\s+(?:collect|store)`),
+		fn:      command.DescribePrivacy,
+		name:    "describe-privacy",
+		privacy: true,
 	},
 	{
 		parse: regexp.MustCompile(`(?i)^[¿¡]*\s*(?:ple?a?se?\s+)?(?:will\s+y?o?u\s+)?(?:\s*ple?a?se?\s+)?(?:marry\s+me|be?\s+my\s+(?<partnership>wife|waifu|h[ua]su?bando?|partner|spouse|daddy|mommy))`),
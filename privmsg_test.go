@@ -1,6 +1,20 @@
 package main
 
-import "testing"
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"testing"
+
+	"gitlab.com/zephyrtronium/pick"
+	"gitlab.com/zephyrtronium/tmi"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/channel"
+	"github.com/zephyrtronium/robot/message"
+	"github.com/zephyrtronium/robot/privacy"
+)
 
 func TestParseCommand(t *testing.T) {
 	cases := []struct {
@@ -36,3 +50,87 @@ func TestParseCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestPrivacyCommand(t *testing.T) {
+	cases := []struct {
+		cmd  string
+		want bool
+	}{
+		{"give me privacy", true},
+		{"ignore me", true},
+		{"learn from me again", true},
+		{"what information do you collect", true},
+		{"say something", false},
+		{"marry me", false},
+		{"rawr", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := privacyCommand(c.cmd); got != c.want {
+			t.Errorf("wrong privacy for %q: want %t, got %t", c.cmd, c.want, got)
+		}
+	}
+}
+
+func TestModCommand(t *testing.T) {
+	cases := []struct {
+		cmd  string
+		name string
+		args map[string]string
+	}{
+		{"forget where you learned kita ikuyo", "forget-source", map[string]string{"phrase": "kita ikuyo"}},
+		{"Forget where you got KITA", "forget-source", map[string]string{"phrase": "KITA"}},
+		{"forget kita ikuyo", "forget", map[string]string{"term": "kita ikuyo"}},
+		{"forget everything", "forget", map[string]string{"term": ""}},
+	}
+	for _, c := range cases {
+		cmd, args := findTwitch(twitchMod, c.cmd)
+		if cmd == nil {
+			t.Errorf("no command for %q", c.cmd)
+			continue
+		}
+		if cmd.name != c.name {
+			t.Errorf("wrong command for %q: want %s, got %s", c.cmd, c.name, cmd.name)
+		}
+		if !maps.Equal(args, c.args) {
+			t.Errorf("wrong args for %q: want %q, got %q", c.cmd, c.args, args)
+		}
+	}
+}
+
+func TestQuietPrivacyCommands(t *testing.T) {
+	ctx := context.Background()
+	pool, err := sqlitex.NewPool("file:quiet-privacy.db?mode=memory&cache=shared", sqlitex.PoolOptions{Flags: sqlite.OpenReadWrite | sqlite.OpenCreate | sqlite.OpenMemory | sqlite.OpenSharedCache | sqlite.OpenURI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+	robo := New(nil, 1)
+	robo.privacy, err = privacy.Open(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	robo.tmi = &client[*tmi.Message, *tmi.Message]{name: "bocchi", owner: "kikuri"}
+	var sent []string
+	ch := &channel.Channel{
+		Name: "#kessoku",
+		Message: func(ctx context.Context, reply, text string) {
+			sent = append(sent, text)
+		},
+	}
+	ch.Settings.Store(&channel.Settings{
+		Emotes: pick.New(pick.FromMap(map[string]int{"": 1})),
+		// End not after Start means the window lasts all day.
+		Schedule: &channel.Schedule{Windows: []channel.Window{{Quiet: true}}},
+	})
+	m := &message.Received{ID: "1", To: "#kessoku", Sender: "ryo", Timestamp: 1}
+	log := slog.Default()
+	robo.command(ctx, log, ch, m, m.Sender, "rawr")
+	if len(sent) != 0 {
+		t.Errorf("command ran during quiet window: %q", sent)
+	}
+	robo.command(ctx, log, ch, m, m.Sender, "give me privacy")
+	if err := robo.privacy.Check(ctx, "ryo"); err == nil {
+		t.Errorf("privacy command didn't run during quiet window")
+	}
+}
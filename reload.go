@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zephyrtronium/robot/channel"
+)
+
+// reloadRequest is a request to apply a new configuration to a running robot.
+type reloadRequest struct {
+	cfg  *Config
+	done chan error
+}
+
+// Reload applies a new configuration to a running robot.
+// Channel settings such as block expressions, response probability, emotes,
+// effects, privileges, and rate limits take effect immediately.
+// Channels added to the configuration are joined, and channels removed from
+// it are parted. Existing channels keep their history, meme detector, chat
+// velocity, per-user limits, and pre-generated messages; changes to those and
+// to channel tags or retention require a restart, and each is logged.
+//
+// If the new configuration is invalid, nothing changes and the error
+// describes why. Reload blocks until the robot is running.
+func (robo *Robot) Reload(ctx context.Context, cfg *Config) error {
+	req := reloadRequest{cfg: cfg, done: make(chan error, 1)}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case robo.reloads <- req:
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-req.done:
+		return err
+	}
+}
+
+// reloadLoop applies configuration reloads for the lifetime of Run.
+func (robo *Robot) reloadLoop(ctx context.Context, group *errgroup.Group, pregens map[*channel.Channel]context.CancelFunc) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case req := <-robo.reloads:
+			req.done <- robo.reloadTwitch(ctx, group, pregens, req.cfg)
+		}
+	}
+}
+
+// reloadTwitch applies new Twitch channel configuration.
+func (robo *Robot) reloadTwitch(ctx context.Context, group *errgroup.Group, pregens map[*channel.Channel]context.CancelFunc, cfg *Config) error {
+	if robo.tmi == nil {
+		return errors.New("no Twitch configuration to reload")
+	}
+	// Resolve and validate everything before changing anything.
+	if err := robo.InitTwitchUsers(ctx, &cfg.TMI.Owner, cfg.Global.Privileges.Twitch, cfg.Twitch); err != nil {
+		return err
+	}
+	chans, err := robo.twitchChannels(cfg.Global, cfg.Twitch)
+	if err != nil {
+		return err
+	}
+	setBudget(robo.copypasta, cfg.Global.Copypasta)
+	cfgs := channelCfgs(cfg.Twitch)
+	next := make(map[string]*ChannelCfg, len(cfgs))
+	keep := make(map[string]bool, len(chans))
+	var join, part []string
+	for _, v := range chans {
+		keep[v.Name] = true
+		old, ok := robo.channels.Load(v.Name)
+		if !ok {
+			if robo.chatlog != nil {
+				robo.loadHistory(ctx, v, time.Now())
+			}
+			robo.channels.Store(v.Name, v)
+			next[v.Name] = cfgs[v.Name]
+			robo.startPregen(ctx, group, pregens, v)
+			join = append(join, v.Name)
+			slog.InfoContext(ctx, "added channel", slog.String("in", v.Name))
+			continue
+		}
+		old.Settings.Store(v.Settings.Load())
+		old.Rate.SetLimit(v.Rate.Limit())
+		old.Rate.SetBurst(v.Rate.Burst())
+		for k, l := range v.Budgets {
+			old.Budgets[k].SetLimit(l.Limit())
+			old.Budgets[k].SetBurst(l.Burst())
+		}
+		// The channel keeps running with the parts of its old configuration
+		// which can't change in place, so keep comparing against that.
+		prev := robo.twitchCfg[v.Name]
+		if prev == nil {
+			prev = cfgs[v.Name]
+		}
+		next[v.Name] = prev
+		for _, f := range restartFields(prev, cfgs[v.Name]) {
+			slog.WarnContext(ctx, "channel config changed; restart to apply",
+				slog.String("in", v.Name),
+				slog.String("field", f),
+			)
+		}
+		slog.InfoContext(ctx, "reloaded channel", slog.String("in", v.Name))
+	}
+	robo.twitchCfg = next
+	for nm, ch := range robo.channels.All() {
+		if keep[nm] {
+			continue
+		}
+		robo.channels.Delete(nm)
+		if stop := pregens[ch]; stop != nil {
+			stop()
+			delete(pregens, ch)
+		}
+		chatVelocity.DeleteLabelValues(nm)
+		responseProbability.DeleteLabelValues(nm)
+		part = append(part, nm)
+		slog.InfoContext(ctx, "removed channel", slog.String("in", nm))
+	}
+	go joinPart(ctx, robo.tmi.send, "PART", part)
+	go joinPart(ctx, robo.tmi.send, "JOIN", join)
+	return nil
+}
+
+// restartFields lists the fields of a channel's configuration which changed
+// from old to cur but which only apply to new channels.
+func restartFields(old, cur *ChannelCfg) []string {
+	var r []string
+	if old.Learn != cur.Learn {
+		r = append(r, "learn")
+	}
+	if old.Send != cur.Send {
+		r = append(r, "send")
+	}
+	if old.Retain != cur.Retain {
+		r = append(r, "retain")
+	}
+	if !old.Copypasta.equal(cur.Copypasta) {
+		r = append(r, "copypasta")
+	}
+	if old.History != cur.History {
+		r = append(r, "history")
+	}
+	if old.Pregen != cur.Pregen {
+		r = append(r, "pregen")
+	}
+	if old.Users != cur.Users {
+		r = append(r, "users")
+	}
+	if old.Adaptive.Window != cur.Adaptive.Window {
+		r = append(r, "adaptive.window")
+	}
+	return r
+}
+
+// equal reports whether two copypasta configurations are the same.
+func (c Copypasta) equal(d Copypasta) bool {
+	return c.Need == d.Need &&
+		c.Within == d.Within &&
+		slices.Equal(c.Normalize, d.Normalize) &&
+		c.Similarity == d.Similarity &&
+		c.MinLength == d.MinLength &&
+		c.MinTokens == d.MinTokens &&
+		c.Ignore == d.Ignore &&
+		c.Fresh == d.Fresh
+}
+
+// reloadOnSignal reloads configuration from a file whenever the process
+// receives SIGHUP.
+func reloadOnSignal(ctx context.Context, reload func(context.Context) error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.InfoContext(ctx, "reloading config on SIGHUP")
+			if err := reload(ctx); err != nil {
+				slog.ErrorContext(ctx, "couldn't reload config", slog.Any("err", err))
+				continue
+			}
+			slog.InfoContext(ctx, "reloaded config")
+		}
+	}
+}
+
+// reloadHandler serves requests to reload configuration.
+// Requests must have token as a bearer token.
+func reloadHandler(token []byte, reload func(context.Context) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(h), token) != 1 {
+			slog.WarnContext(r.Context(), "unauthorized reload request", slog.String("remote", r.RemoteAddr))
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		slog.InfoContext(r.Context(), "reloading config on request", slog.String("remote", r.RemoteAddr))
+		if err := reload(r.Context()); err != nil {
+			slog.ErrorContext(r.Context(), "couldn't reload config", slog.Any("err", err))
+			http.Error(w, fmt.Sprintf("couldn't reload config: %v", err), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/zephyrtronium/tmi"
+	"golang.org/x/oauth2"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zephyrtronium/robot/channel"
+	"github.com/zephyrtronium/robot/twitch"
+)
+
+// fakeTokens is an auth.TokenSource which always has the same token.
+type fakeTokens struct{}
+
+func (fakeTokens) Token(ctx context.Context) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "bocchi"}, nil
+}
+
+func (fakeTokens) Refresh(ctx context.Context, old *oauth2.Token) (*oauth2.Token, error) {
+	return old, nil
+}
+
+// fakeHelix is an http.RoundTripper which answers every request with the
+// same user.
+type fakeHelix struct{}
+
+func (fakeHelix) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := `{"data":[{"id":"1","login":"zephyrtronium"}]}`
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+	return resp, nil
+}
+
+func reloadRobot(t *testing.T, chans map[string]*ChannelCfg) *Robot {
+	t.Helper()
+	robo := New(nil, 1)
+	robo.tmi = &client[*tmi.Message, *tmi.Message]{
+		send:   make(chan *tmi.Message, 8),
+		tokens: fakeTokens{},
+	}
+	robo.twitch = twitch.Client{HTTP: &http.Client{Transport: fakeHelix{}}}
+	if err := robo.SetTwitchChannels(context.Background(), Global{}, chans); err != nil {
+		t.Fatalf("couldn't set channels: %v", err)
+	}
+	return robo
+}
+
+func channelNames(robo *Robot) []string {
+	var r []string
+	for nm := range robo.channels.All() {
+		r = append(r, nm)
+	}
+	slices.Sort(r)
+	return r
+}
+
+func TestReloadTwitch(t *testing.T) {
+	ctx := context.Background()
+	robo := reloadRobot(t, map[string]*ChannelCfg{
+		"kessoku":  {Channels: []string{"#bocchi", "#kita"}, Learn: "kessoku", Send: "kessoku", Responses: 0.1},
+		"sickhack": {Channels: []string{"#ryo"}, Learn: "sickhack", Send: "sickhack"},
+	})
+	bocchi, _ := robo.channels.Load("#bocchi")
+	var group errgroup.Group
+	pregens := make(map[*channel.Channel]context.CancelFunc)
+
+	cfg := &Config{Twitch: map[string]*ChannelCfg{
+		"kessoku": {Channels: []string{"#bocchi", "#nijika"}, Learn: "kessoku", Send: "kessoku", Responses: 0.5},
+	}}
+	if err := robo.reloadTwitch(ctx, &group, pregens, cfg); err != nil {
+		t.Fatalf("couldn't reload: %v", err)
+	}
+	if got, want := channelNames(robo), []string{"#bocchi", "#nijika"}; !slices.Equal(got, want) {
+		t.Errorf("wrong channels after reload: want %q, got %q", want, got)
+	}
+	if ch, _ := robo.channels.Load("#bocchi"); ch != bocchi {
+		t.Errorf("changed channel was replaced")
+	}
+	if got := bocchi.Settings.Load().Responses; got != 0.5 {
+		t.Errorf("changed channel has wrong response probability: want 0.5, got %v", got)
+	}
+	sent := make(map[string][]string)
+	for range 2 {
+		select {
+		case msg := <-robo.tmi.send:
+			l := strings.Split(msg.Params[0], ",")
+			slices.Sort(l)
+			sent[msg.Command] = l
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for JOIN and PART; got %q", sent)
+		}
+	}
+	if got, want := sent["JOIN"], []string{"#nijika"}; !slices.Equal(got, want) {
+		t.Errorf("wrong joins: want %q, got %q", want, got)
+	}
+	if got, want := sent["PART"], []string{"#kita", "#ryo"}; !slices.Equal(got, want) {
+		t.Errorf("wrong parts: want %q, got %q", want, got)
+	}
+
+	bad := &Config{Twitch: map[string]*ChannelCfg{
+		"kessoku": {Channels: []string{"#bocchi"}, Learn: "kessoku", Send: "kessoku", Responses: 1, Block: "("},
+	}}
+	if err := robo.reloadTwitch(ctx, &group, pregens, bad); err == nil {
+		t.Errorf("invalid config reloaded without error")
+	}
+	if got, want := channelNames(robo), []string{"#bocchi", "#nijika"}; !slices.Equal(got, want) {
+		t.Errorf("wrong channels after invalid reload: want %q, got %q", want, got)
+	}
+	if got := bocchi.Settings.Load().Responses; got != 0.5 {
+		t.Errorf("invalid reload changed response probability to %v", got)
+	}
+	if len(robo.tmi.send) != 0 {
+		t.Errorf("invalid reload sent %d messages", len(robo.tmi.send))
+	}
+	if err := group.Wait(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestartFields(t *testing.T) {
+	base := func() *ChannelCfg {
+		return &ChannelCfg{
+			Learn:     "kessoku",
+			Send:      "kessoku",
+			Copypasta: Copypasta{Need: 2, Normalize: []string{"case"}},
+			History:   HistoryCfg{Window: 60},
+			Users:     UsersCfg{Every: 10, Num: 1},
+		}
+	}
+	cases := []struct {
+		name   string
+		change func(c *ChannelCfg)
+		want   []string
+	}{
+		{
+			name:   "none",
+			change: func(c *ChannelCfg) {},
+			want:   nil,
+		},
+		{
+			name:   "settings",
+			change: func(c *ChannelCfg) { c.Responses = 1; c.Block = "bocchi" },
+			want:   nil,
+		},
+		{
+			name:   "tags",
+			change: func(c *ChannelCfg) { c.Learn = "sickhack"; c.Retain = 1 },
+			want:   []string{"learn", "retain"},
+		},
+		{
+			name:   "copypasta",
+			change: func(c *ChannelCfg) { c.Copypasta.Normalize = []string{"case", "space"} },
+			want:   []string{"copypasta"},
+		},
+		{
+			name: "everything else",
+			change: func(c *ChannelCfg) {
+				c.History.Size = 10
+				c.Pregen = 1
+				c.Users.Strikes = 3
+				c.Adaptive.Window = 30
+			},
+			want: []string{"history", "pregen", "users", "adaptive.window"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cur := base()
+			c.change(cur)
+			got := restartFields(base(), cur)
+			if !slices.Equal(got, c.want) {
+				t.Errorf("wrong fields: want %q, got %q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestReloadHandler(t *testing.T) {
+	cases := []struct {
+		name   string
+		auth   string
+		status int
+		calls  int
+	}{
+		{"none", "", http.StatusUnauthorized, 0},
+		{"wrong", "Bearer kita", http.StatusUnauthorized, 0},
+		{"bare", "bocchi", http.StatusUnauthorized, 0},
+		{"ok", "Bearer bocchi", http.StatusNoContent, 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			calls := 0
+			h := reloadHandler([]byte("bocchi"), func(ctx context.Context) error {
+				calls++
+				return nil
+			})
+			req := httptest.NewRequest("POST", "/admin/reload", nil)
+			if c.auth != "" {
+				req.Header.Set("Authorization", c.auth)
+			}
+			w := httptest.NewRecorder()
+			h(w, req)
+			if w.Code != c.status {
+				t.Errorf("wrong status: want %d, got %d", c.status, w.Code)
+			}
+			if calls != c.calls {
+				t.Errorf("wrong number of reloads: want %d, got %d", c.calls, calls)
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"slices"
 	"strings"
 	"time"
 
@@ -16,6 +18,7 @@ import (
 	"github.com/zephyrtronium/robot/auth"
 	"github.com/zephyrtronium/robot/brain"
 	"github.com/zephyrtronium/robot/channel"
+	"github.com/zephyrtronium/robot/chatlog"
 	"github.com/zephyrtronium/robot/privacy"
 	"github.com/zephyrtronium/robot/spoken"
 	"github.com/zephyrtronium/robot/syncmap"
@@ -31,8 +34,23 @@ type Robot struct {
 	privacy *privacy.List
 	// spoken is the history of generated messages.
 	spoken *spoken.History
+	// chatlog is the persistent log of channel histories.
+	// It is nil if histories are not persisted.
+	chatlog *chatlog.Log
 	// channels are the channels.
 	channels *syncmap.Map[string, *channel.Channel]
+	// twitchCfg is the configuration from which each Twitch channel was
+	// created, keyed by channel name. Reloads use it to find changes which
+	// require a restart.
+	twitchCfg map[string]*ChannelCfg
+	// copypasta is the rate limiter for copypasta shared by all channels.
+	copypasta *rate.Limiter
+	// snapshot saves the brain. It is nil if the brain needs no saving.
+	snapshot func(context.Context) error
+	// snapshotEvery is the interval between calls to snapshot.
+	snapshotEvery time.Duration
+	// reloads carries requests to apply new configuration while running.
+	reloads chan reloadRequest
 	// works is the worker queue.
 	works chan chan func(context.Context)
 	// hashes is a function that obtains userhashers.
@@ -72,18 +90,37 @@ type client[Send, Receive any] struct {
 // New creates a new robot instance.
 func New(usersKey []byte, poolSize int) *Robot {
 	return &Robot{
-		channels: syncmap.New[string, *channel.Channel](),
-		works:    make(chan chan func(context.Context), poolSize),
-		hashes:   func() userhash.Hasher { return userhash.New(usersKey) },
+		channels:  syncmap.New[string, *channel.Channel](),
+		copypasta: rate.NewLimiter(rate.Inf, 0),
+		reloads:   make(chan reloadRequest),
+		works:     make(chan chan func(context.Context), poolSize),
+		hashes:    func() userhash.Hasher { return userhash.New(usersKey) },
 	}
 }
 
 func (robo *Robot) Run(ctx context.Context) error {
+	if robo.chatlog != nil {
+		// Restore histories before any new messages can arrive.
+		robo.loadHistories(ctx, time.Now())
+	}
 	group, ctx := errgroup.WithContext(ctx)
 	// TODO(zeph): stdin?
 	if robo.tmi != nil {
 		group.Go(func() error { return robo.runTwitch(ctx, group) })
 	}
+	// Channels with retention can be added by reloading, so always watch.
+	group.Go(func() error { return robo.retainLoop(ctx) })
+	if robo.chatlog != nil {
+		group.Go(func() error { return robo.chatlogLoop(ctx) })
+	}
+	if robo.snapshot != nil {
+		group.Go(func() error { return robo.snapshotLoop(ctx) })
+	}
+	pregens := make(map[*channel.Channel]context.CancelFunc)
+	for _, ch := range robo.channels.All() {
+		robo.startPregen(ctx, group, pregens, ch)
+	}
+	group.Go(func() error { return robo.reloadLoop(ctx, group, pregens) })
 	err := group.Wait()
 	if err == context.Canceled {
 		// If the first error is context canceled, then we are shutting down
@@ -104,6 +141,9 @@ func (robo *Robot) runTwitch(ctx context.Context, group *errgroup.Group) error {
 	group.Go(func() error {
 		return robo.streamsLoop(ctx, robo.channels)
 	})
+	group.Go(func() error {
+		return robo.idleLoop(ctx, group)
+	})
 	tok, err := robo.tmi.tokens.Token(ctx)
 	if err != nil {
 		return err
@@ -281,6 +321,233 @@ func (robo *Robot) streamsLoop(ctx context.Context, channels *syncmap.Map[string
 	}
 }
 
+// retainLoop periodically expires messages learned longer ago than their
+// channels' retention periods.
+func (robo *Robot) retainLoop(ctx context.Context) error {
+	ex, _ := robo.brain.(brain.Expirer)
+	tick := time.NewTicker(time.Hour)
+	defer tick.Stop()
+	for {
+		robo.expire(ctx, ex, time.Now())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C: // continue below
+		}
+	}
+}
+
+// learnTags returns the sorted learn tags of the current channels.
+func (robo *Robot) learnTags() []string {
+	var tags []string
+	for _, ch := range robo.channels.All() {
+		if ch.Learn != "" && !slices.Contains(tags, ch.Learn) {
+			tags = append(tags, ch.Learn)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// expire expires old messages in each learn tag.
+// If ex is nil, it only warns about channels which have retention periods.
+func (robo *Robot) expire(ctx context.Context, ex brain.Expirer, now time.Time) {
+	// Multiple channels can share a learn tag. In that case, use the shortest
+	// retention period among them.
+	tags := make(map[string]time.Duration)
+	for _, ch := range robo.channels.All() {
+		if ch.Retain <= 0 || ch.Learn == "" {
+			continue
+		}
+		if d, ok := tags[ch.Learn]; !ok || ch.Retain < d {
+			tags[ch.Learn] = ch.Retain
+		}
+	}
+	if len(tags) != 0 && ex == nil {
+		slog.WarnContext(ctx, "brain cannot expire messages; retention is disabled")
+		return
+	}
+	for tag, d := range tags {
+		before := now.Add(-d)
+		n, err := ex.Expire(ctx, tag, before)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to expire messages", slog.Any("err", err), slog.String("tag", tag))
+			continue
+		}
+		expiredCount.Add(float64(n))
+		slog.InfoContext(ctx, "expired messages",
+			slog.String("tag", tag),
+			slog.Time("before", before),
+			slog.Int64("count", n),
+		)
+		if n == 0 {
+			continue
+		}
+		// Pregenerated messages don't know which messages they came from, so
+		// any from this tag might use expired ones.
+		for _, c := range robo.channels.All() {
+			if c.Send == tag {
+				c.Pregen.Clear()
+			}
+		}
+	}
+}
+
+// loadHistories restores channel histories from the chat log.
+func (robo *Robot) loadHistories(ctx context.Context, now time.Time) {
+	for _, ch := range robo.channels.All() {
+		robo.loadHistory(ctx, ch, now)
+	}
+}
+
+// loadHistory restores one channel's history from the chat log.
+func (robo *Robot) loadHistory(ctx context.Context, ch *channel.Channel, now time.Time) {
+	msgs, err := robo.chatlog.Since(ctx, ch.Name, now.Add(-ch.History.Window()))
+	if err != nil {
+		slog.ErrorContext(ctx, "couldn't load history", slog.String("in", ch.Name), slog.Any("err", err))
+		return
+	}
+	for _, m := range msgs {
+		ch.History.Add(m.Time, m.ID, m.Sender, m.Text)
+	}
+	slog.InfoContext(ctx, "loaded history", slog.String("in", ch.Name), slog.Int("count", len(msgs)))
+}
+
+// chatlogLoop periodically deletes logged messages which have fallen out of
+// their channels' history windows. Messages from channels which are no longer
+// in use are deleted once they are older than every current window.
+func (robo *Robot) chatlogLoop(ctx context.Context) error {
+	tick := time.NewTicker(time.Minute)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C: // continue below
+		}
+		robo.expireChatlog(ctx, time.Now())
+	}
+}
+
+// expireChatlog deletes logged messages which have fallen out of history
+// windows as of now.
+func (robo *Robot) expireChatlog(ctx context.Context, now time.Time) {
+	var longest time.Duration
+	for _, ch := range robo.channels.All() {
+		w := ch.History.Window()
+		longest = max(longest, w)
+		n, err := robo.chatlog.Expire(ctx, ch.Name, now.Add(-w))
+		if err != nil {
+			slog.ErrorContext(ctx, "couldn't expire chat log", slog.String("in", ch.Name), slog.Any("err", err))
+			continue
+		}
+		slog.DebugContext(ctx, "expired chat log", slog.String("in", ch.Name), slog.Int("count", n))
+	}
+	n, err := robo.chatlog.ExpireAll(ctx, now.Add(-longest))
+	if err != nil {
+		slog.ErrorContext(ctx, "couldn't expire chat log", slog.Any("err", err))
+		return
+	}
+	slog.DebugContext(ctx, "expired chat log", slog.Int("count", n))
+}
+
+// sender gets the key which identifies a user ID as a sender in channel
+// histories and the chat log.
+func (robo *Robot) sender(id string) string {
+	return string(robo.hashes().Sender(new(userhash.Hash), id)[:])
+}
+
+// snapshotLoop periodically saves the brain.
+func (robo *Robot) snapshotLoop(ctx context.Context) error {
+	tick := time.NewTicker(robo.snapshotEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick.C: // continue below
+		}
+		if err := robo.snapshot(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "couldn't save brain", slog.Any("err", err))
+		}
+	}
+}
+
+// startPregen starts a channel's pre-generation loop, if it has one.
+// The loop stops when ctx is canceled or when the channel's entry in pregens
+// is called.
+func (robo *Robot) startPregen(ctx context.Context, group *errgroup.Group, pregens map[*channel.Channel]context.CancelFunc, ch *channel.Channel) {
+	if ch.Pregen == nil || ch.Send == "" {
+		return
+	}
+	pctx, cancel := context.WithCancel(ctx)
+	pregens[ch] = cancel
+	group.Go(func() error {
+		err := robo.pregenLoop(pctx, ch)
+		if ctx.Err() == nil {
+			// Only this channel stopped, e.g. because it was removed.
+			// That isn't a reason to stop everything else.
+			return nil
+		}
+		return err
+	})
+}
+
+// pregenLoop keeps a channel's queue of pre-generated messages full.
+func (robo *Robot) pregenLoop(ctx context.Context, ch *channel.Channel) error {
+	log := slog.With(slog.String("in", ch.Name), slog.String("tag", ch.Send))
+	rng := newRand()
+	for {
+		for ch.Pregen.Need() > 0 {
+			wait := robo.pregen(ctx, log, ch, rng)
+			if wait == 0 {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ch.Pregen.Wait(): // continue above
+		}
+	}
+}
+
+// pregen generates one message into a channel's pre-generation queue.
+// The result is the time to wait before trying again.
+func (robo *Robot) pregen(ctx context.Context, log *slog.Logger, ch *channel.Channel, rng *rand.Rand) time.Duration {
+	epoch := ch.Pregen.Epoch()
+	c, err := ch.Generate(ctx, log, robo.brain, "", rng, speakLatency.WithLabelValues(ch.Send))
+	switch {
+	case errors.Is(err, brain.ErrRejected):
+		log.DebugContext(ctx, "pregenerated blocked message", slog.String("text", c.Text))
+		// Don't spin if the brain only has blocked things to say.
+		return time.Second
+	case err != nil:
+		if ctx.Err() == nil {
+			log.ErrorContext(ctx, "couldn't pregenerate", slog.Any("err", err))
+		}
+		return time.Minute
+	case c.Text == "":
+		// Probably nothing learned yet.
+		return time.Minute
+	}
+	if !ch.Pregen.Put(c, epoch) {
+		log.DebugContext(ctx, "discarded pregenerated message", slog.String("text", c.Text))
+	}
+	return 0
+}
+
+// newRand creates a source for the random choices involved in handling one
+// event. Its seed comes from the global source, which is securely seeded.
+func newRand() *rand.Rand {
+	return brain.NewRand(rand.Uint64())
+}
+
 func deviceCodePrompt(userCode, verURI, verURIComplete string) {
 	fmt.Println("\n---- OAuth2 Device Code Flow ----")
 	if verURIComplete != "" {
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zephyrtronium/robot/channel"
+)
+
+// expirer is a brain.Expirer which expires a fixed number of messages.
+type expirer struct {
+	n    int64
+	tags []string
+}
+
+func (e *expirer) Expire(ctx context.Context, tag string, before time.Time) (int64, error) {
+	e.tags = append(e.tags, tag)
+	return e.n, nil
+}
+
+func TestExpireClearsPregen(t *testing.T) {
+	ctx := context.Background()
+	robo := New(nil, 1)
+	mk := func(name, learn, send string, retain time.Duration) *channel.Channel {
+		ch := &channel.Channel{Name: name, Learn: learn, Send: send, Retain: retain, Pregen: channel.NewPregen(1)}
+		if !ch.Pregen.Put(channel.Candidate{Text: "bocchi"}, ch.Pregen.Epoch()) {
+			t.Fatal("couldn't fill pregen")
+		}
+		robo.channels.Store(name, ch)
+		return ch
+	}
+	kessoku := mk("#kessoku", "kessoku", "kessoku", time.Hour)
+	// Sends from the expired tag without learning into it.
+	listener := mk("#listener", "", "kessoku", 0)
+	sickhack := mk("#sickhack", "sickhack", "sickhack", 0)
+
+	ex := &expirer{n: 0}
+	robo.expire(ctx, ex, time.Now())
+	if len(ex.tags) != 1 || ex.tags[0] != "kessoku" {
+		t.Errorf("wrong tags expired: want [kessoku], got %q", ex.tags)
+	}
+	if kessoku.Pregen.Need() != 0 {
+		t.Errorf("pregen cleared when nothing expired")
+	}
+
+	ex.n = 1
+	robo.expire(ctx, ex, time.Now())
+	if kessoku.Pregen.Need() != 1 || listener.Pregen.Need() != 1 {
+		t.Errorf("pregen sending from expired tag wasn't cleared")
+	}
+	if sickhack.Pregen.Need() != 0 {
+		t.Errorf("pregen sending from another tag was cleared")
+	}
+}
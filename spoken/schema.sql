@@ -11,9 +11,13 @@ CREATE TABLE IF NOT EXISTS spoken (
 	time INTEGER NOT NULL,
 	-- Various metadata about the message, stored as a JSONB object.
 	-- May include:
+	-- 	"orig": Generated message prior to applying emote or effect.
 	-- 	"emote": Emote appended to the message.
 	-- 	"effect": Name of the effect applied to the message.
 	-- 	"cost": Time in nanoseconds spent generating the message.
+	-- 	"spans": Source message ID, byte offsets in "orig", and matched context
+	-- 		length of each generated term.
+	-- 	"seed": Random seed which reproduces "orig", as a decimal string.
 	meta BLOB NOT NULL
 ) STRICT;
 
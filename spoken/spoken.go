@@ -9,6 +9,9 @@ import (
 
 	"github.com/go-json-experiment/json"
 	"zombiezen.com/go/sqlite/sqlitex"
+
+	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/migrate"
 )
 
 // History records messages generated by robot.
@@ -27,6 +30,36 @@ type meta struct {
 	Effect string `json:"effect,omitempty"`
 	// Cost is the time in nanoseconds spent generating the message.
 	Cost int64 `json:"cost,omitempty,omitzero"`
+	// Spans are the provenance of each generated term of the original message.
+	Spans []span `json:"spans,omitempty"`
+	// Seed is the seed which reproduces the original message.
+	// It is encoded as a string since it may not fit in a double.
+	Seed uint64 `json:"seed,omitzero,string"`
+}
+
+// span is the serialized form of [brain.Span].
+type span struct {
+	ID      string `json:"id"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Context int    `json:"context"`
+}
+
+// Provenance is the origin of the terms of a spoken message.
+type Provenance struct {
+	// Msg is the message as recorded.
+	Msg string
+	// Orig is the original generated message, prior to applying any emote
+	// or effect. Span offsets refer to it.
+	Orig string
+	// Spans are the provenance of each generated term of Orig.
+	// Terms which came from a prompt have no spans.
+	Spans []brain.Span
+	// Seed is the seed which reproduces Orig from the same knowledge.
+	// It is zero if the seed is unknown.
+	Seed uint64
+	// Time is the time at which the message was recorded.
+	Time time.Time
 }
 
 // Open opens an existing history in a DB.
@@ -36,7 +69,7 @@ func Open(ctx context.Context, db *sqlitex.Pool) (*History, error) {
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get connection from pool: %w", err)
 	}
-	if err := sqlitex.ExecuteScript(conn, schemaSQL, nil); err != nil {
+	if _, err := migrate.Apply(conn, &Schema); err != nil {
 		return nil, fmt.Errorf("couldn't initialize spoken messages schema: %w", err)
 	}
 	return &History{db}, nil
@@ -45,8 +78,17 @@ func Open(ctx context.Context, db *sqlitex.Pool) (*History, error) {
 //go:embed schema.sql
 var schemaSQL string
 
+// Schema is the list of migrations for spoken history databases.
+var Schema = migrate.Schema{
+	Name: "spoken",
+	Migrations: []migrate.Migration{
+		{Name: "initial schema", SQL: schemaSQL},
+	},
+}
+
 // Record records a message with its trace and metadata.
-func (h *History) Record(ctx context.Context, tag, msg string, trace []string, tm time.Time, cost time.Duration, orig, emote, effect string) error {
+// The offsets of spans refer to orig.
+func (h *History) Record(ctx context.Context, tag, msg string, trace []string, spans []brain.Span, tm time.Time, cost time.Duration, seed uint64, orig, emote, effect string) error {
 	conn, err := h.db.Take(ctx)
 	defer h.db.Put(conn)
 	if err != nil {
@@ -67,6 +109,13 @@ func (h *History) Record(ctx context.Context, tag, msg string, trace []string, t
 		Emote:  emote,
 		Effect: effect,
 		Cost:   cost.Nanoseconds(),
+		Seed:   seed,
+	}
+	if len(spans) != 0 {
+		m.Spans = make([]span, len(spans))
+		for i, sp := range spans {
+			m.Spans[i] = span{ID: sp.ID, Start: sp.Start, End: sp.End, Context: sp.Context}
+		}
 	}
 	md, err := json.Marshal(m)
 	if err != nil {
@@ -160,3 +209,57 @@ func (h *History) Since(ctx context.Context, tag string, tm time.Time) iter.Seq2
 		}
 	}
 }
+
+// Recent provides an iterator over the provenance of messages recorded since
+// the given time, most recent first.
+func (h *History) Recent(ctx context.Context, tag string, tm time.Time) iter.Seq2[*Provenance, error] {
+	conn, err := h.db.Take(ctx)
+	// NOTE(zeph): we don't defer return the conn here because we need it alive
+	// for the entire iterator
+	if err != nil {
+		h.db.Put(conn)
+		return once2[*Provenance](nil, fmt.Errorf("couldn't get conn to find recent messages: %w", err))
+	}
+	const sel = `SELECT msg, JSON(meta), time FROM spoken WHERE tag = :tag AND time >= :time ORDER BY time DESC`
+	st, err := conn.Prepare(sel)
+	if err != nil {
+		h.db.Put(conn)
+		return once2[*Provenance](nil, fmt.Errorf("couldn't prepare statement to find recent messages: %w", err))
+	}
+	st.SetText(":tag", tag)
+	st.SetInt64(":time", tm.UnixNano())
+	return func(yield func(*Provenance, error) bool) {
+		defer h.db.Put(conn)
+		defer st.Reset()
+		for {
+			ok, err := st.Step()
+			if err != nil {
+				yield(nil, fmt.Errorf("couldn't get recent messages: %w", err))
+				return
+			}
+			if !ok {
+				return
+			}
+			var m meta
+			if err := json.Unmarshal([]byte(st.ColumnText(1)), &m); err != nil {
+				yield(nil, fmt.Errorf("couldn't decode metadata: %w", err))
+				return
+			}
+			p := Provenance{
+				Msg:  st.ColumnText(0),
+				Orig: m.Orig,
+				Seed: m.Seed,
+				Time: time.Unix(0, st.ColumnInt64(2)),
+			}
+			if len(m.Spans) != 0 {
+				p.Spans = make([]brain.Span, len(m.Spans))
+				for i, sp := range m.Spans {
+					p.Spans[i] = brain.Span{ID: sp.ID, Start: sp.Start, End: sp.End, Context: sp.Context}
+				}
+			}
+			if !yield(&p, nil) {
+				return
+			}
+		}
+	}
+}
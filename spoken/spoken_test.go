@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/go-json-experiment/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/zephyrtronium/robot/brain"
 	"github.com/zephyrtronium/robot/spoken"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
@@ -38,7 +40,7 @@ func TestRecord(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = h.Record(ctx, "kessoku", "boccho ryo xD", []string{"1", "2"}, time.Unix(1, 0), time.Second, "bocchi ryo", "xD", "o")
+	err = h.Record(ctx, "kessoku", "boccho ryo xD", []string{"1", "2"}, nil, time.Unix(1, 0), time.Second, 0xffffffffffffffff, "bocchi ryo", "xD", "o")
 	if err != nil {
 		t.Errorf("couldn't record: %v", err)
 	}
@@ -76,6 +78,7 @@ func TestRecord(t *testing.T) {
 				"emote":  "xD",
 				"effect": "o",
 				"cost":   float64(time.Second.Nanoseconds()),
+				"seed":   "18446744073709551615",
 			}
 			if !maps.Equal(md, want) {
 				t.Errorf("wrong metadata recorded: want %v, got %v from %q", want, md, meta)
@@ -278,3 +281,49 @@ func TestSince(t *testing.T) {
 		})
 	}
 }
+
+func TestRecent(t *testing.T) {
+	ctx := context.Background()
+	db := testDB()
+	h, err := spoken.Open(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := []brain.Span{
+		{ID: "1", Start: 0, End: 7, Context: 0},
+		{ID: "2", Start: 7, End: 10, Context: 1},
+	}
+	if err := h.Record(ctx, "kessoku", "bocchi ryo xD", []string{"1", "2"}, spans, time.Unix(0, 10), 0, 0xffffffffffffffff, "bocchi ryo", "xD", ""); err != nil {
+		t.Fatalf("couldn't record: %v", err)
+	}
+	if err := h.Record(ctx, "kessoku", "nijika", []string{"3"}, nil, time.Unix(0, 20), 0, 0, "nijika", "", ""); err != nil {
+		t.Fatalf("couldn't record: %v", err)
+	}
+	if err := h.Record(ctx, "sickhack", "hiroi", []string{"4"}, nil, time.Unix(0, 30), 0, 0, "hiroi", "", ""); err != nil {
+		t.Fatalf("couldn't record: %v", err)
+	}
+	var got []*spoken.Provenance
+	for p, err := range h.Recent(ctx, "kessoku", time.Unix(0, 5)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	want := []*spoken.Provenance{
+		{Msg: "nijika", Orig: "nijika", Time: time.Unix(0, 20)},
+		{Msg: "bocchi ryo xD", Orig: "bocchi ryo", Spans: spans, Seed: 0xffffffffffffffff, Time: time.Unix(0, 10)},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("wrong provenance (+got/-want):\n%s", diff)
+	}
+	got = got[:0]
+	for p, err := range h.Recent(ctx, "kessoku", time.Unix(0, 15)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p)
+	}
+	if diff := cmp.Diff(want[:1], got); diff != "" {
+		t.Errorf("wrong provenance since later time (+got/-want):\n%s", diff)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"golang.org/x/sync/errgroup"
 
 	"github.com/zephyrtronium/robot/brain"
+	"github.com/zephyrtronium/robot/channel"
 	"github.com/zephyrtronium/robot/userhash"
 )
 
@@ -53,12 +54,18 @@ func (robo *Robot) joinTwitch(ctx context.Context, send chan<- *tmi.Message) {
 	for _, ch := range robo.channels.All() {
 		ls = append(ls, ch.Name)
 	}
+	joinPart(ctx, send, "JOIN", ls)
+}
+
+// joinPart sends JOIN or PART messages for a list of channels, respecting
+// Twitch's rate limits.
+func joinPart(ctx context.Context, send chan<- *tmi.Message, cmd string, ls []string) {
 	burst := 20
 	for len(ls) > 0 {
 		l := ls[:min(burst, len(ls))]
 		ls = ls[len(l):]
 		msg := tmi.Message{
-			Command: "JOIN",
+			Command: cmd,
 			Params:  []string{strings.Join(l, ",")},
 		}
 		select {
@@ -96,6 +103,19 @@ func (robo *Robot) clearchat(ctx context.Context, group *errgroup.Group, msg *tm
 			if err != nil {
 				slog.ErrorContext(ctx, "failed to forget from all chat", slog.Any("err", err), slog.String("channel", msg.To()))
 			}
+			// We don't know which messages were forgotten, so anything
+			// pregenerated from this tag might be tainted.
+			for _, c := range robo.channels.All() {
+				if c.Send == tag {
+					c.Pregen.Clear()
+				}
+			}
+			ch.History.Clear()
+			if robo.chatlog != nil {
+				if _, err := robo.chatlog.Expire(ctx, ch.Name, msg.Time()); err != nil {
+					slog.ErrorContext(ctx, "failed to clear chat log", slog.Any("err", err), slog.String("channel", msg.To()))
+				}
+			}
 		}
 	case robo.tmi.userID:
 		work = func(ctx context.Context) {
@@ -112,6 +132,7 @@ func (robo *Robot) clearchat(ctx context.Context, group *errgroup.Group, msg *tm
 					continue
 				}
 				forgortCount.Inc()
+				channel.Invalidate(robo.channels.All(), tag, id)
 				if err := robo.brain.ForgetMessage(ctx, tag, id); err != nil {
 					slog.ErrorContext(ctx, "failed to forget from recent trace",
 						slog.Any("err", err),
@@ -137,6 +158,17 @@ func (robo *Robot) clearchat(ctx context.Context, group *errgroup.Group, msg *tm
 			if err := robo.brain.ForgetUser(ctx, h); err != nil {
 				slog.ErrorContext(ctx, "failed to forget older messages from user", slog.Any("err", err), slog.String("channel", msg.To()))
 			}
+			// Pregenerated messages don't know who they came from.
+			for _, c := range robo.channels.All() {
+				c.Pregen.Clear()
+			}
+			who := robo.sender(t)
+			ch.History.RemoveSender(who)
+			if robo.chatlog != nil {
+				if err := robo.chatlog.ForgetSender(ctx, ch.Name, who); err != nil {
+					slog.ErrorContext(ctx, "failed to forget user from chat log", slog.Any("err", err), slog.String("channel", msg.To()))
+				}
+			}
 		}
 	}
 	robo.enqueue(ctx, group, work)
@@ -158,6 +190,13 @@ func (robo *Robot) clearmsg(ctx context.Context, group *errgroup.Group, msg *tmi
 			// Forget a message from someone else.
 			log.InfoContext(ctx, "forget message", slog.String("tag", ch.Learn), slog.String("id", t))
 			forget(ctx, log, robo.brain, ch.Learn, t)
+			channel.Invalidate(robo.channels.All(), ch.Learn, t)
+			ch.History.Remove(t)
+			if robo.chatlog != nil {
+				if err := robo.chatlog.Forget(ctx, ch.Name, t); err != nil {
+					log.ErrorContext(ctx, "failed to forget from chat log", slog.Any("err", err))
+				}
+			}
 			return
 		}
 		// Forget a message from the robo.
@@ -178,6 +217,7 @@ func (robo *Robot) clearmsg(ctx context.Context, group *errgroup.Group, msg *tmi
 		}
 		log.InfoContext(ctx, "forget trace", slog.String("tag", ch.Send), slog.Any("spoken", tm), slog.Any("trace", trace))
 		forget(ctx, log, robo.brain, ch.Send, trace...)
+		channel.Invalidate(robo.channels.All(), ch.Send, trace...)
 	}
 	robo.enqueue(ctx, group, work)
 }
@@ -78,3 +78,19 @@ func (h Hasher) Hash(dst *Hash, uid, where string, when time.Time) *Hash {
 	h.mac.Write(b)
 	return (*Hash)(h.mac.Sum(dst[:0]))
 }
+
+// Sender computes a hash identifying a user alone and writes it into dst.
+// Unlike a userhash, it is the same in every location and at every time, so
+// it correlates all of a user's messages. It is meant for records which are
+// kept only briefly and must be deleted by user, like channel histories.
+func (h Hasher) Sender(dst *Hash, uid string) *Hash {
+	h.mac.Reset()
+	b := make([]byte, 0, len(senderDomain)+len(uid))
+	b = append(b, senderDomain...)
+	b = append(b, uid...)
+	h.mac.Write(b)
+	return (*Hash)(h.mac.Sum(dst[:0]))
+}
+
+// senderDomain separates sender hashes from userhashes.
+const senderDomain = "sender\xbb"
@@ -50,3 +50,21 @@ func TestHasher(t *testing.T) {
 		}
 	}
 }
+
+func TestSender(t *testing.T) {
+	t.Parallel()
+	hr := userhash.New([]byte("madoka"))
+	a := *hr.Sender(new(userhash.Hash), "bocchi")
+	if b := *hr.Sender(new(userhash.Hash), "bocchi"); a != b {
+		t.Errorf("repeated hash changed: first %x then %x", a, b)
+	}
+	if b := *hr.Sender(new(userhash.Hash), "nijika"); a == b {
+		t.Errorf("different users gave the same hash %x", a)
+	}
+	if b := *userhash.New([]byte("homura")).Sender(new(userhash.Hash), "bocchi"); a == b {
+		t.Errorf("different keys gave the same hash %x", a)
+	}
+	if b := *hr.Hash(new(userhash.Hash), "bocchi", "", time.Unix(0, 0)); a == b {
+		t.Errorf("sender hash matches userhash %x", a)
+	}
+}